@@ -1,8 +1,11 @@
 package goquery
 
 import (
+	"context"
 	"strconv"
 	"testing"
+
+	"golang.org/x/net/html"
 )
 
 func BenchmarkEach(b *testing.B) {
@@ -25,6 +28,56 @@ func BenchmarkEach(b *testing.B) {
 	}
 }
 
+func BenchmarkEachContext(b *testing.B) {
+	var tmp, n int
+
+	b.StopTimer()
+	sel := DocW().Find("td")
+	f := func(i int, s *Selection) {
+		tmp++
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		sel.EachContext(ctx, f)
+		if n == 0 {
+			n = tmp
+		}
+	}
+	if n != 59 {
+		b.Fatalf("want 59, got %d", n)
+	}
+}
+
+func BenchmarkWalkContext(b *testing.B) {
+	var tmp, n int
+
+	b.StopTimer()
+	sel := DocW().Find("body")
+	f := func(s *Selection) bool {
+		tmp++
+		return true
+	}
+	want := 0
+	walkDescendants(sel.Nodes[0], true, false, func(*html.Node) bool {
+		want++
+		return true
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		sel.WalkContext(ctx, f)
+		if n == 0 {
+			n = tmp
+		}
+	}
+	if n != want {
+		b.Fatalf("want %d, got %d", want, n)
+	}
+}
+
 func BenchmarkEachIter(b *testing.B) {
 	var tmp, n int
 
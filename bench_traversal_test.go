@@ -1,6 +1,7 @@
 package goquery
 
 import (
+	"context"
 	"testing"
 
 	"github.com/andybalholm/cascadia"
@@ -22,6 +23,25 @@ func BenchmarkFind(b *testing.B) {
 	}
 }
 
+func BenchmarkFindContext(b *testing.B) {
+	var n int
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for i := 0; i < b.N; i++ {
+		sel, err := DocB().FindContext(ctx, "dd")
+		if err != nil {
+			b.Fatal(err)
+		}
+		if n == 0 {
+			n = sel.Length()
+		}
+	}
+	if n != 41 {
+		b.Fatalf("want 41, got %d", n)
+	}
+}
+
 func BenchmarkFindWithinSelection(b *testing.B) {
 	var n int
 
@@ -820,3 +840,23 @@ func BenchmarkSingleMatcher(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkFindDocumentOrder(b *testing.B) {
+	var n int
+
+	b.StopTimer()
+	doc := NewDocumentFromNode(DocW().rootNode)
+	doc.DocumentOrder = true
+	sel := doc.Find("*")
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		if n == 0 {
+			n = sel.Find("*").Length()
+		} else {
+			sel.Find("*")
+		}
+	}
+	if n != sel.Length()-1 {
+		b.Fatalf("want %d, got %d", sel.Length()-1, n)
+	}
+}
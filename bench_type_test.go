@@ -0,0 +1,38 @@
+package goquery
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func loadBenchSource(b *testing.B) string {
+	src, err := os.ReadFile("testdata/gowiki.html")
+	if err != nil {
+		b.Fatal(err)
+	}
+	return string(src)
+}
+
+func BenchmarkNewDocumentFromReader(b *testing.B) {
+	src := loadBenchSource(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewDocumentFromReader(strings.NewReader(src)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkNewDocumentFromReaderContext(b *testing.B) {
+	src := loadBenchSource(b)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := NewDocumentFromReaderContext(ctx, strings.NewReader(src)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package goquery
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// El creates an element node with the given tag name, to be used with the
+// *Nodes manipulation methods, such as AppendNodes, WrapNode or
+// ReplaceWithNodes, e.g.:
+//
+//	sel.AppendNodes(goquery.El("a", goquery.Attr("href", u), goquery.Text(label)))
+//
+// The args may be of the following types, any other type causes a panic:
+//
+//	html.Attribute : added to the attributes of the element (see Attr)
+//	[]html.Attribute : each attribute is added
+//	*html.Node : appended as child (see Text and Comment), or a clone of it
+//	             if it is already attached to a tree
+//	[]*html.Node : each node is appended as child
+//	*Selection : a clone of each node of the selection is appended as child
+//	string : appended as a text node
+//	nil : ignored
+//
+// As for the nodes created by the HTML parser, the DataAtom of the element is
+// set, the tag name of HTML elements is lowercased, and the "svg" and "math"
+// elements and their descendants are created in the SVG and MathML
+// namespaces (except inside the elements that switch back to HTML content,
+// such as foreignObject), with namespaced attributes such as "xlink:href"
+// split into their namespace and key. The elements built without a namespace
+// are also put in the namespace of the SVG or MathML content they are
+// inserted in by the *Nodes methods, e.g. El("rect") appended to an svg
+// element, while the nodes moved from a tree keep their namespace. Text and attribute values are stored
+// unescaped, they are escaped when the node is rendered.
+func El(tag string, args ...any) *html.Node {
+	tag = strings.ToLower(tag)
+	n := &html.Node{Type: html.ElementNode, Data: tag, DataAtom: atom.Lookup([]byte(tag))}
+	if tag == "svg" || tag == "math" {
+		n.Namespace = tag
+	}
+
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case nil:
+		case html.Attribute:
+			n.Attr = append(n.Attr, arg)
+		case []html.Attribute:
+			n.Attr = append(n.Attr, arg...)
+		case *html.Node:
+			appendBuiltChild(n, arg)
+		case []*html.Node:
+			for _, c := range arg {
+				appendBuiltChild(n, c)
+			}
+		case *Selection:
+			for _, c := range arg.Nodes {
+				appendBuiltChild(n, cloneNode(c))
+			}
+		case string:
+			appendBuiltChild(n, Text(arg))
+		default:
+			panic(fmt.Sprintf("goquery: unsupported El argument of type %T", arg))
+		}
+	}
+
+	if n.Namespace != "" {
+		setForeignNamespace(n, n.Namespace)
+	}
+	return n
+}
+
+// Attr creates an attribute, to be used as argument to El.
+func Attr(key, val string) html.Attribute {
+	return html.Attribute{Key: key, Val: val}
+}
+
+// Text creates a text node, to be used as argument to El or with the *Nodes
+// manipulation methods.
+func Text(text string) *html.Node {
+	return &html.Node{Type: html.TextNode, Data: text}
+}
+
+// Comment creates a comment node, to be used as argument to El or with the
+// *Nodes manipulation methods.
+func Comment(text string) *html.Node {
+	return &html.Node{Type: html.CommentNode, Data: text}
+}
+
+func appendBuiltChild(n, c *html.Node) {
+	if c == nil {
+		return
+	}
+	if c.Parent != nil || c.PrevSibling != nil || c.NextSibling != nil {
+		c = cloneNode(c)
+	}
+	n.AppendChild(c)
+}
+
+// foreignAttrNamespaces are the prefixes of the namespaced attributes of the
+// foreign elements, as adjusted by the HTML parser.
+var foreignAttrNamespaces = []string{"xlink", "xml", "xmlns"}
+
+// svgTagNames are the SVG element names that are not lowercase, indexed by
+// their lowercase form.
+var svgTagNames = map[string]string{}
+
+func init() {
+	for _, name := range []string{"altGlyph", "altGlyphDef", "altGlyphItem",
+		"animateColor", "animateMotion", "animateTransform", "clipPath",
+		"feBlend", "feColorMatrix", "feComponentTransfer", "feComposite",
+		"feConvolveMatrix", "feDiffuseLighting", "feDisplacementMap",
+		"feDistantLight", "feDropShadow", "feFlood", "feFuncA", "feFuncB",
+		"feFuncG", "feFuncR", "feGaussianBlur", "feImage", "feMerge",
+		"feMergeNode", "feMorphology", "feOffset", "fePointLight",
+		"feSpecularLighting", "feSpotLight", "feTile", "feTurbulence",
+		"foreignObject", "glyphRef", "linearGradient", "radialGradient",
+		"textPath"} {
+		svgTagNames[strings.ToLower(name)] = name
+	}
+}
+
+// adoptForeignNamespace puts n, an element built without a namespace, such as
+// by El, and its descendants in the namespace of its parent, if it is in
+// SVG or MathML content, as the parser does for the elements of foreign
+// content.
+func adoptForeignNamespace(n *html.Node) {
+	if n.Type == html.ElementNode && n.Namespace == "" && n.Parent != nil && isForeignContext(n.Parent) {
+		setForeignNamespace(n, n.Parent.Namespace)
+	}
+}
+
+// setForeignNamespace sets the namespace of n, its attributes and its
+// descendant elements that don't have a namespace yet, stopping at the
+// elements that switch back to HTML content. The names of the SVG elements
+// are adjusted to their mixed-case form, as done by the HTML parser.
+func setForeignNamespace(n *html.Node, ns string) {
+	n.Namespace = ns
+	if name, ok := svgTagNames[n.Data]; ok && ns == "svg" {
+		n.Data = name
+	}
+	for i, a := range n.Attr {
+		if a.Namespace != "" {
+			continue
+		}
+		for _, prefix := range foreignAttrNamespaces {
+			if key, ok := strings.CutPrefix(a.Key, prefix+":"); ok {
+				n.Attr[i].Namespace, n.Attr[i].Key = prefix, key
+				break
+			}
+		}
+	}
+	if isHTMLIntegrationPoint(n) {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.Namespace == "" {
+			setForeignNamespace(c, ns)
+		}
+	}
+}
+
+// isHTMLIntegrationPoint returns true if n is a foreign element whose
+// children are HTML content.
+func isHTMLIntegrationPoint(n *html.Node) bool {
+	switch n.Namespace {
+	case "svg":
+		switch n.Data {
+		case "foreignObject", "desc", "title":
+			return true
+		}
+	case "math":
+		switch n.Data {
+		case "mi", "mo", "mn", "ms", "mtext", "annotation-xml":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package goquery
+
+import (
+	"testing"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+func TestEl(t *testing.T) {
+	n := El("A", Attr("href", "/a?b=1&c=2"), Attr("title", `"x"`), "1 < 2", El("b", "bold"), Comment("c"))
+	if n.Data != "a" || n.DataAtom != atom.A {
+		t.Errorf("want lowercase a element, got %q (%v)", n.Data, n.DataAtom)
+	}
+	got, err := OuterHtml(newSingleSelection(n, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<a href="/a?b=1&amp;c=2" title="&#34;x&#34;">1 &lt; 2<b>bold</b><!--c--></a>`
+	if got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestElWithManipulation(t *testing.T) {
+	doc := Doc2Clone()
+	sel := doc.Find("#nf1")
+	sel.AppendNodes(El("span", Attr("class", "built"), "text"))
+	assertLength(t, doc.Find("#nf1 > span.built").Nodes, 1)
+
+	sel.WrapNode(El("section", Attr("id", "wrapper")))
+	assertLength(t, doc.Find("#wrapper > #nf1").Nodes, 1)
+
+	doc.Find("span.built").ReplaceWithNodes(Text("plain"))
+	assertLength(t, doc.Find("span.built").Nodes, 0)
+	if txt := sel.Text(); txt != "plain" {
+		t.Errorf("want text plain, got %q", txt)
+	}
+}
+
+func TestElAttachedChildIsCloned(t *testing.T) {
+	doc := Doc2Clone()
+	sel := doc.Find("#nf1")
+	n := El("div", sel, sel.Nodes[0])
+	assertLength(t, doc.Find("#nf1").Nodes, 1)
+	if n.FirstChild == sel.Nodes[0] || n.LastChild == sel.Nodes[0] {
+		t.Error("want attached nodes to be cloned")
+	}
+	if n.FirstChild == nil || n.FirstChild.NextSibling != n.LastChild {
+		t.Error("want 2 children")
+	}
+}
+
+func TestElForeign(t *testing.T) {
+	n := El("svg",
+		El("use", Attr("xlink:href", "#r")),
+		El("lineargradient"),
+		El("foreignObject", El("div")))
+
+	if n.Namespace != "svg" {
+		t.Fatalf("want svg namespace, got %q", n.Namespace)
+	}
+	use := n.FirstChild
+	if use.Namespace != "svg" || use.Attr[0].Namespace != "xlink" || use.Attr[0].Key != "href" {
+		t.Errorf("want svg use with xlink:href, got %q %+v", use.Namespace, use.Attr)
+	}
+	if grad := use.NextSibling; grad.Data != "linearGradient" {
+		t.Errorf("want linearGradient, got %q", grad.Data)
+	}
+	fo := n.LastChild
+	if fo.Data != "foreignObject" || fo.Namespace != "svg" {
+		t.Errorf("want svg foreignObject, got %q %q", fo.Namespace, fo.Data)
+	}
+	if div := fo.FirstChild; div.Namespace != "" {
+		t.Errorf("want HTML div inside foreignObject, got namespace %q", div.Namespace)
+	}
+
+	got, err := OuterHtml(newSingleSelection(n.FirstChild, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<use xlink:href="#r"></use>`; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestElInsertedInForeignContent(t *testing.T) {
+	doc := loadString(t, `<svg id="s"><g id="g"><circle></circle></g><foreignObject id="f"></foreignObject></svg>`)
+	doc.Find("#s").AppendNodes(El("rect", Attr("xlink:href", "#g")))
+	doc.Find("circle").BeforeNodes(El("lineargradient"))
+	doc.Find("circle").WrapNode(El("g", Attr("id", "w")))
+	doc.Find("#f").AppendNodes(El("div"))
+
+	for _, sel := range []string{"svg|rect[xlink|href]", "svg|linearGradient", "svg|g#w > svg|circle"} {
+		if got := doc.Find(sel).Length(); got != 1 {
+			t.Errorf("%s: want 1 node, got %d", sel, got)
+		}
+	}
+	if got := doc.Find("#f > div").Nodes[0].Namespace; got != "" {
+		t.Errorf("want an HTML div in foreignObject, got namespace %q", got)
+	}
+
+	// the same as the parsed elements
+	want := loadString(t, `<svg id="s"><g id="g"><lineargradient></lineargradient><g id="w"><circle></circle></g></g>`+
+		`<foreignObject id="f"><div></div></foreignObject><rect xlink:href="#g"></rect></svg>`)
+	if got, want := mustOuterHtml(t, doc.Find("svg")), mustOuterHtml(t, want.Find("svg")); got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+
+	// the nodes moved from a tree keep their namespace
+	doc.Find("#s").AppendSelection(doc.Find("#f > div"))
+	if got := doc.Find("#s > div").Nodes[0].Namespace; got != "" {
+		t.Errorf("want the moved div kept in HTML, got namespace %q", got)
+	}
+}
+
+func TestElUnsupported(t *testing.T) {
+	defer assertPanic(t)
+	El("div", 42)
+}
+
+func TestElNilArgs(t *testing.T) {
+	var n *html.Node
+	el := El("p", nil, n)
+	if el.FirstChild != nil {
+		t.Error("want no child")
+	}
+}
@@ -0,0 +1,178 @@
+package goquery
+
+import (
+	"context"
+	"io"
+
+	"golang.org/x/net/html"
+)
+
+// contextCheckInterval is the number of nodes visited between two checks of
+// the context by the *Context methods.
+const contextCheckInterval = 1024
+
+// NewDocumentFromReaderContext returns a Document from an io.Reader, like
+// NewDocumentFromReader, but stops parsing and returns ctx.Err() when ctx is
+// done.
+func NewDocumentFromReaderContext(ctx context.Context, r io.Reader) (*Document, error) {
+	return NewDocumentFromReaderWithOptionsContext(ctx, r, DocumentOptions{})
+}
+
+// NewDocumentFromReaderWithOptionsContext returns a Document from an
+// io.Reader, like NewDocumentFromReaderWithOptions, but stops parsing and
+// returns ctx.Err() when ctx is done.
+func NewDocumentFromReaderWithOptionsContext(ctx context.Context, r io.Reader, opts DocumentOptions) (*Document, error) {
+	return newDocumentFromReader(ctx, r, opts)
+}
+
+// FindContext gets the descendants of each element in the current set of
+// matched elements, filtered by a selector, like Find. It checks ctx
+// periodically while searching, and returns an empty Selection and
+// ctx.Err() when ctx is done.
+func (s *Selection) FindContext(ctx context.Context, selector string) (*Selection, error) {
+	return s.FindMatcherContext(ctx, compileMatcher(selector))
+}
+
+// FindMatcherContext gets the descendants of each element in the current set
+// of matched elements, filtered by the matcher, like FindMatcher. The nodes
+// are matched one by one with the Match method of m. It checks ctx
+// periodically while searching, and returns an empty Selection and
+// ctx.Err() when ctx is done.
+func (s *Selection) FindMatcherContext(ctx context.Context, m Matcher) (*Selection, error) {
+	cc := &contextChecker{ctx: ctx}
+	nodes := mapNodes(s.Nodes, func(i int, n *html.Node) []*html.Node {
+		return matchDescendantsContext(n, m, s.composed(), cc)
+	})
+	if cc.err != nil {
+		return pushStack(s, nil), cc.err
+	}
+	return pushStack(s, nodes), nil
+}
+
+// EachContext iterates over a Selection object, executing a function for each
+// matched element, like Each. It checks ctx before each call, and stops and
+// returns ctx.Err() when ctx is done. It returns the current Selection
+// object.
+func (s *Selection) EachContext(ctx context.Context, f func(int, *Selection)) (*Selection, error) {
+	for i, n := range s.Nodes {
+		if err := ctx.Err(); err != nil {
+			return s, err
+		}
+		f(i, newSingleSelection(n, s.document))
+	}
+	return s, nil
+}
+
+// WalkContext calls f for each descendant node of the elements in the current
+// set of matched elements, including the text and comment nodes, in document
+// order and skipping the content of the templates, like Find. The walk stops
+// when f returns false. It checks ctx periodically while walking, and stops
+// and returns ctx.Err() when ctx is done. It returns the current Selection
+// object.
+func (s *Selection) WalkContext(ctx context.Context, f func(*Selection) bool) (*Selection, error) {
+	cc := &contextChecker{ctx: ctx}
+	for _, n := range s.Nodes {
+		more := walkDescendants(n, true, s.composed(), func(c *html.Node) bool {
+			return cc.check() && f(newSingleSelection(c, s.document))
+		})
+		if !more {
+			break
+		}
+	}
+	return s, cc.err
+}
+
+// contextChecker checks if a context is done every contextCheckInterval
+// calls of check.
+type contextChecker struct {
+	ctx context.Context
+	n   int
+	err error
+}
+
+// check returns false if the context is done.
+func (cc *contextChecker) check() bool {
+	if cc.err == nil && cc.n%contextCheckInterval == 0 {
+		cc.err = cc.ctx.Err()
+	}
+	cc.n++
+	return cc.err == nil
+}
+
+// matchDescendantsContext returns the nodes returned by matchDescendants, or
+// nil if cc's context is done before the search ends.
+func matchDescendantsContext(n *html.Node, m Matcher, composed bool, cc *contextChecker) []*html.Node {
+	w := &contextMatch{m: m, cc: cc}
+	_, w.single = m.(singleMatcher)
+	if composed {
+		walkDescendants(n, true, true, func(c *html.Node) bool {
+			return c.Type != html.ElementNode || w.match(c)
+		})
+	} else {
+		w.walk(n)
+	}
+	if cc.err != nil {
+		return nil
+	}
+	return w.result
+}
+
+// contextMatch is the state of a search by matchDescendantsContext.
+type contextMatch struct {
+	m      Matcher
+	single bool
+	cc     *contextChecker
+	result []*html.Node
+}
+
+// match adds the element n to the result if it matches, and returns false if
+// the search is over.
+func (w *contextMatch) match(n *html.Node) bool {
+	if !w.cc.check() {
+		return false
+	}
+	if w.m.Match(n) {
+		w.result = append(w.result, n)
+		return !w.single
+	}
+	return true
+}
+
+// walk matches the element descendants of n, skipping the content of the
+// templates below n, and returns false if the search is over.
+func (w *contextMatch) walk(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		if !w.match(c) {
+			return false
+		}
+		if !isTemplate(c) && !w.walk(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// contextReader reads from r until ctx is done, and then returns ctx.Err().
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr *contextReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+// withContext returns r, read until ctx is done.
+func withContext(ctx context.Context, r io.Reader) io.Reader {
+	if ctx.Done() == nil {
+		// the context is never done
+		return r
+	}
+	return &contextReader{ctx, r}
+}
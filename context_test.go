@@ -0,0 +1,141 @@
+package goquery
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// cancelReader cancels its context after the first read.
+type cancelReader struct {
+	r      *strings.Reader
+	cancel context.CancelFunc
+}
+
+func (cr *cancelReader) Read(p []byte) (int, error) {
+	defer cr.cancel()
+	return cr.r.Read(p[:min(len(p), 16)])
+}
+
+func TestNewDocumentFromReaderContext(t *testing.T) {
+	src := `<div><p>a</p><p>b</p></div>`
+	doc, err := NewDocumentFromReaderContext(context.Background(), strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLength(t, doc.Find("p").Nodes, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewDocumentFromReaderContext(ctx, strings.NewReader(src)); !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+
+	for _, opts := range []DocumentOptions{{}, {Positions: true}} {
+		ctx, cancel := context.WithCancel(context.Background())
+		r := &cancelReader{strings.NewReader(src), cancel}
+		if _, err := NewDocumentFromReaderWithOptionsContext(ctx, r, opts); !errors.Is(err, context.Canceled) {
+			t.Errorf("want context.Canceled while parsing, got %v", err)
+		}
+	}
+}
+
+func TestFindContext(t *testing.T) {
+	doc := DocW()
+	for _, sel := range []string{"a[class]", "td", "#toc li", "nothing"} {
+		got, err := doc.FindContext(context.Background(), sel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := doc.Find(sel); !slices.Equal(got.Nodes, want.Nodes) {
+			t.Errorf("%s: want %d nodes as Find, got %d", sel, want.Length(), got.Length())
+		}
+	}
+	got, err := doc.Find("ul").FindMatcherContext(context.Background(), Single("a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := doc.Find("ul").FindMatcher(Single("a")); !slices.Equal(got.Nodes, want.Nodes) {
+		t.Errorf("want %d nodes as FindMatcher, got %d", want.Length(), got.Length())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	got, err = doc.FindContext(ctx, "td")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("want context.Canceled, got %v", err)
+	}
+	assertLength(t, got.Nodes, 0)
+	if got.End() != doc.Selection {
+		t.Error("want End to return the document")
+	}
+}
+
+func TestFindContextTemplate(t *testing.T) {
+	doc := loadString(t, `<div><template><p>inert</p></template><p>live</p></div>`)
+	got, err := doc.FindContext(context.Background(), "p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLength(t, got.Nodes, 1)
+	if got.Text() != "live" {
+		t.Errorf("want the live p, got %q", got.Text())
+	}
+}
+
+func TestEachContext(t *testing.T) {
+	sel := Doc().Find(".pvk-content")
+	var calls int
+	ret, err := sel.EachContext(context.Background(), func(i int, s *Selection) {
+		calls++
+	})
+	if err != nil || ret != sel || calls != sel.Length() {
+		t.Errorf("want %d calls, got %d and error %v", sel.Length(), calls, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls = 0
+	_, err = sel.EachContext(ctx, func(i int, s *Selection) {
+		calls++
+		cancel()
+	})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("want context.Canceled after 1 call, got %d and error %v", calls, err)
+	}
+}
+
+func TestWalkContext(t *testing.T) {
+	doc := loadString(t, `<div><p>a<!--c--></p><template><p>t</p></template></div><div><b>b</b></div>`)
+	var got []string
+	sel := doc.Find("div")
+	ret, err := sel.WalkContext(context.Background(), func(s *Selection) bool {
+		got = append(got, NodeName(s))
+		return true
+	})
+	want := []string{"p", "#text", "#comment", "template", "b", "#text"}
+	if err != nil || ret != sel || !slices.Equal(got, want) {
+		t.Errorf("want %v, got %v and error %v", want, got, err)
+	}
+
+	got = nil
+	sel.WalkContext(context.Background(), func(s *Selection) bool {
+		got = append(got, NodeName(s))
+		return !s.Is("p")
+	})
+	if want := []string{"p"}; !slices.Equal(got, want) {
+		t.Errorf("want the walk stopped at %v, got %v", want, got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	got = nil
+	_, err = sel.WalkContext(ctx, func(s *Selection) bool {
+		got = append(got, NodeName(s))
+		return true
+	})
+	if !errors.Is(err, context.Canceled) || len(got) != 0 {
+		t.Errorf("want context.Canceled before any call, got %v and error %v", got, err)
+	}
+}
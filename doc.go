@@ -58,6 +58,19 @@ The three dots (...) indicate that various "overloads" are available.
     - Last()
     - Slice()
 
+* builder.go : construction of nodes to insert in a document.
+    - El(), Attr(), Text(), Comment()
+
+* context.go : methods that stop when a context.Context is done.
+    - EachContext()
+    - Find...Context()
+    - NewDocumentFromReader...Context()
+    - WalkContext()
+
+* embedded.go : documents embedded in iframe srcdoc and noscript elements.
+    - ContentDocument(), ParseContentDocument()
+    - Document.FrameElement(), Document.ParentDocument()
+
 * expand.go : methods that expand or augment the selection's set.
     - Add...()
     - AndSelf()
@@ -70,27 +83,64 @@ The three dots (...) indicate that various "overloads" are available.
     - Intersection(), which is an alias of FilterSelection()
     - Not...()
 
+* fragment.go : parsing of HTML fragments, and options and errors of the
+*HtmlWithOptions methods.
+    - HtmlOptions
+    - HtmlParseError
+    - NewFragment()
+
+* infer.go : inference of selectors from example nodes.
+    - InferSelector...()
+
 * iteration.go : methods to loop over the selection's nodes.
     - Each()
     - EachWithBreak()
     - Map()
 
+* limit.go : limits on the documents parsed with DocumentOptions.
+    - Document.Truncated()
+    - Limit, LimitError
+    - DefaultMaxBytes
+
 * manipulation.go : methods for modifying the document
     - After...()
     - Append...()
+    - AppendTo...()
     - Before...()
     - Clone()
     - Empty()
+    - InsertAfter...()
+    - InsertBefore...()
+    - Normalize...()
     - Prepend...()
+    - PrependTo...()
     - Remove...()
+    - ReplaceAll...()
     - ReplaceWith...()
     - Unwrap()
     - Wrap...()
     - WrapAll...()
     - WrapInner...()
 
+* observer.go : observation of the changes made to a document.
+    - Document.Observe()
+    - MutationObserver
+    - MutationRecord
+
+* order.go : document order of the nodes.
+    - CompareDocumentPosition()
+    - SortDocumentOrder()
+
+* path.go : selectors that uniquely identify the nodes in their document.
+    - CSSPath...()
+    - XPathPath...()
+
+* position.go : positions of the nodes in the source of their document.
+    - Document.NodeAt(), Document.NodesInRange()
+    - Position()
+
 * property.go : methods that inspect and get the node's properties values.
-    - Attr*(), RemoveAttr(), SetAttr()
+    - Attr*(), RemoveAttr*(), SetAttr*()
     - AddClass(), HasClass(), RemoveClass(), ToggleClass()
     - Html()
     - Length()
@@ -101,8 +151,35 @@ The three dots (...) indicate that various "overloads" are available.
     - Contains()
     - Is...()
 
+* range.go : ranges of the content of a document, like the DOM Range.
+    - Document.NewRange()
+    - Range
+
+* render.go : rendering of the HTML of a node, configured by RenderOptions.
+    - OuterHtmlWithOptions
+    - RenderWithOptions
+
+* source.go : rendering of the documents created by
+NewDocumentFromReaderWithSource from their source.
+    - RenderOptions.PreserveSource
+
+* stream.go : matching of the elements of a document while it is read.
+    - Stream()
+
+* template.go : content of the templates and declarative shadow roots.
+    - ShadowRoot()
+    - TemplateContent()
+
+* text.go : methods that operate on the text of the selection's nodes.
+    - ReplaceText...()
+    - WrapText()
+
+* textmap.go : mapping of offsets in the selection's text to text nodes.
+    - TextMap
+
 * traversal.go : methods to traverse the HTML document tree.
     - Children...()
+    - CommonAncestor()
     - Contents()
     - Find...()
     - Next...()
@@ -119,5 +196,20 @@ The three dots (...) indicate that various "overloads" are available.
 that are not part of jQuery, but are useful to goquery.
     - NodeName
     - OuterHtml
+
+* xml.go : documents parsed from XML, such as feeds or SVG images.
+    - NewXMLDocumentFromReader()
+
+The selectors are compiled by cascadia, which lowercases the names of the
+elements and attributes. The selectors with uppercase names, such as pubDate
+or [viewBox], are matched by goquery instead, ignoring the case of the names,
+so that the mixed-case names of the XML documents and of SVG can be selected.
+
+The type and attribute selectors may also have a namespace prefix, such as
+svg|rect or [xlink|href]. The prefixes svg, math, xlink, xml and xmlns are the
+namespaces of the HTML parser, the other prefixes those declared with xmlns
+in XML documents. The empty prefix, as in |a, matches the nodes without a
+namespace, such as the HTML elements, and the * prefix or no prefix matches
+any namespace.
 */
 package goquery
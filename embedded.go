@@ -0,0 +1,132 @@
+package goquery
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// embeddedDocument is a document returned by ContentDocument, with the source
+// it was parsed from.
+type embeddedDocument struct {
+	src string
+	doc *Document
+	err error
+}
+
+// ContentDocument returns the document embedded in the first node of the
+// Selection, parsed as a separate Document:
+//
+//   - the document of the srcdoc attribute of an iframe element;
+//   - the content of a noscript element, which the parser keeps as text
+//     unless the document is parsed with the DisableScripting option, parsed
+//     as with scripting disabled, as a browser would render it.
+//
+// It returns nil if the node has no such document, or if it cannot be parsed,
+// as reported by ParseContentDocument. The embedded document is parsed as a
+// full document with the options of the document of the Selection, except
+// for Context, and is linked to it by ParentDocument and FrameElement. The
+// same Document is returned as long as its source is unchanged, but changes
+// made to it are not reflected in its source, e.g. the srcdoc attribute.
+func (s *Selection) ContentDocument() *Document {
+	d, _ := s.ParseContentDocument()
+	return d
+}
+
+// ParseContentDocument is like ContentDocument, but it returns the error of
+// the parsing of the embedded document, such as a *LimitError if it exceeds
+// one of the limits of the options of the document of the Selection. It
+// returns nil and no error if the node has no embedded document.
+func (s *Selection) ParseContentDocument() (*Document, error) {
+	if len(s.Nodes) == 0 {
+		return nil, nil
+	}
+	n := s.Nodes[0]
+	src, scripting, ok := embeddedSource(n)
+	if !ok {
+		return nil, nil
+	}
+
+	parent := s.document
+	if parent != nil {
+		parent.embeddedMu.Lock()
+		e := parent.embedded[n]
+		parent.embeddedMu.Unlock()
+		if e != nil && e.src == src {
+			return e.doc, e.err
+		}
+	}
+
+	var opts DocumentOptions
+	if parent != nil {
+		opts = parent.options
+		opts.DocumentOrder, opts.Composed = parent.DocumentOrder, parent.Composed
+	}
+	// the embedded documents are full documents, with their own positions
+	opts.Context = ""
+	opts.DisableScripting = opts.DisableScripting || !scripting
+	d, err := NewDocumentFromReaderWithOptions(strings.NewReader(src), opts)
+	if parent == nil {
+		return d, err
+	}
+
+	if d != nil {
+		d.Url, d.parent, d.frame = parent.Url, parent, n
+	}
+	// the document is parsed without the lock, and the document of a
+	// concurrent call that stored it first is returned instead
+	parent.embeddedMu.Lock()
+	defer parent.embeddedMu.Unlock()
+	if e := parent.embedded[n]; e != nil && e.src == src {
+		return e.doc, e.err
+	}
+	if parent.embedded == nil {
+		parent.embedded = make(map[*html.Node]*embeddedDocument)
+	}
+	parent.embedded[n] = &embeddedDocument{src, d, err}
+	return d, err
+}
+
+// ParentDocument returns the document that the document is embedded in, if it
+// was returned by ContentDocument, or nil.
+func (d *Document) ParentDocument() *Document {
+	return d.parent
+}
+
+// FrameElement returns the element of the parent document that the document
+// is embedded in, if it was returned by ContentDocument, or an empty
+// Selection.
+func (d *Document) FrameElement() *Selection {
+	if d.parent == nil {
+		return newEmptySelection(d)
+	}
+	return newSingleSelection(d.frame, d.parent)
+}
+
+// embeddedSource returns the source of the document embedded in n, and whether
+// it is parsed with scripting enabled.
+func embeddedSource(n *html.Node) (string, bool, bool) {
+	if n.Type != html.ElementNode || n.Namespace != "" {
+		return "", false, false
+	}
+	switch n.DataAtom {
+	case atom.Iframe:
+		if src, ok := attrValue(n, "srcdoc"); ok {
+			return src, true, true
+		}
+	case atom.Noscript:
+		// the content is only kept as text with scripting enabled
+		var sb strings.Builder
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.TextNode {
+				return "", false, false
+			}
+			sb.WriteString(c.Data)
+		}
+		if n.FirstChild != nil {
+			return sb.String(), false, true
+		}
+	}
+	return "", false, false
+}
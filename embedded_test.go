@@ -0,0 +1,140 @@
+package goquery
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestContentDocumentSrcdoc(t *testing.T) {
+	doc := loadString(t, `<p>outer</p><iframe srcdoc="<p class=&quot;in&quot;>inner &amp; more</p>"></iframe><iframe src="x.html"></iframe>`)
+	frame := doc.Find("iframe").First()
+	sub := frame.ContentDocument()
+	if sub == nil {
+		t.Fatal("want a content document")
+	}
+	if got := sub.Find("p.in").Text(); got != "inner & more" {
+		t.Errorf("want the srcdoc content, got %q", got)
+	}
+	if got := doc.Find("p").Length(); got != 1 {
+		t.Errorf("want the srcdoc content out of the parent, got %d", got)
+	}
+	if sub.ParentDocument() != doc || sub.FrameElement().Nodes[0] != frame.Nodes[0] {
+		t.Error("want the content document linked to its parent")
+	}
+
+	// the same document is returned until the srcdoc changes
+	sub.Find("p").SetText("changed")
+	if got := frame.ContentDocument(); got != sub {
+		t.Error("want the same content document")
+	}
+	frame.SetAttr("srcdoc", "<b>new</b>")
+	if got := frame.ContentDocument().Find("b").Text(); got != "new" {
+		t.Errorf("want the new srcdoc content, got %q", got)
+	}
+
+	if doc.Find("iframe").Last().ContentDocument() != nil || doc.Find("p").ContentDocument() != nil {
+		t.Error("want no content document")
+	}
+	if doc.ParentDocument() != nil || doc.FrameElement().Length() != 0 {
+		t.Error("want no parent document")
+	}
+}
+
+func TestContentDocumentConcurrent(t *testing.T) {
+	doc := loadString(t, `<iframe srcdoc="<p>a</p>"></iframe><iframe srcdoc="<p>b</p>"></iframe>`)
+	frames := doc.Find("iframe")
+
+	var wg sync.WaitGroup
+	docs := make([]*Document, 8)
+	for i := range docs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			docs[i] = frames.Eq(i % 2).ContentDocument()
+		}()
+	}
+	wg.Wait()
+	for i, d := range docs {
+		if d != docs[i%2] {
+			t.Errorf("%d: want the cached content document", i)
+		}
+	}
+}
+
+func TestContentDocumentNoscript(t *testing.T) {
+	src := `<head><noscript><link rel="stylesheet" href="a.css"></noscript></head><body><noscript><img src="a.png"></noscript></body>`
+	doc := loadString(t, src)
+	if got := doc.Find("img").Length(); got != 0 {
+		t.Errorf("want the noscript content kept as text, got %d", got)
+	}
+	sub := doc.Find("body noscript").ContentDocument()
+	if sub == nil {
+		t.Fatal("want a content document")
+	}
+	if got, _ := sub.Find("img").Attr("src"); got != "a.png" {
+		t.Errorf("want the noscript content, got %q", got)
+	}
+	if sub.Find("noscript").Length() != 0 || doc.Find("head noscript").ContentDocument().Find("link").Length() != 1 {
+		t.Error("want the content of the noscript elements")
+	}
+
+	// with scripting disabled, the content is parsed in place
+	doc, err := NewDocumentFromReaderWithOptions(strings.NewReader(src), DocumentOptions{DisableScripting: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := doc.Find("noscript img, noscript link").Length(); got != 2 {
+		t.Errorf("want the noscript content parsed as markup, got %d", got)
+	}
+	if doc.Find("noscript").ContentDocument() != nil {
+		t.Error("want no content document")
+	}
+}
+
+func TestDisableScriptingPositions(t *testing.T) {
+	src := `<body><noscript><p>a</p></noscript><p>b</p></body>`
+	doc, err := NewDocumentFromReaderWithOptions(strings.NewReader(src), DocumentOptions{DisableScripting: true, Positions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := doc.Find("noscript p").Position()
+	if got := src[p.Offset:p.EndOffset]; got != "<p>a</p>" {
+		t.Errorf("want the span of the paragraph, got %q", got)
+	}
+	doc.Find("p").Last().SetText("c")
+	if got := renderSource(t, doc.Selection); got != strings.Replace(src, "<p>b</p>", "<p>c</p>", 1) {
+		t.Errorf("want the source preserved, got %q", got)
+	}
+}
+
+func TestContentDocumentOptions(t *testing.T) {
+	src := `<iframe srcdoc="<p>a</p><p>b</p>"></iframe>`
+	doc, err := NewDocumentFromReaderWithOptions(strings.NewReader(src), DocumentOptions{Context: "body"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the srcdoc is a full document, not a fragment in the context
+	sub := doc.Find("iframe").ContentDocument()
+	if sub == nil || sub.Find("html > body > p").Length() != 2 {
+		t.Error("want the srcdoc parsed as a full document")
+	}
+
+	doc, err = NewDocumentFromReaderWithOptions(strings.NewReader(src), DocumentOptions{MaxNodes: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := doc.Find("iframe")
+	sub, err = frame.ParseContentDocument()
+	var lerr *LimitError
+	if sub != nil || !errors.As(err, &lerr) || lerr.Limit != LimitNodes {
+		t.Errorf("want a MaxNodes error, got %v", err)
+	}
+	if frame.ContentDocument() != nil {
+		t.Error("want no content document")
+	}
+	if sub, err = doc.Find("p").ParseContentDocument(); sub != nil || err != nil {
+		t.Errorf("want no content document and no error, got %v", err)
+	}
+}
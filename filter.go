@@ -88,18 +88,9 @@ func (s *Selection) Has(selector string) *Selection {
 func (s *Selection) HasMatcher(m Matcher) *Selection {
 	result := make([]*html.Node, 0, len(s.Nodes))
 
-	// Manually create a singleMatcher (rather than using SingleMatcher, which
-	// returns a Matcher interface) so we can call its MatchFirst method
-	// directly. MatchFirst probes for the first match in a subtree without
-	// building a result slice, avoiding a throwaway one-element slice
-	// allocation for every matching child subtree.
-	sm := singleMatcher{m}
 	for _, n := range s.Nodes {
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			if c.Type == html.ElementNode && sm.MatchFirst(c) != nil {
-				result = append(result, n)
-				break
-			}
+		if firstDescendant(n, m, s.composed()) != nil {
+			result = append(result, n)
 		}
 	}
 	return pushStack(s, result)
@@ -0,0 +1,407 @@
+package goquery
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// NewFragment parses htmlStr as an HTML fragment in the context of an element
+// of the contextTag name, or of a body element if contextTag is empty, as
+// AppendHtml would parse it in such an element. It returns a Selection with
+// the parsed nodes, which are the children of the root node of a new
+// Document. The root Document is returned by End.
+func NewFragment(htmlStr, contextTag string) *Selection {
+	if contextTag == "" {
+		contextTag = "body"
+	}
+	// without limits, the parsing of a string cannot fail
+	d, _ := NewDocumentFromReaderWithOptions(strings.NewReader(htmlStr), DocumentOptions{Context: contextTag})
+	return d.Contents()
+}
+
+// HtmlOptions configures the parsing of the HTML given to the
+// *HtmlWithOptions manipulation methods, such as AppendHtmlWithOptions.
+type HtmlOptions struct {
+	// Strict rejects the HTML that the parser had to restructure to fit in
+	// its context, instead of inserting the restructured nodes. This
+	// includes misnested tags, end tags without a matching start tag,
+	// elements that are not allowed in the context (e.g. a td outside of a
+	// table) and content moved out of tables (foster parenting). Omitted end
+	// tags and implied table elements such as tbody are accepted.
+	Strict bool
+}
+
+// HtmlParseError is the error returned by the *HtmlWithOptions manipulation
+// methods when the HTML cannot be inserted as requested.
+type HtmlParseError struct {
+	// Html is the HTML that was parsed.
+	Html string
+	// Context is the name of the node in the context of which the HTML was
+	// parsed, as returned by NodeName.
+	Context string
+	// Offset is the byte offset in Html of the token that caused the error,
+	// or -1 if the error does not relate to a specific token.
+	Offset int
+	// Reason describes the error.
+	Reason string
+}
+
+// Error returns the description of the error.
+func (e *HtmlParseError) Error() string {
+	if e.Offset >= 0 {
+		return fmt.Sprintf("goquery: invalid HTML in %s context at offset %d: %s", e.Context, e.Offset, e.Reason)
+	}
+	return fmt.Sprintf("goquery: invalid HTML in %s context: %s", e.Context, e.Reason)
+}
+
+// htmlFragment is an HTML string to be parsed in the context of the nodes of
+// a selection, with the results cached by context name and namespace.
+type htmlFragment struct {
+	html string
+
+	// xml indicates that the fragment is inserted in an XML document, and
+	// is parsed as XML.
+	xml bool
+
+	// checked indicates that the parsed nodes must be validated, with opts.
+	// The fragments of the unchecked manipulation methods are never
+	// validated.
+	checked bool
+	opts    HtmlOptions
+
+	// wrap indicates that the fragment is used to wrap nodes, so it must
+	// contain an element.
+	wrap bool
+
+	cache map[string]parsedFragment
+}
+
+type parsedFragment struct {
+	nodes []*html.Node
+	err   error
+}
+
+// newHtmlFragment returns the fragment of htmlStr to be inserted in the
+// document of s.
+func (s *Selection) newHtmlFragment(htmlStr string, wrap bool) *htmlFragment {
+	return &htmlFragment{html: htmlStr, xml: s.isXML(), wrap: wrap, cache: make(map[string]parsedFragment)}
+}
+
+// withOptions enables the validation of the parsed nodes.
+func (f *htmlFragment) withOptions(opts HtmlOptions) *htmlFragment {
+	f.checked, f.opts = true, opts
+	return f
+}
+
+// htmlContextFunc returns the node in the context of which the HTML inserted
+// relative to n is parsed, or nil if n is skipped.
+type htmlContextFunc func(n *html.Node) *html.Node
+
+// parentContext parses the HTML in the context of the node's parent, for the
+// HTML inserted as a sibling of the node.
+func parentContext(n *html.Node) *html.Node {
+	return n.Parent
+}
+
+// elementContext parses the HTML in the context of the node, for the HTML
+// inserted as children of an element.
+func elementContext(n *html.Node) *html.Node {
+	if n.Type != html.ElementNode {
+		return nil
+	}
+	return n
+}
+
+// nodeContext parses the HTML in the context of the node, whatever its type.
+func nodeContext(n *html.Node) *html.Node {
+	return n
+}
+
+// wrapContext parses the HTML in the context of the node's parent, or of an
+// anonymous element if it has none.
+func wrapContext(n *html.Node) *html.Node {
+	if n.Parent == nil {
+		return &html.Node{Type: html.ElementNode}
+	}
+	return n.Parent
+}
+
+// parse returns the nodes parsed from the fragment in the given context. The
+// nodes are returned even if there is an error, so that the unchecked
+// manipulation methods behave as if no check was made.
+func (f *htmlFragment) parse(context *html.Node) ([]*html.Node, error) {
+	key := nodeName(context)
+	if context.Namespace != "" {
+		// e.g. the content of the title element of SVG is not parsed as in HTML
+		key = context.Namespace + " " + key
+	}
+	if p, ok := f.cache[key]; ok {
+		return p.nodes, p.err
+	}
+	var nodes []*html.Node
+	var err error
+	if f.xml {
+		// the nodes of an XML fragment are never restructured, it is either
+		// well-formed or rejected, even by the unchecked methods
+		var off int
+		if nodes, off, err = parseXMLFragment(f.html, context); err != nil {
+			err = &HtmlParseError{Html: f.html, Context: nodeName(context), Offset: off, Reason: err.Error()}
+		}
+	} else {
+		nodes = parseHtmlWithContext(f.html, context)
+	}
+	if f.checked && err == nil {
+		err = f.validate(nodes, context)
+	}
+	f.cache[key] = parsedFragment{nodes, err}
+	return nodes, err
+}
+
+// check parses the fragment in the context of each of the nodes, and returns
+// the first error.
+func (f *htmlFragment) check(nodes []*html.Node, context htmlContextFunc) error {
+	for _, n := range nodes {
+		if ctx := context(n); ctx != nil {
+			if _, err := f.parse(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *htmlFragment) validate(nodes []*html.Node, context *html.Node) error {
+	newErr := func(offset int, reason string, args ...any) error {
+		return &HtmlParseError{
+			Html:    f.html,
+			Context: nodeName(context),
+			Offset:  offset,
+			Reason:  fmt.Sprintf(reason, args...),
+		}
+	}
+
+	if len(nodes) == 0 && f.html != "" {
+		return newErr(-1, "no node could be parsed")
+	}
+	if f.wrap && getFirstElement(nodes) == nil {
+		return newErr(-1, "no element to wrap with")
+	}
+	if f.opts.Strict && !f.xml {
+		if off, reason := strictCheck(f.html, nodes, context); reason != "" {
+			return newErr(off, "%s", reason)
+		}
+	}
+	return nil
+}
+
+// isForeignContext returns true if the content of the context node is parsed
+// as foreign content, such as SVG or MathML.
+func isForeignContext(n *html.Node) bool {
+	return n.Namespace != "" && !isHTMLIntegrationPoint(n)
+}
+
+// voidElements are the elements that have no content and no end tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "keygen": true, "link": true,
+	"meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// optionalEndTags are the elements whose end tag may be omitted, and are
+// implicitly closed by the end tag of their parent.
+var optionalEndTags = map[string]bool{
+	"caption": true, "colgroup": true, "dd": true, "dt": true, "li": true,
+	"optgroup": true, "option": true, "p": true, "rb": true, "rp": true,
+	"rt": true, "rtc": true, "tbody": true, "td": true, "tfoot": true,
+	"th": true, "thead": true, "tr": true,
+}
+
+// impliedElements are the elements that the parser inserts in tables when
+// they are omitted from the source.
+var impliedElements = map[string]bool{"colgroup": true, "tbody": true, "tr": true}
+
+// fragmentEvent is an element start, a text or a comment, as found in the
+// source or in the parsed nodes of an HTML fragment.
+type fragmentEvent struct {
+	node   html.NodeType
+	data   string
+	offset int
+}
+
+func (e fragmentEvent) String() string {
+	switch e.node {
+	case html.ElementNode:
+		return "element <" + e.data + ">"
+	case html.TextNode:
+		return fmt.Sprintf("text %q", e.data)
+	}
+	return "comment"
+}
+
+// strictCheck compares the source of an HTML fragment with the nodes parsed
+// from it in the context node, and returns the offset in src and the
+// description of the first difference that is not allowed by the Strict
+// option, if any.
+func strictCheck(src string, nodes []*html.Node, context *html.Node) (int, string) {
+	// the content of the raw text elements, such as textarea or script, is
+	// tokenized as text, as the parser does
+	var contextTag string
+	if context.Type == html.ElementNode && context.Namespace == "" {
+		contextTag = context.Data
+	}
+	tokens, off, reason := tokenizeFragment(src, contextTag, isForeignContext(context))
+	if reason != "" {
+		return off, reason
+	}
+	var tree []fragmentEvent
+	for _, n := range nodes {
+		tree = appendNodeEvents(tree, n)
+	}
+
+	i, j := 0, 0
+	for i < len(tokens) || j < len(tree) {
+		switch {
+		case j < len(tree) && (i == len(tokens) || !tree[j].is(tokens[i])) &&
+			tree[j].node == html.ElementNode && impliedElements[tree[j].data]:
+			j++
+			continue
+		case i == len(tokens):
+			return len(src), tree[j].String() + " was inserted by the parser"
+		case j == len(tree) || indexEvent(tree[j:], tokens[i]) < 0:
+			return tokens[i].offset, tokens[i].String() + " was dropped by the parser"
+		case !tree[j].is(tokens[i]):
+			// tokens[i] comes later in the tree, so tree[j] was either moved
+			// before it or inserted.
+			if k := indexEvent(tokens[i+1:], tree[j]); k >= 0 {
+				return tokens[i+1+k].offset, tree[j].String() + " was moved by the parser"
+			}
+			return tokens[i].offset, tree[j].String() + " was inserted by the parser"
+		}
+		i++
+		j++
+	}
+	return 0, ""
+}
+
+// indexEvent returns the index of the first event of events that is e, or -1.
+func indexEvent(events []fragmentEvent, e fragmentEvent) int {
+	for i, ee := range events {
+		if ee.is(e) {
+			return i
+		}
+	}
+	return -1
+}
+
+// is returns true if e and o are the same event, regardless of offsets.
+func (e fragmentEvent) is(o fragmentEvent) bool {
+	return e.node == o.node && e.data == o.data
+}
+
+// tokenizeFragment returns the events of the source of an HTML fragment, in
+// the context of an HTML element of the tag name contextTag, if not empty,
+// and in foreign content if inForeign is true. It fails on the end tags that
+// don't close an open element, or that close elements whose end tag cannot be
+// omitted.
+func tokenizeFragment(src, contextTag string, inForeign bool) ([]fragmentEvent, int, string) {
+	var events []fragmentEvent
+	var open []string
+	var foreign int
+	if inForeign {
+		foreign = 1
+	}
+	var prev, tt html.TokenType
+	z := html.NewTokenizerFragment(strings.NewReader(src), contextTag)
+	for off := 0; ; {
+		prev, tt = tt, z.Next()
+		start := off
+		off += len(z.Raw())
+
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return events, 0, ""
+			}
+			return nil, start, z.Err().Error()
+
+		case html.TextToken:
+			text := string(z.Text())
+			if prev == html.StartTagToken && len(open) > 0 {
+				// the parser drops the newline that follows those start tags
+				switch open[len(open)-1] {
+				case "pre", "listing", "textarea":
+					text = strings.TrimPrefix(text, "\n")
+				}
+			}
+			if text == "" {
+				continue
+			}
+			if last := len(events) - 1; prev == html.TextToken && last >= 0 && events[last].node == html.TextNode {
+				// consecutive text tokens form a single text node
+				events[last].data += text
+				continue
+			}
+			events = append(events, fragmentEvent{html.TextNode, text, start})
+
+		case html.CommentToken:
+			events = append(events, fragmentEvent{html.CommentNode, string(z.Text()), start})
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			events = append(events, fragmentEvent{html.ElementNode, tag, start})
+			isForeign := tag == "svg" || tag == "math"
+			if voidElements[tag] || (tt == html.SelfClosingTagToken && (foreign > 0 || isForeign)) {
+				continue
+			}
+			open = append(open, tag)
+			if isForeign {
+				foreign++
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			tag := string(name)
+			i := len(open) - 1
+			for i >= 0 && open[i] != tag {
+				i--
+			}
+			if i < 0 {
+				return nil, start, "unexpected end tag </" + tag + ">"
+			}
+			for _, o := range open[i+1:] {
+				if !optionalEndTags[o] {
+					return nil, start, "misnested end tag </" + tag + ">, <" + o + "> is not closed"
+				}
+			}
+			for _, o := range open[i:] {
+				if o == "svg" || o == "math" {
+					foreign--
+				}
+			}
+			open = open[:i]
+
+		case html.DoctypeToken:
+			return nil, start, "unexpected doctype"
+		}
+	}
+}
+
+// appendNodeEvents appends the events of n and its descendants to events.
+func appendNodeEvents(events []fragmentEvent, n *html.Node) []fragmentEvent {
+	switch n.Type {
+	case html.ElementNode:
+		events = append(events, fragmentEvent{node: html.ElementNode, data: strings.ToLower(n.Data)})
+	case html.TextNode:
+		events = append(events, fragmentEvent{node: html.TextNode, data: n.Data})
+	case html.CommentNode:
+		events = append(events, fragmentEvent{node: html.CommentNode, data: n.Data})
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		events = appendNodeEvents(events, c)
+	}
+	return events
+}
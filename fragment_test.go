@@ -0,0 +1,231 @@
+package goquery
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+func TestAppendHtmlWithOptions(t *testing.T) {
+	doc := Doc2Clone()
+	sel, err := doc.Find("#main").AppendHtmlWithOptions("<p>new</p>", HtmlOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLength(t, sel.Nodes, 1)
+	assertLength(t, doc.Find("#main > p").Nodes, 1)
+}
+
+func TestWrapHtmlWithOptionsNoElement(t *testing.T) {
+	doc := Doc2Clone()
+	before, _ := doc.Html()
+
+	_, err := doc.Find("#nf1").WrapHtmlWithOptions("just text", HtmlOptions{})
+	var perr *HtmlParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("want an HtmlParseError, got %v", err)
+	}
+	if perr.Context != "div" || perr.Offset != -1 {
+		t.Errorf("unexpected error %+v", perr)
+	}
+	if after, _ := doc.Html(); after != before {
+		t.Error("want document unchanged")
+	}
+
+	// the unchecked variant still ignores it
+	doc.Find("#nf1").WrapHtml("just text")
+}
+
+func TestHtmlWithOptionsNoNode(t *testing.T) {
+	doc := Doc2Clone()
+	if _, err := doc.Find("#main").AppendHtmlWithOptions("</div>", HtmlOptions{}); err == nil {
+		t.Error("want an error for a stray end tag")
+	}
+	if _, err := doc.Find("#main").AppendHtmlWithOptions("", HtmlOptions{}); err != nil {
+		t.Errorf("want no error for empty html, got %v", err)
+	}
+}
+
+func TestHtmlWithOptionsStrict(t *testing.T) {
+	cases := []struct {
+		html   string
+		offset int // -1 if valid
+	}{
+		{`<p>a <b>b</b></p>`, -1},
+		{`<ul><li>a<li>b</ul>`, -1},
+		{`<div class="x">`, -1},
+		{`<br><img src="a"><hr/>`, -1},
+		{`<table><tr><td>a</td></tr></table>`, -1},
+		{`<table><td>a</table>`, -1},
+		{`<pre>` + "\n" + `text</pre>`, -1},
+		{`<svg><circle r="1"/></svg>`, -1},
+		{`a &amp; b<!-- c -->`, -1},
+		{`<b><i>x</b></i>`, 7},
+		{`<p>a</p></div>`, 8},
+		{`<table><tr><td>a</td></tr>oops</table>`, 26},
+		{`<td>a</td>`, 0},
+		{`<p>a<div>b</div></p>`, 20},
+	}
+	for _, c := range cases {
+		doc := Doc2Clone()
+		before, _ := doc.Html()
+		_, err := doc.Find("#main").AppendHtmlWithOptions(c.html, HtmlOptions{Strict: true})
+		if c.offset < 0 {
+			if err != nil {
+				t.Errorf("%s: want no error, got %v", c.html, err)
+			}
+			continue
+		}
+		var perr *HtmlParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("%s: want an HtmlParseError, got %v", c.html, err)
+			continue
+		}
+		if perr.Offset != c.offset {
+			t.Errorf("%s: want offset %d, got %d (%v)", c.html, c.offset, perr.Offset, err)
+		}
+		if after, _ := doc.Html(); after != before {
+			t.Errorf("%s: want document unchanged", c.html)
+		}
+	}
+}
+
+func TestSetHtmlWithOptionsStrictContext(t *testing.T) {
+	doc := loadString(t, `<html><body><table><tbody id="tb"></tbody></table><div id="d"></div></body></html>`)
+	if _, err := doc.Find("#tb").SetHtmlWithOptions("<tr><td>a</td></tr>", HtmlOptions{Strict: true}); err != nil {
+		t.Errorf("want rows to be valid in a tbody, got %v", err)
+	}
+	if _, err := doc.Find("#d").SetHtmlWithOptions("<tr><td>a</td></tr>", HtmlOptions{Strict: true}); err == nil {
+		t.Error("want rows to be rejected in a div")
+	}
+	assertLength(t, doc.Find("#tb td").Nodes, 1)
+}
+
+func TestAppendHtmlWithOptionsStrictRawText(t *testing.T) {
+	doc := loadString(t, `<html><head><title></title><script></script></head><body><textarea></textarea></body></html>`)
+	cases := []struct {
+		sel, html string
+	}{
+		{"textarea", "a <b> c"},
+		{"title", "a <b> c &amp; d"},
+		{"script", "if (a <b> c) {}</p>"},
+	}
+	for _, c := range cases {
+		sel := doc.Find(c.sel)
+		if _, err := sel.AppendHtmlWithOptions(c.html, HtmlOptions{Strict: true}); err != nil {
+			t.Errorf("%s: want no error, got %v", c.sel, err)
+		}
+		if got := sel.Children().Length(); got != 0 {
+			t.Errorf("%s: want the content kept as text, got %d elements", c.sel, got)
+		}
+	}
+}
+
+func TestReplaceWithHtmlWithOptions(t *testing.T) {
+	doc := Doc2Clone()
+	sel, err := doc.Find("#nf1").ReplaceWithHtmlWithOptions("<span id='r'></span>", HtmlOptions{Strict: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLength(t, sel.Nodes, 1)
+	assertLength(t, doc.Find("#nf1").Nodes, 0)
+	assertLength(t, doc.Find("#r").Nodes, 1)
+}
+
+func TestWrapAllHtmlWithOptions(t *testing.T) {
+	doc := Doc2Clone()
+	if _, err := doc.Find(".odd").WrapAllHtmlWithOptions("<div id='w'><p></p></div>", HtmlOptions{Strict: true}); err != nil {
+		t.Fatal(err)
+	}
+	assertLength(t, doc.Find("#w p .odd").Nodes, doc.Find(".odd").Length())
+
+	if _, err := doc.Find(".odd").WrapInnerHtmlWithOptions("", HtmlOptions{}); err == nil {
+		t.Error("want an error for empty wrapper html")
+	}
+}
+
+func TestNewFragment(t *testing.T) {
+	sel := NewFragment(`<p>a</p>text<p>b`, "")
+	assertLength(t, sel.Nodes, 3)
+	if got := sel.Filter("p").Text(); got != "ab" {
+		t.Errorf("want %q, got %q", "ab", got)
+	}
+	if sel.document.rootNode != sel.Nodes[0].Parent {
+		t.Error("want the nodes to be children of the root of the document")
+	}
+	if sel.End() != sel.document.Selection {
+		t.Error("want End to return the document")
+	}
+
+	sel = NewFragment(`<tr><td>a</td></tr>`, "table")
+	if got := NodeName(sel); got != "tbody" {
+		t.Errorf("want %q, got %q", "tbody", got)
+	}
+	assertLength(t, sel.Find("td").Nodes, 1)
+}
+
+func TestHtmlForeignContent(t *testing.T) {
+	doc := loadString(t, `<svg><g></g><foreignObject></foreignObject></svg><math></math>`)
+	doc.Find("svg").AppendHtml(`<rect/><linearGradient/><a xlink:href="#x"><circle/></a>`)
+	doc.Find("g").AfterHtml(`<circle/>`)
+	doc.Find("foreignObject").AppendHtml(`<p>a</p>`)
+	doc.Find("math").AppendHtml(`<mi>x</mi>`)
+
+	cases := []struct {
+		sel string
+		ns  string
+	}{
+		{"svg > rect", "svg"},
+		{"svg > linearGradient", "svg"},
+		{"svg > a > circle", "svg"},
+		{"g + circle", "svg"},
+		{"foreignObject > p", ""},
+		{"math > mi", "math"},
+	}
+	for _, c := range cases {
+		sel := doc.Find(c.sel)
+		if sel.Length() != 1 || sel.Nodes[0].Namespace != c.ns {
+			t.Errorf("%s: want 1 node in the namespace %q, got %v", c.sel, c.ns, sel.Nodes)
+		}
+	}
+	if got, _ := doc.Find("svg > a").AttrNS("xlink", "href"); got != "#x" {
+		t.Errorf("want xlink:href %q, got %q", "#x", got)
+	}
+
+	// the clones keep their namespace
+	clone := doc.Find("svg > rect").Clone()
+	if clone.Nodes[0].Namespace != "svg" {
+		t.Errorf("want the clone in the svg namespace, got %q", clone.Nodes[0].Namespace)
+	}
+
+	// self-closing tags are allowed in foreign content
+	if _, err := doc.Find("g").AppendHtmlWithOptions(`<g><path/></g>`, HtmlOptions{Strict: true}); err != nil {
+		t.Errorf("want no error, got %v", err)
+	}
+	if got := doc.Find("g > g > path").Length(); got != 1 {
+		t.Errorf("want the nested path, got %d", got)
+	}
+}
+
+func TestNewFragmentForeignContent(t *testing.T) {
+	sel := NewFragment(`<circle r="1"/><foreignObject><p>a</p></foreignObject>`, "svg")
+	assertLength(t, sel.Nodes, 2)
+	if sel.Nodes[0].Namespace != "svg" || sel.Nodes[1].Data != "foreignObject" {
+		t.Errorf("want svg elements, got %v", sel.Nodes)
+	}
+	if p := sel.Find("p"); p.Length() != 1 || p.Nodes[0].Namespace != "" {
+		t.Errorf("want an HTML p, got %v", p.Nodes)
+	}
+}
+
+func TestHtmlContextWithoutAtom(t *testing.T) {
+	// a context built by hand, whose DataAtom is not set
+	root := &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div}
+	root.AppendChild(&html.Node{Type: html.ElementNode, Data: "title"})
+	sel := NewDocumentFromNode(root).Find("title").AppendHtml("<b>x</b>")
+	if got, _ := sel.Html(); got != "&lt;b&gt;x&lt;/b&gt;" {
+		t.Errorf("want the content parsed as the text of a title, got %q", got)
+	}
+}
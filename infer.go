@@ -0,0 +1,395 @@
+package goquery
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ErrNoSelector is returned by InferSelector when no selector matches all the
+// positive examples and none of the negative ones.
+var ErrNoSelector = errors.New("goquery: no selector matches all the positives and none of the negatives")
+
+// InferredSelector is a selector inferred from examples, as returned by
+// InferSelectorWithConfidence.
+type InferredSelector struct {
+	// Selector matches all the positive examples and none of the negative
+	// ones.
+	Selector string
+
+	// Confidence is a score between 0 and 1 of how likely the selector is to
+	// match the nodes that the examples stand for, and only them. It
+	// increases with the number of examples, and is lower for the selectors
+	// that rely on tag names and positions than for those that rely on
+	// classes and attributes.
+	Confidence float64
+}
+
+// maxInferredFeatures is the maximum number of features of the selectors
+// tried by InferSelector.
+const maxInferredFeatures = 4
+
+// maxCommonFeatures is the maximum number of the features of the positive
+// elements, and of their parents and ancestors, that are combined by
+// InferSelector, and maxInferredCandidates the maximum number of selectors
+// it tries, which bound the number of combinations.
+const (
+	maxCommonFeatures     = 16
+	maxInferredCandidates = 10000
+)
+
+// InferSelector returns a short CSS selector that matches all the elements
+// of the positives and none of the elements of the negatives, in their
+// documents. The selector is built from the tag names, classes, attributes
+// and positions that the positive elements have in common, and those of
+// their parents and ancestors, and is verified with Find. It returns
+// ErrNoSelector if there is no such selector.
+func InferSelector(positives, negatives []*Selection) (string, error) {
+	inferred, err := InferSelectorWithConfidence(positives, negatives)
+	return inferred.Selector, err
+}
+
+// InferSelectorWithConfidence is like InferSelector, but it also returns the
+// confidence score of the selector.
+func InferSelectorWithConfidence(positives, negatives []*Selection) (InferredSelector, error) {
+	pos, neg := inferElements(positives), inferElements(negatives)
+	if len(pos) == 0 {
+		return InferredSelector{}, errors.New("goquery: no positive element to infer a selector from")
+	}
+
+	own := limitFeatures(commonFeatures(pos, elementFeatures))
+	var contexts []selectorFeature
+	for _, f := range commonFeatures(pos, parentFeatures) {
+		f.css += " > "
+		contexts = append(contexts, f)
+	}
+	for _, f := range commonFeatures(pos, ancestorFeatures) {
+		f.css += " "
+		contexts = append(contexts, f)
+	}
+	contexts = limitFeatures(contexts)
+
+	roots := make(map[*html.Node]bool)
+	for _, n := range append(append([]*html.Node(nil), pos...), neg...) {
+		roots[rootNode(n)] = true
+	}
+	inPos, inNeg := make(map[*html.Node]bool), make(map[*html.Node]bool)
+	for _, n := range pos {
+		inPos[n] = true
+	}
+	for _, n := range neg {
+		inNeg[n] = true
+	}
+
+	// the candidates are tried by increasing number of features, and the
+	// best of the first size with valid candidates is kept
+	tried := 0
+	for size := 1; size <= maxInferredFeatures; size++ {
+		var best *inferCandidate
+		try := func(features []selectorFeature) bool {
+			if tried++; tried > maxInferredCandidates {
+				return false
+			}
+			c := newInferCandidate(features)
+			if best != nil && c.weight < best.weight {
+				return true
+			}
+
+			// the examples are matched first, which rejects most of the
+			// candidates without searching the documents
+			m := compileMatcher(c.selector)
+			for _, n := range pos {
+				if !m.Match(n) {
+					return true
+				}
+			}
+			for _, n := range neg {
+				if m.Match(n) {
+					return true
+				}
+			}
+			for root := range roots {
+				for _, n := range newSingleSelection(root, nil).FindMatcher(m).Nodes {
+					if inNeg[n] {
+						return true
+					}
+					if inPos[n] {
+						c.positives++
+					}
+					c.matches++
+				}
+			}
+			if c.positives == len(pos) && (best == nil || c.better(best)) {
+				best = c
+			}
+			return true
+		}
+
+		more := combineFeatures(own, size, try)
+		for _, ctx := range contexts {
+			if !more {
+				break
+			}
+			more = combineFeatures(own, size-1, func(features []selectorFeature) bool {
+				return try(append([]selectorFeature{ctx}, features...))
+			})
+		}
+		if best != nil {
+			examples := float64(len(pos)+len(neg)) / float64(len(pos)+len(neg)+1)
+			return InferredSelector{Selector: best.selector, Confidence: examples * best.weight}, nil
+		}
+	}
+	return InferredSelector{}, ErrNoSelector
+}
+
+// selectorFeature is a part of a selector, with the kind of feature it tests,
+// which determines its order in a compound selector and its weight.
+type selectorFeature struct {
+	css  string
+	kind int
+}
+
+// The kinds of features, in the order they appear in a compound selector.
+const (
+	featureTag = iota
+	featureID
+	featureClass
+	featureAttr
+	featureAttrPresence
+	featurePosition
+)
+
+// featureWeights are the weights of the kinds of features in the confidence
+// of a selector.
+var featureWeights = []float64{
+	featureTag:          0.5,
+	featureID:           1,
+	featureClass:        1,
+	featureAttr:         1,
+	featureAttrPresence: 0.7,
+	featurePosition:     0.3,
+}
+
+// inferCandidate is a selector tried by InferSelector.
+type inferCandidate struct {
+	selector string
+	weight   float64
+
+	// positives is the number of positive elements that the selector
+	// matches, and matches the number of all the elements it matches.
+	positives, matches int
+}
+
+func newInferCandidate(features []selectorFeature) *inferCandidate {
+	var sb strings.Builder
+	weight := 0.0
+	for _, f := range features {
+		sb.WriteString(f.css)
+		weight += featureWeights[f.kind]
+	}
+	return &inferCandidate{selector: sb.String(), weight: weight / float64(len(features))}
+}
+
+// better returns true if c is preferred to o, which has as many features: it
+// has stronger features or, failing that, matches fewer elements.
+func (c *inferCandidate) better(o *inferCandidate) bool {
+	if c.weight != o.weight {
+		return c.weight > o.weight
+	}
+	if c.matches != o.matches {
+		return c.matches < o.matches
+	}
+	return len(c.selector) < len(o.selector)
+}
+
+// combineFeatures calls f with each combination of size features, in the order
+// of their kinds, until f returns false. A combination has at most one tag
+// name, and does not only test positions. It returns false if f returned
+// false.
+func combineFeatures(features []selectorFeature, size int, f func([]selectorFeature) bool) bool {
+	if size == 0 {
+		return true
+	}
+	combo := make([]selectorFeature, 0, size)
+	var rec func(start int) bool
+	rec = func(start int) bool {
+		if len(combo) == size {
+			if combo[0].kind != featurePosition {
+				return f(append([]selectorFeature(nil), combo...))
+			}
+			return true
+		}
+		for i := start; i < len(features); i++ {
+			if features[i].kind == featureTag && len(combo) > 0 {
+				continue
+			}
+			combo = append(combo, features[i])
+			more := rec(i + 1)
+			combo = combo[:len(combo)-1]
+			if !more {
+				return false
+			}
+		}
+		return true
+	}
+	return rec(0)
+}
+
+// limitFeatures returns the first maxCommonFeatures features, keeping the
+// positions, which are sorted last but needed to tell siblings apart.
+func limitFeatures(features []selectorFeature) []selectorFeature {
+	if len(features) <= maxCommonFeatures {
+		return features
+	}
+	var kept, positions []selectorFeature
+	for _, f := range features {
+		if f.kind == featurePosition {
+			positions = append(positions, f)
+		} else {
+			kept = append(kept, f)
+		}
+	}
+	if len(positions) > maxCommonFeatures {
+		positions = positions[:maxCommonFeatures]
+	}
+	if n := maxCommonFeatures - len(positions); len(kept) > n {
+		kept = kept[:n]
+	}
+	return append(kept, positions...)
+}
+
+// commonFeatures returns the features that all the nodes have, sorted by kind.
+func commonFeatures(nodes []*html.Node, features func(*html.Node) []selectorFeature) []selectorFeature {
+	common := features(nodes[0])
+	for _, n := range nodes[1:] {
+		has := make(map[selectorFeature]bool)
+		for _, f := range features(n) {
+			has[f] = true
+		}
+		kept := common[:0]
+		for _, f := range common {
+			if has[f] {
+				kept = append(kept, f)
+			}
+		}
+		common = kept
+	}
+	sort.SliceStable(common, func(i, j int) bool { return common[i].kind < common[j].kind })
+	return common
+}
+
+// elementFeatures returns the features of the element n.
+func elementFeatures(n *html.Node) []selectorFeature {
+	features := []selectorFeature{{cssIdent(n.Data), featureTag}}
+	for _, a := range n.Attr {
+		if a.Namespace != "" {
+			continue
+		}
+		switch a.Key {
+		case "id":
+			if a.Val != "" {
+				features = append(features, selectorFeature{"#" + cssIdent(a.Val), featureID})
+			}
+		case "class":
+			seen := make(map[string]bool)
+			for _, class := range strings.Fields(a.Val) {
+				if !seen[class] {
+					seen[class] = true
+					features = append(features, selectorFeature{"." + cssIdent(class), featureClass})
+				}
+			}
+		case "style":
+		default:
+			if len(a.Val) <= 64 {
+				features = append(features, selectorFeature{"[" + cssIdent(a.Key) + "=" + cssString(a.Val) + "]", featureAttr})
+			}
+			features = append(features, selectorFeature{"[" + cssIdent(a.Key) + "]", featureAttrPresence})
+		}
+	}
+
+	k, last := 1, true
+	for sib := n.PrevSibling; sib != nil; sib = sib.PrevSibling {
+		if sib.Type == html.ElementNode {
+			k++
+		}
+	}
+	for sib := n.NextSibling; sib != nil; sib = sib.NextSibling {
+		if sib.Type == html.ElementNode {
+			last = false
+			break
+		}
+	}
+	features = append(features, selectorFeature{":nth-child(" + strconv.Itoa(k) + ")", featurePosition})
+	if k == 1 {
+		features = append(features, selectorFeature{":first-child", featurePosition})
+	}
+	if last {
+		features = append(features, selectorFeature{":last-child", featurePosition})
+	}
+	return features
+}
+
+// parentFeatures returns the features of the parent of n, except for its
+// position.
+func parentFeatures(n *html.Node) []selectorFeature {
+	if n.Parent == nil || n.Parent.Type != html.ElementNode {
+		return nil
+	}
+	return contextFeatures(n.Parent)
+}
+
+// ancestorFeatures returns the ids, classes and attributes of the ancestors of
+// n, above its parent.
+func ancestorFeatures(n *html.Node) []selectorFeature {
+	var features []selectorFeature
+	if n.Parent == nil {
+		return nil
+	}
+	for a := n.Parent.Parent; a != nil && a.Type == html.ElementNode; a = a.Parent {
+		for _, f := range contextFeatures(a) {
+			if f.kind != featureTag {
+				features = append(features, f)
+			}
+		}
+	}
+	return features
+}
+
+// contextFeatures returns the features of n that do not depend on its
+// position.
+func contextFeatures(n *html.Node) []selectorFeature {
+	var features []selectorFeature
+	for _, f := range elementFeatures(n) {
+		if f.kind != featurePosition && f.kind != featureAttrPresence {
+			features = append(features, f)
+		}
+	}
+	return features
+}
+
+// inferElements returns the element nodes of the selections.
+func inferElements(sels []*Selection) []*html.Node {
+	var nodes []*html.Node
+	for _, s := range sels {
+		if s == nil {
+			continue
+		}
+		for _, n := range s.Nodes {
+			if n.Type == html.ElementNode && !isInSlice(nodes, n) {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	return nodes
+}
+
+// rootNode returns the topmost ancestor of n.
+func rootNode(n *html.Node) *html.Node {
+	for n.Parent != nil {
+		n = n.Parent
+	}
+	return n
+}
@@ -0,0 +1,132 @@
+package goquery
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestInferSelector(t *testing.T) {
+	doc := loadString(t, `<div id="list">
+<div class="item card"><h2 class="title">A</h2><span class="price">1</span></div>
+<div class="item card"><h2 class="title">B</h2><span class="price">2</span></div>
+<div class="item card promo"><h2 class="title">C</h2><span class="price">3</span></div>
+</div>
+<aside><h2 class="title">Related</h2><span class="price">4</span></aside>`)
+
+	cases := []struct {
+		pos, neg []*Selection
+		want     string
+	}{
+		{[]*Selection{doc.Find("h2").Eq(0), doc.Find("h2").Eq(1)}, nil, ".title"},
+		{[]*Selection{doc.Find("h2").Eq(0), doc.Find("h2").Eq(2)}, []*Selection{doc.Find("aside h2")}, "#list .title"},
+		{[]*Selection{doc.Find("span").Eq(3)}, []*Selection{doc.Find("#list .price")}, "aside > .price"},
+		{[]*Selection{doc.Find(".item").First()}, []*Selection{doc.Find(".item").Eq(1)}, ".item:first-child"},
+		{[]*Selection{doc.Find(".promo")}, nil, ".promo"},
+	}
+	for i, c := range cases {
+		got, err := InferSelector(c.pos, c.neg)
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%d: want %q, got %q", i, c.want, got)
+		}
+
+		// the selector matches all the positives and none of the negatives
+		matches := doc.Find(got)
+		for _, s := range c.pos {
+			if matches.Intersection(s).Length() != s.Length() {
+				t.Errorf("%d: %q does not match all the positives", i, got)
+			}
+		}
+		for _, s := range c.neg {
+			if matches.Intersection(s).Length() != 0 {
+				t.Errorf("%d: %q matches a negative", i, got)
+			}
+		}
+	}
+}
+
+func TestInferSelectorConfidence(t *testing.T) {
+	doc := loadString(t, `<ul><li class="a">1</li><li class="a">2</li><li class="a">3</li><li>4</li></ul><p>x</p>`)
+	li := doc.Find("li")
+
+	one, err := InferSelectorWithConfidence([]*Selection{li.Eq(0)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	three, err := InferSelectorWithConfidence([]*Selection{li.Slice(0, 3)}, []*Selection{li.Eq(3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if three.Selector != ".a" {
+		t.Errorf("want .a, got %q", three.Selector)
+	}
+	if one.Confidence <= 0 || one.Confidence >= three.Confidence || three.Confidence >= 1 {
+		t.Errorf("want 0 < %f < %f < 1", one.Confidence, three.Confidence)
+	}
+
+	// positions are weaker than classes
+	pos, err := InferSelectorWithConfidence([]*Selection{li.Eq(3)}, []*Selection{li.Slice(0, 3), doc.Find("p")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pos.Selector != "li:last-child" {
+		t.Errorf("want a position, got %q", pos.Selector)
+	}
+	if pos.Confidence >= three.Confidence {
+		t.Errorf("want a lower confidence than %f, got %f", three.Confidence, pos.Confidence)
+	}
+}
+
+func TestInferSelectorErrors(t *testing.T) {
+	doc := Doc()
+	if _, err := InferSelector(nil, nil); err == nil {
+		t.Error("want an error without positives")
+	}
+	text := doc.Find("body").Contents().FilterFunction(func(_ int, s *Selection) bool {
+		return s.Nodes[0].Type == html.TextNode
+	})
+	if _, err := InferSelector([]*Selection{text}, nil); err == nil {
+		t.Error("want an error without positive elements")
+	}
+	p := doc.Find("p").First()
+	if _, err := InferSelector([]*Selection{p}, []*Selection{p}); err != ErrNoSelector {
+		t.Errorf("want ErrNoSelector, got %v", err)
+	}
+}
+
+func TestInferSelectorManyFeatures(t *testing.T) {
+	var sb strings.Builder
+	for i := 0; i < 10; i++ {
+		sb.WriteString(`<div class="w` + strconv.Itoa(i) + ` a b c d e f g h">`)
+	}
+	for i := 0; i < 20; i++ {
+		sb.WriteString(`<span class="`)
+		for j := 0; j < 40; j++ {
+			sb.WriteString(" c" + strconv.Itoa(j))
+		}
+		sb.WriteString(`" data-a="1" data-b="2" data-c="3" data-d="4" title="t">x</span>`)
+	}
+	doc := loadString(t, sb.String())
+
+	spans := doc.Find("span")
+	got, err := InferSelector([]*Selection{spans.Eq(14)}, []*Selection{spans.Slice(0, 14), spans.Slice(15, 20)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sel := doc.Find(got); sel.Length() != 1 || sel.Nodes[0] != spans.Nodes[14] {
+		t.Errorf("want %s to only match the positive", got)
+	}
+
+	// the negative has the same features, all the combinations are tried
+	doc = loadString(t, sb.String()+sb.String())
+	spans = doc.Find("span")
+	if _, err := InferSelector([]*Selection{spans.Eq(0)}, []*Selection{spans.Eq(20)}); err != ErrNoSelector {
+		t.Errorf("want ErrNoSelector, got %v", err)
+	}
+}
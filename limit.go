@@ -0,0 +1,323 @@
+package goquery
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Limit identifies a limit of DocumentOptions on the documents that are
+// parsed.
+type Limit uint8
+
+// The limits of DocumentOptions, as reported by LimitError.
+const (
+	// LimitBytes is the MaxBytes option.
+	LimitBytes Limit = iota + 1
+	// LimitNodes is the MaxNodes option.
+	LimitNodes
+	// LimitDepth is the MaxDepth option.
+	LimitDepth
+	// LimitAttributes is the MaxAttributes option.
+	LimitAttributes
+	// LimitTextLength is the MaxTextLength option.
+	LimitTextLength
+)
+
+// String returns the name of the option that sets the limit.
+func (l Limit) String() string {
+	switch l {
+	case LimitBytes:
+		return "MaxBytes"
+	case LimitNodes:
+		return "MaxNodes"
+	case LimitDepth:
+		return "MaxDepth"
+	case LimitAttributes:
+		return "MaxAttributes"
+	case LimitTextLength:
+		return "MaxTextLength"
+	}
+	return fmt.Sprintf("Limit(%d)", uint8(l))
+}
+
+// LimitError is the error returned by NewDocumentFromReaderWithOptions when
+// the document exceeds one of the limits set by its options, and by
+// Document.Truncated when the document was truncated instead.
+type LimitError struct {
+	// Limit is the limit that was exceeded.
+	Limit Limit
+	// Max is the value of the limit.
+	Max int64
+	// Offset is the byte offset in the source of the token that exceeds the
+	// limit, or of the first byte over the limit for MaxBytes.
+	Offset int64
+}
+
+// Error returns the description of the error.
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("goquery: document exceeds the %s limit of %d at offset %d", e.Limit, e.Max, e.Offset)
+}
+
+// Truncated returns the *LimitError of the limit that the source of the
+// document exceeded, if it was parsed with the Truncate option and only the
+// part of the source before the limit was kept, or nil.
+func (d *Document) Truncated() error {
+	if d.truncated == nil {
+		return nil
+	}
+	return d.truncated
+}
+
+// DefaultMaxBytes is the MaxBytes limit applied by
+// NewDocumentFromReaderWithOptions when one of the limits checked on the
+// tokens of the source is set without MaxBytes, as the whole input is read
+// before it is parsed.
+const DefaultMaxBytes = 64 << 20
+
+// hasTokenLimits returns true if opts sets limits that are checked on the
+// tokens of the source.
+func (opts DocumentOptions) hasTokenLimits() bool {
+	return opts.MaxNodes > 0 || opts.MaxDepth > 0 || opts.MaxAttributes > 0 || opts.MaxTextLength > 0
+}
+
+// checkLimits tokenizes src as the parser does, and returns the error of the
+// first token that exceeds one of the limits of opts, or nil.
+func checkLimits(src string, opts DocumentOptions) *LimitError {
+	var nodes int64
+	var open []string
+	var start, off int
+	exceeds := func(max, n int64) bool {
+		return max > 0 && n > max
+	}
+	newErr := func(l Limit, max int64) *LimitError {
+		return &LimitError{Limit: l, Max: max, Offset: int64(start)}
+	}
+
+	z := html.NewTokenizerFragment(strings.NewReader(src), strings.ToLower(opts.Context))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			// the end of src, as the tokenizer has no buffer limit
+			return nil
+		}
+		start = off
+		off += len(z.Raw())
+
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			nodes++
+			name, more := z.TagName()
+			var attrs int64
+			for more {
+				_, _, more = z.TagAttr()
+				attrs++
+			}
+			if exceeds(opts.MaxAttributes, attrs) {
+				return newErr(LimitAttributes, opts.MaxAttributes)
+			}
+			tag := string(name)
+			if tt == html.SelfClosingTagToken || voidElements[tag] || rootElements[tag] {
+				break
+			}
+			if tag == "noscript" && opts.DisableScripting {
+				// the parser does the same with scripting disabled
+				z.NextIsNotRawText()
+			}
+			open = closeOptional(open, tag)
+			open = append(open, tag)
+			if exceeds(opts.MaxDepth, int64(len(open))) {
+				return newErr(LimitDepth, opts.MaxDepth)
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			open = closeInScope(open, string(name))
+		case html.TextToken, html.CommentToken:
+			if exceeds(opts.MaxTextLength, int64(off-start)) {
+				return newErr(LimitTextLength, opts.MaxTextLength)
+			}
+			nodes++
+		case html.DoctypeToken:
+			nodes++
+		}
+		if exceeds(opts.MaxNodes, nodes) {
+			return newErr(LimitNodes, opts.MaxNodes)
+		}
+	}
+}
+
+// rootElements are the elements that the parser inserts when they are
+// omitted, and whose end tags it ignores. They are not counted in the depth.
+var rootElements = map[string]bool{"html": true, "head": true, "body": true}
+
+// scopeElements are the elements that the end tags of the elements opened
+// before them cannot close, as the parser ignores the end tags of the
+// elements that are not in scope.
+var scopeElements = map[string]bool{
+	"applet": true, "caption": true, "marquee": true, "object": true,
+	"table": true, "td": true, "th": true, "template": true,
+	"foreignobject": true, "desc": true, "title": true, "mi": true, "mo": true,
+	"mn": true, "ms": true, "mtext": true, "annotation-xml": true,
+}
+
+// closeInScope closes the element of the tag name in open, the names of the
+// open elements, and the elements opened after it, unless it is not in scope.
+func closeInScope(open []string, tag string) []string {
+	for i := len(open) - 1; i >= 0; i-- {
+		if open[i] == tag {
+			return open[:i]
+		}
+		if scopeElements[open[i]] {
+			break
+		}
+	}
+	return open
+}
+
+// checkDepth returns the first element of the tree of root, in document
+// order, that is nested deeper than max elements, not counting the
+// rootElements, or nil. The parser may nest the elements deeper than their
+// tags in the source, e.g. when it reopens the formatting elements that were
+// implicitly closed.
+func checkDepth(root *html.Node, max int64) *html.Node {
+	var deep *html.Node
+	var walk func(n *html.Node, depth int64) bool
+	walk = func(n *html.Node, depth int64) bool {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			d := depth + nestingDepth(c)
+			if d > max {
+				deep = c
+				return false
+			}
+			if !walk(c, d) {
+				return false
+			}
+		}
+		return true
+	}
+	walk(root, 0)
+	return deep
+}
+
+// pruneDepth removes the elements of the tree of n that are nested deeper
+// than max elements, as counted by checkDepth, where n is at depth.
+func pruneDepth(n *html.Node, depth, max int64) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if d := depth + nestingDepth(c); d > max {
+			n.RemoveChild(c)
+		} else {
+			pruneDepth(c, d, max)
+		}
+		c = next
+	}
+}
+
+// checkNodes returns the first node of the tree of root, in document order,
+// that is over max nodes, not counting root and the rootElements, or nil. The
+// parser may create more nodes than there are tags in the source, e.g. when
+// it reopens the formatting elements that were implicitly closed.
+func checkNodes(root *html.Node, max int64) *html.Node {
+	var count int64
+	var over *html.Node
+	var walk func(n *html.Node) bool
+	walk = func(n *html.Node) bool {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode || nestingDepth(c) > 0 {
+				if count++; count > max {
+					over = c
+					return false
+				}
+			}
+			if !walk(c) {
+				return false
+			}
+		}
+		return true
+	}
+	walk(root)
+	return over
+}
+
+// pruneFrom removes n, which has a parent, and the nodes that follow it in
+// document order.
+func pruneFrom(n *html.Node) {
+	for a := n; a.Parent != nil; a = a.Parent {
+		for a.NextSibling != nil {
+			a.Parent.RemoveChild(a.NextSibling)
+		}
+	}
+	n.Parent.RemoveChild(n)
+}
+
+// nodeOffset returns the offset in src, the source of the tree of root, of
+// the node n or of the first node that follows it and comes from the source.
+func nodeOffset(src string, root, n *html.Node, opts DocumentOptions) int64 {
+	ds := newDocumentSource(src, root, opts.Context, !opts.DisableScripting)
+	for n != nil {
+		if rec := ds.nodes[n]; rec != nil && rec.hasSpan {
+			return int64(rec.start)
+		}
+		// the next node in document order
+		if n.FirstChild != nil {
+			n = n.FirstChild
+			continue
+		}
+		for n != nil && n.NextSibling == nil {
+			n = n.Parent
+		}
+		if n != nil {
+			n = n.NextSibling
+		}
+	}
+	return int64(len(src))
+}
+
+// nestingDepth returns 1 if n counts in the depth of its descendants.
+func nestingDepth(n *html.Node) int64 {
+	if n.Type != html.ElementNode || n.Namespace == "" && rootElements[n.Data] {
+		return 0
+	}
+	return 1
+}
+
+// closeOptional closes the element of the tag name in open, the names of the
+// open elements, if it is only followed by elements whose end tag may be
+// omitted, as a start tag of the same name does, e.g. for li or tr.
+func closeOptional(open []string, tag string) []string {
+	if !optionalEndTags[tag] {
+		return open
+	}
+	for i := len(open) - 1; i >= 0 && optionalEndTags[open[i]]; i-- {
+		if open[i] == tag {
+			return open[:i]
+		}
+	}
+	return open
+}
+
+// limitReader reads at most n bytes from r, and returns err instead of the
+// bytes that follow. exceeded is set when there are such bytes.
+type limitReader struct {
+	r        io.Reader
+	n        int64
+	err      error
+	exceeded bool
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	// read one more byte than remains, to tell if the limit is exceeded
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.n {
+		n, l.n = int(l.n), 0
+		l.exceeded = true
+		return n, l.err
+	}
+	l.n -= int64(n)
+	return n, err
+}
@@ -4,6 +4,7 @@ import (
 	"strings"
 
 	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
 )
 
 // After applies the selector from the root document and inserts the matched elements
@@ -25,7 +26,7 @@ func (s *Selection) After(selector string) *Selection {
 //
 // This follows the same rules as Selection.Append.
 func (s *Selection) AfterMatcher(m Matcher) *Selection {
-	return s.AfterNodes(m.MatchAll(s.document.rootNode)...)
+	return s.AfterNodes(s.document.findAll(m)...)
 }
 
 // AfterSelection inserts the elements in the selection after each element in the set of matched
@@ -40,11 +41,21 @@ func (s *Selection) AfterSelection(sel *Selection) *Selection {
 //
 // This follows the same rules as Selection.Append.
 func (s *Selection) AfterHtml(htmlStr string) *Selection {
-	return s.eachNodeHtml(htmlStr, true, func(node *html.Node, nodes []*html.Node) {
+	return s.afterHtml(s.newHtmlFragment(htmlStr, false))
+}
+
+// AfterHtmlWithOptions is like AfterHtml, but it returns an error, and leaves
+// the document unchanged, if the html cannot be inserted as requested.
+func (s *Selection) AfterHtmlWithOptions(htmlStr string, opts HtmlOptions) (*Selection, error) {
+	return s.checkedHtml(s.newHtmlFragment(htmlStr, false), opts, s.Nodes, parentContext, s.afterHtml)
+}
+
+func (s *Selection) afterHtml(f *htmlFragment) *Selection {
+	return s.eachNodeHtml(f, parentContext, func(node *html.Node, nodes []*html.Node) {
 		nextSibling := node.NextSibling
 		for _, n := range nodes {
 			if node.Parent != nil {
-				node.Parent.InsertBefore(n, nextSibling)
+				s.document.insertBefore(node.Parent, n, nextSibling)
 			}
 		}
 	})
@@ -54,11 +65,7 @@ func (s *Selection) AfterHtml(htmlStr string) *Selection {
 //
 // This follows the same rules as Selection.Append.
 func (s *Selection) AfterNodes(ns ...*html.Node) *Selection {
-	return s.manipulateNodes(ns, true, func(sn *html.Node, n *html.Node) {
-		if sn.Parent != nil {
-			sn.Parent.InsertBefore(n, sn.NextSibling)
-		}
-	})
+	return s.manipulateNodes(ns, true, s.afterNode)
 }
 
 // Append appends the elements specified by the selector to the end of each element
@@ -80,7 +87,7 @@ func (s *Selection) Append(selector string) *Selection {
 //
 // This follows the same rules as Selection.Append.
 func (s *Selection) AppendMatcher(m Matcher) *Selection {
-	return s.AppendNodes(m.MatchAll(s.document.rootNode)...)
+	return s.AppendNodes(s.document.findAll(m)...)
 }
 
 // AppendSelection appends the elements in the selection to the end of each element
@@ -93,9 +100,19 @@ func (s *Selection) AppendSelection(sel *Selection) *Selection {
 
 // AppendHtml parses the html and appends it to the set of matched elements.
 func (s *Selection) AppendHtml(htmlStr string) *Selection {
-	return s.eachNodeHtml(htmlStr, false, func(node *html.Node, nodes []*html.Node) {
+	return s.appendHtml(s.newHtmlFragment(htmlStr, false))
+}
+
+// AppendHtmlWithOptions is like AppendHtml, but it returns an error, and
+// leaves the document unchanged, if the html cannot be inserted as requested.
+func (s *Selection) AppendHtmlWithOptions(htmlStr string, opts HtmlOptions) (*Selection, error) {
+	return s.checkedHtml(s.newHtmlFragment(htmlStr, false), opts, s.Nodes, elementContext, s.appendHtml)
+}
+
+func (s *Selection) appendHtml(f *htmlFragment) *Selection {
+	return s.eachNodeHtml(f, elementContext, func(node *html.Node, nodes []*html.Node) {
 		for _, n := range nodes {
-			node.AppendChild(n)
+			s.document.appendChild(node, n)
 		}
 	})
 }
@@ -104,9 +121,43 @@ func (s *Selection) AppendHtml(htmlStr string) *Selection {
 //
 // This follows the same rules as Selection.Append.
 func (s *Selection) AppendNodes(ns ...*html.Node) *Selection {
-	return s.manipulateNodes(ns, false, func(sn *html.Node, n *html.Node) {
-		sn.AppendChild(n)
-	})
+	return s.manipulateNodes(ns, false, s.appendNode)
+}
+
+// AppendTo appends each element in the set of matched elements to the end of
+// the elements specified by the selector, which is applied to the root
+// document. It is the inverse of Append, and follows the same rules.
+//
+// It returns a new Selection object containing the inserted nodes, including
+// the clones inserted when there are multiple targets.
+func (s *Selection) AppendTo(selector string) *Selection {
+	return s.AppendToMatcher(compileMatcher(selector))
+}
+
+// AppendToMatcher appends each element in the set of matched elements to the
+// end of the elements specified by the matcher, which is applied to the root
+// document.
+//
+// This follows the same rules as Selection.AppendTo.
+func (s *Selection) AppendToMatcher(m Matcher) *Selection {
+	return s.AppendToNodes(s.document.findAll(m)...)
+}
+
+// AppendToSelection appends each element in the set of matched elements to
+// the end of the elements in the selection.
+//
+// This follows the same rules as Selection.AppendTo.
+func (s *Selection) AppendToSelection(sel *Selection) *Selection {
+	return s.AppendToNodes(sel.Nodes...)
+}
+
+// AppendToNodes appends each element in the set of matched elements to the
+// end of the specified nodes.
+//
+// This follows the same rules as Selection.AppendTo.
+func (s *Selection) AppendToNodes(targets ...*html.Node) *Selection {
+	t := &Selection{targets, s.document, nil}
+	return pushStack(s, t.insertNodes(s.Nodes, false, t.appendNode))
 }
 
 // Before inserts the matched elements before each element in the set of matched elements.
@@ -120,7 +171,7 @@ func (s *Selection) Before(selector string) *Selection {
 //
 // This follows the same rules as Selection.Append.
 func (s *Selection) BeforeMatcher(m Matcher) *Selection {
-	return s.BeforeNodes(m.MatchAll(s.document.rootNode)...)
+	return s.BeforeNodes(s.document.findAll(m)...)
 }
 
 // BeforeSelection inserts the elements in the selection before each element in the set of matched
@@ -135,10 +186,20 @@ func (s *Selection) BeforeSelection(sel *Selection) *Selection {
 //
 // This follows the same rules as Selection.Append.
 func (s *Selection) BeforeHtml(htmlStr string) *Selection {
-	return s.eachNodeHtml(htmlStr, true, func(node *html.Node, nodes []*html.Node) {
+	return s.beforeHtml(s.newHtmlFragment(htmlStr, false))
+}
+
+// BeforeHtmlWithOptions is like BeforeHtml, but it returns an error, and
+// leaves the document unchanged, if the html cannot be inserted as requested.
+func (s *Selection) BeforeHtmlWithOptions(htmlStr string, opts HtmlOptions) (*Selection, error) {
+	return s.checkedHtml(s.newHtmlFragment(htmlStr, false), opts, s.Nodes, parentContext, s.beforeHtml)
+}
+
+func (s *Selection) beforeHtml(f *htmlFragment) *Selection {
+	return s.eachNodeHtml(f, parentContext, func(node *html.Node, nodes []*html.Node) {
 		for _, n := range nodes {
 			if node.Parent != nil {
-				node.Parent.InsertBefore(n, node)
+				s.document.insertBefore(node.Parent, n, node)
 			}
 		}
 	})
@@ -148,11 +209,7 @@ func (s *Selection) BeforeHtml(htmlStr string) *Selection {
 //
 // This follows the same rules as Selection.Append.
 func (s *Selection) BeforeNodes(ns ...*html.Node) *Selection {
-	return s.manipulateNodes(ns, false, func(sn *html.Node, n *html.Node) {
-		if sn.Parent != nil {
-			sn.Parent.InsertBefore(n, sn)
-		}
-	})
+	return s.manipulateNodes(ns, false, s.beforeNode)
 }
 
 // Clone creates a deep copy of the set of matched nodes. The new nodes will not be
@@ -178,7 +235,7 @@ func (s *Selection) Empty() *Selection {
 	nodes := make([]*html.Node, 0, count)
 	for _, n := range s.Nodes {
 		for c := n.FirstChild; c != nil; c = n.FirstChild {
-			n.RemoveChild(c)
+			s.document.removeChild(n, c)
 			nodes = append(nodes, c)
 		}
 	}
@@ -186,6 +243,181 @@ func (s *Selection) Empty() *Selection {
 	return pushStack(s, nodes)
 }
 
+// InsertAfter inserts each element in the set of matched elements after the
+// elements specified by the selector, which is applied to the root document.
+// It is the inverse of After, and follows the same rules as
+// Selection.AppendTo.
+func (s *Selection) InsertAfter(selector string) *Selection {
+	return s.InsertAfterMatcher(compileMatcher(selector))
+}
+
+// InsertAfterMatcher inserts each element in the set of matched elements after
+// the elements specified by the matcher, which is applied to the root
+// document.
+//
+// This follows the same rules as Selection.AppendTo.
+func (s *Selection) InsertAfterMatcher(m Matcher) *Selection {
+	return s.InsertAfterNodes(s.document.findAll(m)...)
+}
+
+// InsertAfterSelection inserts each element in the set of matched elements
+// after the elements in the selection.
+//
+// This follows the same rules as Selection.AppendTo.
+func (s *Selection) InsertAfterSelection(sel *Selection) *Selection {
+	return s.InsertAfterNodes(sel.Nodes...)
+}
+
+// InsertAfterNodes inserts each element in the set of matched elements after
+// the specified nodes.
+//
+// This follows the same rules as Selection.AppendTo.
+func (s *Selection) InsertAfterNodes(targets ...*html.Node) *Selection {
+	t := &Selection{targets, s.document, nil}
+	return pushStack(s, t.insertNodes(s.Nodes, true, t.afterNode))
+}
+
+// InsertBefore inserts each element in the set of matched elements before the
+// elements specified by the selector, which is applied to the root document.
+// It is the inverse of Before, and follows the same rules as
+// Selection.AppendTo.
+func (s *Selection) InsertBefore(selector string) *Selection {
+	return s.InsertBeforeMatcher(compileMatcher(selector))
+}
+
+// InsertBeforeMatcher inserts each element in the set of matched elements
+// before the elements specified by the matcher, which is applied to the root
+// document.
+//
+// This follows the same rules as Selection.AppendTo.
+func (s *Selection) InsertBeforeMatcher(m Matcher) *Selection {
+	return s.InsertBeforeNodes(s.document.findAll(m)...)
+}
+
+// InsertBeforeSelection inserts each element in the set of matched elements
+// before the elements in the selection.
+//
+// This follows the same rules as Selection.AppendTo.
+func (s *Selection) InsertBeforeSelection(sel *Selection) *Selection {
+	return s.InsertBeforeNodes(sel.Nodes...)
+}
+
+// InsertBeforeNodes inserts each element in the set of matched elements before
+// the specified nodes.
+//
+// This follows the same rules as Selection.AppendTo.
+func (s *Selection) InsertBeforeNodes(targets ...*html.Node) *Selection {
+	t := &Selection{targets, s.document, nil}
+	return pushStack(s, t.insertNodes(s.Nodes, false, t.beforeNode))
+}
+
+// NormalizeOptions configures Selection.NormalizeWithOptions.
+type NormalizeOptions struct {
+	// CollapseWhitespace collapses the runs of whitespace in text nodes to a
+	// single space, and removes the whitespace-only text nodes that are
+	// next to a block element or at the start or end of one, as they have no
+	// effect on rendering. Text inside pre, textarea, script and style
+	// elements is left untouched.
+	CollapseWhitespace bool
+
+	// RemoveEmptyInline removes the inline elements (such as span or b) that
+	// have no attribute and no child once normalized. Elements with
+	// attributes are kept, as they may be styled or targeted by scripts.
+	RemoveEmptyInline bool
+}
+
+// Normalize puts the descendants of each element in the set of matched
+// elements in a normal form, like the DOM's Node.normalize method: adjacent
+// text nodes are merged and empty text nodes are removed.
+// It returns the current Selection object.
+func (s *Selection) Normalize() *Selection {
+	return s.NormalizeWithOptions(NormalizeOptions{})
+}
+
+// NormalizeWithOptions is like Normalize, with the options to also collapse
+// insignificant whitespace and remove empty inline elements.
+func (s *Selection) NormalizeWithOptions(opts NormalizeOptions) *Selection {
+	for _, n := range textRoots(s.Nodes) {
+		s.document.normalize(n, opts, false)
+	}
+	return s
+}
+
+func (d *Document) normalize(n *html.Node, opts NormalizeOptions, preserve bool) {
+	preserve = preserve || isWhitespaceSensitive(n)
+
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.ElementNode {
+			d.normalize(c, opts, preserve)
+			if opts.RemoveEmptyInline && c.FirstChild == nil && len(c.Attr) == 0 && isInlineElement(c) {
+				d.removeChild(n, c)
+			}
+		}
+		c = next
+	}
+
+	// Merge the adjacent text nodes, then remove the empty ones.
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.TextNode {
+			data := c.Data
+			for next != nil && next.Type == html.TextNode {
+				data += next.Data
+				nn := next.NextSibling
+				d.removeChild(n, next)
+				next = nn
+			}
+			if opts.CollapseWhitespace && !preserve {
+				data = collapseWhitespace(data)
+			}
+			d.setData(c, data)
+		}
+		c = next
+	}
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.TextNode {
+			if c.Data == "" || (opts.CollapseWhitespace && !preserve && c.Data == " " &&
+				(isBlockBoundary(n, c.PrevSibling) || isBlockBoundary(n, c.NextSibling))) {
+				d.removeChild(n, c)
+			}
+		}
+		c = next
+	}
+}
+
+// collapseWhitespace replaces each run of HTML whitespace characters with a
+// single space.
+func collapseWhitespace(s string) string {
+	var builder strings.Builder
+	builder.Grow(len(s))
+	space := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case ' ', '\t', '\n', '\f', '\r':
+			if !space {
+				builder.WriteByte(' ')
+			}
+			space = true
+		default:
+			builder.WriteByte(c)
+			space = false
+		}
+	}
+	return builder.String()
+}
+
+// isBlockBoundary returns true if the sibling (which may be nil, meaning the
+// start or end of parent's children) of a whitespace text node makes that
+// whitespace insignificant.
+func isBlockBoundary(parent, sibling *html.Node) bool {
+	if sibling == nil {
+		return !isInlineElement(parent)
+	}
+	return isBlockElement(sibling)
+}
+
 // Prepend prepends the elements specified by the selector to each element in
 // the set of matched elements, following the same rules as Append.
 func (s *Selection) Prepend(selector string) *Selection {
@@ -197,7 +429,7 @@ func (s *Selection) Prepend(selector string) *Selection {
 //
 // This follows the same rules as Selection.Append.
 func (s *Selection) PrependMatcher(m Matcher) *Selection {
-	return s.PrependNodes(m.MatchAll(s.document.rootNode)...)
+	return s.PrependNodes(s.document.findAll(m)...)
 }
 
 // PrependSelection prepends the elements in the selection to each element in
@@ -210,10 +442,20 @@ func (s *Selection) PrependSelection(sel *Selection) *Selection {
 
 // PrependHtml parses the html and prepends it to the set of matched elements.
 func (s *Selection) PrependHtml(htmlStr string) *Selection {
-	return s.eachNodeHtml(htmlStr, false, func(node *html.Node, nodes []*html.Node) {
+	return s.prependHtml(s.newHtmlFragment(htmlStr, false))
+}
+
+// PrependHtmlWithOptions is like PrependHtml, but it returns an error, and
+// leaves the document unchanged, if the html cannot be inserted as requested.
+func (s *Selection) PrependHtmlWithOptions(htmlStr string, opts HtmlOptions) (*Selection, error) {
+	return s.checkedHtml(s.newHtmlFragment(htmlStr, false), opts, s.Nodes, elementContext, s.prependHtml)
+}
+
+func (s *Selection) prependHtml(f *htmlFragment) *Selection {
+	return s.eachNodeHtml(f, elementContext, func(node *html.Node, nodes []*html.Node) {
 		firstChild := node.FirstChild
 		for _, n := range nodes {
-			node.InsertBefore(n, firstChild)
+			s.document.insertBefore(node, n, firstChild)
 		}
 	})
 }
@@ -223,11 +465,40 @@ func (s *Selection) PrependHtml(htmlStr string) *Selection {
 //
 // This follows the same rules as Selection.Append.
 func (s *Selection) PrependNodes(ns ...*html.Node) *Selection {
-	return s.manipulateNodes(ns, true, func(sn *html.Node, n *html.Node) {
-		// sn.FirstChild may be nil, in which case this functions like
-		// sn.AppendChild()
-		sn.InsertBefore(n, sn.FirstChild)
-	})
+	return s.manipulateNodes(ns, true, s.prependNode)
+}
+
+// PrependTo prepends each element in the set of matched elements to the
+// elements specified by the selector, which is applied to the root document.
+// It is the inverse of Prepend, and follows the same rules as
+// Selection.AppendTo.
+func (s *Selection) PrependTo(selector string) *Selection {
+	return s.PrependToMatcher(compileMatcher(selector))
+}
+
+// PrependToMatcher prepends each element in the set of matched elements to the
+// elements specified by the matcher, which is applied to the root document.
+//
+// This follows the same rules as Selection.AppendTo.
+func (s *Selection) PrependToMatcher(m Matcher) *Selection {
+	return s.PrependToNodes(s.document.findAll(m)...)
+}
+
+// PrependToSelection prepends each element in the set of matched elements to
+// the elements in the selection.
+//
+// This follows the same rules as Selection.AppendTo.
+func (s *Selection) PrependToSelection(sel *Selection) *Selection {
+	return s.PrependToNodes(sel.Nodes...)
+}
+
+// PrependToNodes prepends each element in the set of matched elements to the
+// specified nodes.
+//
+// This follows the same rules as Selection.AppendTo.
+func (s *Selection) PrependToNodes(targets ...*html.Node) *Selection {
+	t := &Selection{targets, s.document, nil}
+	return pushStack(s, t.insertNodes(s.Nodes, true, t.prependNode))
 }
 
 // Remove removes the set of matched elements from the document.
@@ -235,7 +506,7 @@ func (s *Selection) PrependNodes(ns ...*html.Node) *Selection {
 func (s *Selection) Remove() *Selection {
 	for _, n := range s.Nodes {
 		if n.Parent != nil {
-			n.Parent.RemoveChild(n)
+			s.document.removeChild(n.Parent, n)
 		}
 	}
 
@@ -259,6 +530,43 @@ func (s *Selection) RemoveMatcher(m Matcher) *Selection {
 	return s.FilterMatcher(m).Remove()
 }
 
+// ReplaceAll replaces the elements specified by the selector, which is applied
+// to the root document, with the set of matched elements. It is the inverse of
+// ReplaceWith, and follows the same rules as Selection.AppendTo.
+//
+// It returns a new Selection object containing the inserted nodes, not the
+// removed ones.
+func (s *Selection) ReplaceAll(selector string) *Selection {
+	return s.ReplaceAllMatcher(compileMatcher(selector))
+}
+
+// ReplaceAllMatcher replaces the elements specified by the matcher, which is
+// applied to the root document, with the set of matched elements.
+//
+// This follows the same rules as Selection.ReplaceAll.
+func (s *Selection) ReplaceAllMatcher(m Matcher) *Selection {
+	return s.ReplaceAllNodes(s.document.findAll(m)...)
+}
+
+// ReplaceAllSelection replaces the elements in the selection with the set of
+// matched elements.
+//
+// This follows the same rules as Selection.ReplaceAll.
+func (s *Selection) ReplaceAllSelection(sel *Selection) *Selection {
+	return s.ReplaceAllNodes(sel.Nodes...)
+}
+
+// ReplaceAllNodes replaces the specified nodes with the set of matched
+// elements.
+//
+// This follows the same rules as Selection.ReplaceAll.
+func (s *Selection) ReplaceAllNodes(targets ...*html.Node) *Selection {
+	t := &Selection{targets, s.document, nil}
+	inserted := t.insertNodes(s.Nodes, true, t.afterNode)
+	t.Remove()
+	return pushStack(s, inserted)
+}
+
 // ReplaceWith replaces each element in the set of matched elements with the
 // nodes matched by the given selector.
 // It returns the removed elements.
@@ -274,7 +582,7 @@ func (s *Selection) ReplaceWith(selector string) *Selection {
 //
 // This follows the same rules as Selection.Append.
 func (s *Selection) ReplaceWithMatcher(m Matcher) *Selection {
-	return s.ReplaceWithNodes(m.MatchAll(s.document.rootNode)...)
+	return s.ReplaceWithNodes(s.document.findAll(m)...)
 }
 
 // ReplaceWithSelection replaces each element in the set of matched elements with
@@ -292,11 +600,22 @@ func (s *Selection) ReplaceWithSelection(sel *Selection) *Selection {
 //
 // This follows the same rules as Selection.Append.
 func (s *Selection) ReplaceWithHtml(htmlStr string) *Selection {
-	s.eachNodeHtml(htmlStr, true, func(node *html.Node, nodes []*html.Node) {
+	return s.replaceWithHtml(s.newHtmlFragment(htmlStr, false))
+}
+
+// ReplaceWithHtmlWithOptions is like ReplaceWithHtml, but it returns an error,
+// and leaves the document unchanged, if the html cannot be inserted as
+// requested.
+func (s *Selection) ReplaceWithHtmlWithOptions(htmlStr string, opts HtmlOptions) (*Selection, error) {
+	return s.checkedHtml(s.newHtmlFragment(htmlStr, false), opts, s.Nodes, parentContext, s.replaceWithHtml)
+}
+
+func (s *Selection) replaceWithHtml(f *htmlFragment) *Selection {
+	s.eachNodeHtml(f, parentContext, func(node *html.Node, nodes []*html.Node) {
 		nextSibling := node.NextSibling
 		for _, n := range nodes {
 			if node.Parent != nil {
-				node.Parent.InsertBefore(n, nextSibling)
+				s.document.insertBefore(node.Parent, n, nextSibling)
 			}
 		}
 	})
@@ -316,22 +635,59 @@ func (s *Selection) ReplaceWithNodes(ns ...*html.Node) *Selection {
 // SetHtml sets the html content of each element in the selection to
 // specified html string.
 func (s *Selection) SetHtml(htmlStr string) *Selection {
+	return s.setHtml(s.newHtmlFragment(htmlStr, false))
+}
+
+// SetHtmlWithOptions is like SetHtml, but it returns an error, and leaves the
+// document unchanged, if the html cannot be inserted as requested.
+func (s *Selection) SetHtmlWithOptions(htmlStr string, opts HtmlOptions) (*Selection, error) {
+	return s.checkedHtml(s.newHtmlFragment(htmlStr, false), opts, s.Nodes, elementContext, s.setHtml)
+}
+
+func (s *Selection) setHtml(f *htmlFragment) *Selection {
 	for _, context := range s.Nodes {
 		for c := context.FirstChild; c != nil; c = context.FirstChild {
-			context.RemoveChild(c)
+			s.document.removeChild(context, c)
 		}
 	}
-	return s.eachNodeHtml(htmlStr, false, func(node *html.Node, nodes []*html.Node) {
+	return s.eachNodeHtml(f, elementContext, func(node *html.Node, nodes []*html.Node) {
 		for _, n := range nodes {
-			node.AppendChild(n)
+			s.document.appendChild(node, n)
 		}
 	})
 }
 
 // SetText sets the content of each element in the selection to specified content.
 // The provided text string is escaped.
+//
+// Observers of the document receive a single MutationCharacterData record
+// per element instead of the MutationChildList records of SetHtml.
 func (s *Selection) SetText(text string) *Selection {
-	return s.SetHtml(html.EscapeString(text))
+	d := s.document
+	if !d.observed() {
+		return s.SetHtml(html.EscapeString(text))
+	}
+
+	for _, n := range s.Nodes {
+		ss := newSingleSelection(n, d)
+		if n.Type != html.ElementNode {
+			ss.SetHtml(html.EscapeString(text))
+			continue
+		}
+		old, removed := ss.Text(), childNodes(n)
+		d.quiet(func() {
+			ss.SetHtml(html.EscapeString(text))
+		})
+		d.notify(&MutationRecord{
+			Type:         MutationCharacterData,
+			Target:       ss,
+			AddedNodes:   childNodes(n),
+			RemovedNodes: removed,
+			OldValue:     old,
+			NewValue:     text,
+		})
+	}
+	return s
 }
 
 // Unwrap removes the parents of the set of matched elements, leaving the matched
@@ -365,7 +721,7 @@ func (s *Selection) Wrap(selector string) *Selection {
 //
 // It returns the original set of elements.
 func (s *Selection) WrapMatcher(m Matcher) *Selection {
-	return s.wrapNodes(m.MatchAll(s.document.rootNode)...)
+	return s.wrapNodes(s.document.findAll(m)...)
 }
 
 // WrapSelection wraps each element in the set of matched elements inside the
@@ -382,13 +738,19 @@ func (s *Selection) WrapSelection(sel *Selection) *Selection {
 //
 // It returns the original set of elements.
 func (s *Selection) WrapHtml(htmlStr string) *Selection {
-	cache := make(map[string][]*html.Node)
+	return s.wrapHtml(s.newHtmlFragment(htmlStr, true))
+}
+
+// WrapHtmlWithOptions is like WrapHtml, but it returns an error, and leaves
+// the document unchanged, if the html cannot be parsed as requested or has no
+// element to wrap with.
+func (s *Selection) WrapHtmlWithOptions(htmlStr string, opts HtmlOptions) (*Selection, error) {
+	return s.checkedHtml(s.newHtmlFragment(htmlStr, true), opts, s.Nodes, wrapContext, s.wrapHtml)
+}
+
+func (s *Selection) wrapHtml(f *htmlFragment) *Selection {
 	for _, n := range s.Nodes {
-		parent := n.Parent
-		if parent == nil {
-			parent = &html.Node{Type: html.ElementNode}
-		}
-		nodes := cachedParseHtmlWithContext(cache, htmlStr, parent)
+		nodes, _ := f.parse(wrapContext(n))
 		newSingleSelection(n, s.document).wrapAllNodes(cloneNodes(nodes)...)
 	}
 	return s
@@ -426,7 +788,7 @@ func (s *Selection) WrapAll(selector string) *Selection {
 //
 // It returns the original set of elements.
 func (s *Selection) WrapAllMatcher(m Matcher) *Selection {
-	return s.wrapAllNodes(m.MatchAll(s.document.rootNode)...)
+	return s.wrapAllNodes(s.document.findAll(m)...)
 }
 
 // WrapAllSelection wraps a single HTML structure, the first node of the given
@@ -444,19 +806,37 @@ func (s *Selection) WrapAllSelection(sel *Selection) *Selection {
 //
 // It returns the original set of elements.
 func (s *Selection) WrapAllHtml(htmlStr string) *Selection {
-	var context *html.Node
+	return s.wrapAllHtml(s.newHtmlFragment(htmlStr, true))
+}
+
+// WrapAllHtmlWithOptions is like WrapAllHtml, but it returns an error, and
+// leaves the document unchanged, if the html cannot be parsed as requested or
+// has no element to wrap with.
+func (s *Selection) WrapAllHtmlWithOptions(htmlStr string, opts HtmlOptions) (*Selection, error) {
+	first := s.Nodes
+	if len(first) > 1 {
+		first = first[:1]
+	}
+	return s.checkedHtml(s.newHtmlFragment(htmlStr, true), opts, first, wrapAllContext, s.wrapAllHtml)
+}
+
+func (s *Selection) wrapAllHtml(f *htmlFragment) *Selection {
 	var nodes []*html.Node
 	if len(s.Nodes) > 0 {
-		context = s.Nodes[0]
-		if context.Parent != nil {
-			nodes = parseHtmlWithContext(htmlStr, context)
-		} else {
-			nodes = parseHtml(htmlStr)
-		}
+		nodes, _ = f.parse(wrapAllContext(s.Nodes[0]))
 	}
 	return s.wrapAllNodes(nodes...)
 }
 
+// wrapAllContext parses the HTML in the context of the first node of the
+// selection, or of an anonymous element if it is not in a tree.
+func wrapAllContext(n *html.Node) *html.Node {
+	if n.Parent == nil {
+		return &html.Node{Type: html.ElementNode}
+	}
+	return n
+}
+
 func (s *Selection) wrapAllNodes(ns ...*html.Node) *Selection {
 	if len(ns) > 0 {
 		return s.WrapAllNode(ns[0])
@@ -478,8 +858,11 @@ func (s *Selection) WrapAllNode(n *html.Node) *Selection {
 
 	first := s.Nodes[0]
 	if first.Parent != nil {
-		first.Parent.InsertBefore(wrap, first)
-		first.Parent.RemoveChild(first)
+		s.document.insertBefore(first.Parent, wrap, first)
+		s.document.removeChild(first.Parent, first)
+		if n.Parent == nil {
+			adoptForeignNamespace(wrap)
+		}
 	}
 
 	for c := getFirstChildEl(wrap); c != nil; c = getFirstChildEl(wrap) {
@@ -506,7 +889,7 @@ func (s *Selection) WrapInner(selector string) *Selection {
 //
 // It returns the original set of elements.
 func (s *Selection) WrapInnerMatcher(m Matcher) *Selection {
-	return s.wrapInnerNodes(m.MatchAll(s.document.rootNode)...)
+	return s.wrapInnerNodes(s.document.findAll(m)...)
 }
 
 // WrapInnerSelection wraps an HTML structure, matched by the given selector,
@@ -524,9 +907,19 @@ func (s *Selection) WrapInnerSelection(sel *Selection) *Selection {
 //
 // It returns the original set of elements.
 func (s *Selection) WrapInnerHtml(htmlStr string) *Selection {
-	cache := make(map[string][]*html.Node)
+	return s.wrapInnerHtml(s.newHtmlFragment(htmlStr, true))
+}
+
+// WrapInnerHtmlWithOptions is like WrapInnerHtml, but it returns an error, and
+// leaves the document unchanged, if the html cannot be parsed as requested or
+// has no element to wrap with.
+func (s *Selection) WrapInnerHtmlWithOptions(htmlStr string, opts HtmlOptions) (*Selection, error) {
+	return s.checkedHtml(s.newHtmlFragment(htmlStr, true), opts, s.Nodes, nodeContext, s.wrapInnerHtml)
+}
+
+func (s *Selection) wrapInnerHtml(f *htmlFragment) *Selection {
 	for _, n := range s.Nodes {
-		nodes := cachedParseHtmlWithContext(cache, htmlStr, n)
+		nodes, _ := f.parse(n)
 		newSingleSelection(n, s.document).wrapInnerNodes(cloneNodes(nodes)...)
 	}
 	return s
@@ -564,6 +957,12 @@ func parseHtml(h string) []*html.Node {
 }
 
 func parseHtmlWithContext(h string, context *html.Node) []*html.Node {
+	if context.Type == html.ElementNode && context.DataAtom != atom.Lookup([]byte(context.Data)) {
+		// the parser rejects the context nodes whose DataAtom is not the atom
+		// of their name, such as those built by hand
+		context = &html.Node{Type: context.Type, Data: context.Data, DataAtom: atom.Lookup([]byte(context.Data)),
+			Namespace: context.Namespace, Attr: context.Attr}
+	}
 	// Errors are only returned when the io.Reader returns any error besides
 	// EOF, but strings.Reader never will
 	nodes, err := html.ParseFragment(strings.NewReader(h), context)
@@ -597,10 +996,11 @@ func cloneNodes(ns []*html.Node) []*html.Node {
 // children but none of its parents or siblings.
 func cloneNode(n *html.Node) *html.Node {
 	nn := &html.Node{
-		Type:     n.Type,
-		DataAtom: n.DataAtom,
-		Data:     n.Data,
-		Attr:     make([]html.Attribute, len(n.Attr)),
+		Type:      n.Type,
+		DataAtom:  n.DataAtom,
+		Data:      n.Data,
+		Namespace: n.Namespace,
+		Attr:      make([]html.Attribute, len(n.Attr)),
 	}
 
 	copy(nn.Attr, n.Attr)
@@ -614,67 +1014,111 @@ func cloneNode(n *html.Node) *html.Node {
 func (s *Selection) manipulateNodes(ns []*html.Node, reverse bool,
 	f func(sn *html.Node, n *html.Node)) *Selection {
 
+	s.insertNodes(ns, reverse, f)
+	return s
+}
+
+// insertNodes implements manipulateNodes and returns the inserted nodes,
+// including the clones, grouped by target node and in the order of ns.
+func (s *Selection) insertNodes(ns []*html.Node, reverse bool,
+	f func(sn *html.Node, n *html.Node)) []*html.Node {
+
 	lasti := s.Size() - 1
 
 	// net.Html doesn't provide document fragments for insertion, so to get
 	// things in the correct order with After() and Prepend(), the callback
-	// needs to be called on the reverse of the nodes.
+	// needs to be called on the reverse of the nodes. Reverse a copy so that
+	// the caller's slice (often the Nodes of a Selection) is left untouched.
 	if reverse {
-		for i, j := 0, len(ns)-1; i < j; i, j = i+1, j-1 {
-			ns[i], ns[j] = ns[j], ns[i]
+		rns := make([]*html.Node, len(ns))
+		for i, n := range ns {
+			rns[len(ns)-1-i] = n
 		}
+		ns = rns
+	}
+
+	// the nodes that are not in a tree, such as those built by El, take the
+	// namespace of the foreign content they are inserted in
+	built := make([]bool, len(ns))
+	for j, n := range ns {
+		built[j] = n.Parent == nil
 	}
 
+	inserted := make([]*html.Node, 0, len(ns)*len(s.Nodes))
 	for i, sn := range s.Nodes {
-		for _, n := range ns {
+		start := len(inserted)
+		for j, n := range ns {
 			if i != lasti {
-				f(sn, cloneNode(n))
-			} else {
-				if n.Parent != nil {
-					n.Parent.RemoveChild(n)
-				}
-				f(sn, n)
+				n = cloneNode(n)
+			} else if n.Parent != nil {
+				s.document.removeChild(n.Parent, n)
+			}
+			f(sn, n)
+			if built[j] {
+				adoptForeignNamespace(n)
+			}
+			inserted = append(inserted, n)
+		}
+		if reverse {
+			group := inserted[start:]
+			for i, j := 0, len(group)-1; i < j; i, j = i+1, j-1 {
+				group[i], group[j] = group[j], group[i]
 			}
 		}
 	}
 
-	return s
+	return inserted
+}
+
+// Insertion callbacks for manipulateNodes and insertNodes, shared by the
+// target-first methods (e.g. AppendNodes) and their inverted counterparts
+// (e.g. AppendToNodes).
+
+func (s *Selection) afterNode(sn *html.Node, n *html.Node) {
+	if sn.Parent != nil {
+		s.document.insertBefore(sn.Parent, n, sn.NextSibling)
+	}
 }
 
-// eachNodeHtml parses the given html string and inserts the resulting nodes in the dom with the mergeFn.
-// The parsed nodes are inserted for each element of the selection.
-// isParent can be used to indicate that the elements of the selection should be treated as the parent for the parsed html.
-// A cache is used to avoid parsing the html multiple times should the elements of the selection result in the same context.
-func (s *Selection) eachNodeHtml(htmlStr string, isParent bool, mergeFn func(n *html.Node, nodes []*html.Node)) *Selection {
-	cache := make(map[string][]*html.Node)
+func (s *Selection) appendNode(sn *html.Node, n *html.Node) {
+	s.document.appendChild(sn, n)
+}
+
+func (s *Selection) beforeNode(sn *html.Node, n *html.Node) {
+	if sn.Parent != nil {
+		s.document.insertBefore(sn.Parent, n, sn)
+	}
+}
+
+func (s *Selection) prependNode(sn *html.Node, n *html.Node) {
+	// sn.FirstChild may be nil, in which case this functions like
+	// sn.AppendChild()
+	s.document.insertBefore(sn, n, sn.FirstChild)
+}
+
+// eachNodeHtml parses the given html fragment and inserts the resulting nodes in the dom with the mergeFn.
+// The parsed nodes are inserted for each element of the selection, and parsed in the context returned by
+// the context function, which skips the element if it returns nil. The fragment caches the parsed nodes to
+// avoid parsing the html multiple times should the elements of the selection result in the same context.
+func (s *Selection) eachNodeHtml(f *htmlFragment, context htmlContextFunc, mergeFn func(n *html.Node, nodes []*html.Node)) *Selection {
 	for _, n := range s.Nodes {
-		var context *html.Node
-		if isParent {
-			context = n.Parent
-		} else {
-			if n.Type != html.ElementNode {
-				continue
-			}
-			context = n
-		}
-		if context == nil {
+		ctx := context(n)
+		if ctx == nil {
 			continue
 		}
-		nodes := cachedParseHtmlWithContext(cache, htmlStr, context)
+		nodes, _ := f.parse(ctx)
 		mergeFn(n, cloneNodes(nodes))
 	}
 	return s
 }
 
-// cachedParseHtmlWithContext returns parseHtmlWithContext(htmlStr, context), reusing a prior
-// result when context's nodeName has already been seen. Callers pass their own
-// cache map so the cache lifetime matches the caller's loop scope.
-func cachedParseHtmlWithContext(cache map[string][]*html.Node, htmlStr string, context *html.Node) []*html.Node {
-	key := nodeName(context)
-	if nodes, ok := cache[key]; ok {
-		return nodes
+// checkedHtml parses the html fragment in the context of each of the nodes,
+// with the given options, and calls apply with the parsed fragment only if
+// there is no error.
+func (s *Selection) checkedHtml(f *htmlFragment, opts HtmlOptions, nodes []*html.Node,
+	context htmlContextFunc, apply func(*htmlFragment) *Selection) (*Selection, error) {
+	if err := f.withOptions(opts).check(nodes, context); err != nil {
+		return s, err
 	}
-	nodes := parseHtmlWithContext(htmlStr, context)
-	cache[key] = nodes
-	return nodes
+	return apply(f), nil
 }
@@ -3,6 +3,8 @@ package goquery
 import (
 	"log"
 	"testing"
+
+	"golang.org/x/net/html"
 )
 
 const (
@@ -155,6 +157,54 @@ func TestAppendHtmlContext(t *testing.T) {
 	printSel(t, doc.Selection)
 }
 
+func TestAppendTo(t *testing.T) {
+	doc := Doc2Clone()
+	sel := doc.Find("#nf6").AppendTo("#main")
+
+	assertLength(t, doc.Find("#foot #nf6").Nodes, 0)
+	assertLength(t, doc.Find("#main > #nf6:last-child").Nodes, 1)
+	assertLength(t, sel.Nodes, 1)
+	assertSelectionIs(t, sel, "#nf6")
+	printSel(t, doc.Selection)
+}
+
+func TestAppendToMany(t *testing.T) {
+	doc := Doc2Clone()
+	src := doc.Find("#nf5, #nf6")
+	sel := src.AppendTo(".one")
+
+	// a clone in #n1, the originals moved in #nf1
+	assertLength(t, sel.Nodes, 4)
+	assertSelectionIs(t, sel, "#nf5", "#nf6", "#nf5", "#nf6")
+	assertLength(t, doc.Find("#n1 > #nf5 + #nf6").Nodes, 1)
+	assertLength(t, doc.Find("#nf1 > #nf5 + #nf6").Nodes, 1)
+	if sel.Get(2) != src.Get(0) || sel.Get(3) != src.Get(1) {
+		t.Error("want the last inserted nodes to be the originals")
+	}
+	if sel.End() != src {
+		t.Error("want the source selection on the stack")
+	}
+	printSel(t, doc.Selection)
+}
+
+func TestAppendToSelection(t *testing.T) {
+	doc := Doc2Clone()
+	sel := doc.Find("#nf1, #nf2").AppendToSelection(doc.Find("#main"))
+
+	assertLength(t, sel.Nodes, 2)
+	assertLength(t, doc.Find("#main > #nf1 + #nf2").Nodes, 1)
+	printSel(t, doc.Selection)
+}
+
+func TestAppendToNodesClone(t *testing.T) {
+	doc := Doc2Clone()
+	sel := doc.Find("#nf1").Clone().AppendToNodes(doc.Find("#main").Nodes...)
+
+	assertLength(t, sel.Nodes, 1)
+	assertLength(t, doc.Find("#nf1").Nodes, 2)
+	assertLength(t, doc.Find("#main > #nf1").Nodes, 1)
+}
+
 func TestBefore(t *testing.T) {
 	doc := Doc2Clone()
 	doc.Find("#main").Before("#nf6")
@@ -223,6 +273,103 @@ func TestEmpty(t *testing.T) {
 	printSel(t, doc.Selection)
 }
 
+func TestInsertAfter(t *testing.T) {
+	doc := Doc2Clone()
+	sel := doc.Find("#nf1, #nf2").InsertAfter("#n3")
+
+	assertLength(t, sel.Nodes, 2)
+	assertSelectionIs(t, sel, "#nf1", "#nf2")
+	assertLength(t, doc.Find("#n3 + #nf1 + #nf2 + #n4").Nodes, 1)
+	assertLength(t, doc.Find("#foot #nf1, #foot #nf2").Nodes, 0)
+	printSel(t, doc.Selection)
+}
+
+func TestInsertAfterMany(t *testing.T) {
+	doc := Doc2Clone()
+	src := doc.Find("#nf1, #nf2")
+	sel := src.InsertAfter(".three")
+
+	assertLength(t, sel.Nodes, 4)
+	assertSelectionIs(t, sel, "#nf1", "#nf2", "#nf1", "#nf2")
+	assertLength(t, doc.Find("#n3 + #nf1 + #nf2").Nodes, 1)
+	assertLength(t, doc.Find("#nf3 + #nf1 + #nf2").Nodes, 1)
+	assertSelectionIs(t, src, "#nf1", "#nf2")
+	printSel(t, doc.Selection)
+}
+
+func TestInsertBefore(t *testing.T) {
+	doc := Doc2Clone()
+	sel := doc.Find("#nf1, #nf2").InsertBefore("#n3")
+
+	assertLength(t, sel.Nodes, 2)
+	assertLength(t, doc.Find("#n2 + #nf1 + #nf2 + #n3").Nodes, 1)
+	printSel(t, doc.Selection)
+}
+
+func TestInsertBeforeSelection(t *testing.T) {
+	doc := Doc2Clone()
+	sel := doc.Find("#nf6").InsertBeforeSelection(doc.Find(".one"))
+
+	assertLength(t, sel.Nodes, 2)
+	assertLength(t, doc.Find("#nf6 + #n1, #nf6 + #nf1").Nodes, 2)
+	printSel(t, doc.Selection)
+}
+
+func TestNormalize(t *testing.T) {
+	doc := loadString(t, `<div id="d"><p>a</p></div>`)
+	p := doc.Find("p")
+	p.AppendNodes(
+		&html.Node{Type: html.TextNode, Data: "b"},
+		&html.Node{Type: html.TextNode, Data: ""},
+		&html.Node{Type: html.TextNode, Data: "c"},
+	)
+	p.AfterNodes(&html.Node{Type: html.TextNode, Data: ""})
+	assertLength(t, p.Contents().Nodes, 4)
+
+	doc.Find("#d").Normalize()
+	assertLength(t, p.Contents().Nodes, 1)
+	assertLength(t, doc.Find("#d").Contents().Nodes, 1)
+	if got := p.Text(); got != "abc" {
+		t.Errorf("want %q, got %q", "abc", got)
+	}
+}
+
+func TestNormalizeAfterUnwrap(t *testing.T) {
+	doc := loadString(t, `<p>one <b>two</b> three</p>`)
+	doc.Find("b").Contents().Unwrap()
+	p := doc.Find("p")
+	assertLength(t, p.Contents().Nodes, 3)
+
+	doc.Normalize()
+	assertLength(t, p.Contents().Nodes, 1)
+	if got := p.Text(); got != "one two three" {
+		t.Errorf("want %q, got %q", "one two three", got)
+	}
+}
+
+func TestNormalizeWithOptions(t *testing.T) {
+	doc := loadString(t, `<div>
+		<p>  some   <b></b><span class="icon"></span> <i><u></u></i>text  </p>
+		<pre>  keep
+   this  </pre>
+		<span>a</span>  <span>b</span>
+	</div>`)
+	doc.Find("div").NormalizeWithOptions(NormalizeOptions{
+		CollapseWhitespace: true,
+		RemoveEmptyInline:  true,
+	})
+
+	got, err := doc.Find("div").Html()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<p> some <span class="icon"></span> text </p><pre>  keep
+   this  </pre><span>a</span> <span>b</span>`
+	if got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
 func TestPrepend(t *testing.T) {
 	doc := Doc2Clone()
 	doc.Find("#main").Prepend("#nf6")
@@ -302,6 +449,25 @@ func TestPrependHtmlContext(t *testing.T) {
 	printSel(t, doc.Selection)
 }
 
+func TestPrependTo(t *testing.T) {
+	doc := Doc2Clone()
+	sel := doc.Find("#nf5, #nf6").PrependTo("#main")
+
+	assertLength(t, sel.Nodes, 2)
+	assertSelectionIs(t, sel, "#nf5", "#nf6")
+	assertLength(t, doc.Find("#main > #nf5:first-child + #nf6 + #n1").Nodes, 1)
+	printSel(t, doc.Selection)
+}
+
+func TestPrependToMany(t *testing.T) {
+	doc := Doc2Clone()
+	sel := doc.Find("#nf6").PrependToMatcher(compileMatcher(".five"))
+
+	assertLength(t, sel.Nodes, 2)
+	assertLength(t, doc.Find("#n5 > #nf6, #nf5 > #nf6").Nodes, 2)
+	printSel(t, doc.Selection)
+}
+
 func TestRemove(t *testing.T) {
 	doc := Doc2Clone()
 	doc.Find("#nf1").Remove()
@@ -339,6 +505,29 @@ func TestRemoveFiltered(t *testing.T) {
 	printSel(t, doc.Selection)
 }
 
+func TestReplaceAll(t *testing.T) {
+	doc := Doc2Clone()
+	sel := doc.Find("#nf6").ReplaceAll(".three")
+
+	assertLength(t, sel.Nodes, 2)
+	assertSelectionIs(t, sel, "#nf6", "#nf6")
+	assertLength(t, doc.Find(".three").Nodes, 0)
+	assertLength(t, doc.Find("#n2 + #nf6 + #n4").Nodes, 1)
+	assertLength(t, doc.Find("#nf2 + #nf6 + #nf4").Nodes, 1)
+	assertLength(t, doc.Find("#foot > #nf6:last-child").Nodes, 0)
+	printSel(t, doc.Selection)
+}
+
+func TestReplaceAllSelection(t *testing.T) {
+	doc := Doc2Clone()
+	sel := doc.Find("#nf1, #nf2").ReplaceAllSelection(doc.Find("#main"))
+
+	assertLength(t, sel.Nodes, 2)
+	assertLength(t, doc.Find("#main").Nodes, 0)
+	assertLength(t, doc.Find("body > #nf1 + #nf2 + #foot").Nodes, 1)
+	printSel(t, doc.Selection)
+}
+
 func TestReplaceWith(t *testing.T) {
 	doc := Doc2Clone()
 
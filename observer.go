@@ -0,0 +1,253 @@
+package goquery
+
+import "golang.org/x/net/html"
+
+// MutationType identifies the kind of change described by a MutationRecord.
+type MutationType int
+
+// Mutation types, modeled after the DOM's MutationRecord.type values
+// (https://developer.mozilla.org/en-US/docs/Web/API/MutationRecord/type).
+const (
+	// MutationChildList is reported when nodes are added to or removed from
+	// the children of the target.
+	MutationChildList MutationType = iota + 1
+	// MutationAttributes is reported when an attribute of the target is
+	// added, changed or removed.
+	MutationAttributes
+	// MutationCharacterData is reported when the text content of the target
+	// is changed.
+	MutationCharacterData
+)
+
+// String returns the DOM name of the mutation type.
+func (t MutationType) String() string {
+	switch t {
+	case MutationChildList:
+		return "childList"
+	case MutationAttributes:
+		return "attributes"
+	case MutationCharacterData:
+		return "characterData"
+	}
+	return ""
+}
+
+// MutationRecord describes a single change made to a Document by one of the
+// manipulation methods.
+type MutationRecord struct {
+	// Type is the kind of change.
+	Type MutationType
+
+	// Target is the node affected by the change: the parent of the added or
+	// removed nodes for MutationChildList, the element that holds the
+	// attribute for MutationAttributes and the node whose text changed for
+	// MutationCharacterData.
+	Target *Selection
+
+	// AddedNodes and RemovedNodes hold the nodes added to or removed from the
+	// Target's children.
+	AddedNodes   []*html.Node
+	RemovedNodes []*html.Node
+
+	// PreviousSibling and NextSibling are the siblings of the added or
+	// removed nodes, if any.
+	PreviousSibling *html.Node
+	NextSibling     *html.Node
+
+	// AttributeName is the name of the changed attribute, for
+	// MutationAttributes records.
+	AttributeName string
+
+	// AttributeNamespace is the Namespace of the changed attribute, for the
+	// MutationAttributes records of AttrNS, SetAttrNS and RemoveAttrNS.
+	AttributeNamespace string
+
+	// OldValue and NewValue are the attribute values before and after the
+	// change for MutationAttributes records, and the text before and after
+	// the change for MutationCharacterData records. A missing attribute is
+	// reported as an empty value.
+	OldValue string
+	NewValue string
+}
+
+// MutationObserver receives the MutationRecords of a Document. It is created
+// by Document.Observe.
+type MutationObserver struct {
+	doc   *Document
+	f     func(*MutationRecord)
+	types []MutationType
+}
+
+// Observe registers f to be called synchronously for each change made to the
+// document by the manipulation methods (Append*, Prepend*, After*, Before*,
+// Remove*, ReplaceWith*, Wrap*, Unwrap, Empty, SetAttr, RemoveAttr,
+// AddClass, RemoveClass, ToggleClass, SetText, SetHtml and ReplaceText). If
+// types are provided, only records of those types are reported.
+//
+// Changes made directly on the html.Node values are not reported.
+func (d *Document) Observe(f func(*MutationRecord), types ...MutationType) *MutationObserver {
+	o := &MutationObserver{doc: d, f: f, types: types}
+	d.observers = append(d.observers, o)
+	return o
+}
+
+// Disconnect stops the observer from receiving any further records.
+func (o *MutationObserver) Disconnect() {
+	obs := o.doc.observers
+	for i, oo := range obs {
+		if oo == o {
+			o.doc.observers = append(obs[:i:i], obs[i+1:]...)
+			return
+		}
+	}
+}
+
+func (o *MutationObserver) wants(t MutationType) bool {
+	if len(o.types) == 0 {
+		return true
+	}
+	for _, tt := range o.types {
+		if tt == t {
+			return true
+		}
+	}
+	return false
+}
+
+// observed returns true if there is at least one observer that should be
+// notified of changes made to the document. It is safe to call on a nil
+// Document.
+func (d *Document) observed() bool {
+	return d != nil && d.muted == 0 && len(d.observers) > 0
+}
+
+// quiet calls f with the notifications suspended, so that a manipulation
+// method can report its changes with a single, higher-level record.
+func (d *Document) quiet(f func()) {
+	if d == nil {
+		f()
+		return
+	}
+	d.muted++
+	defer func() { d.muted-- }()
+	f()
+}
+
+func (d *Document) notify(r *MutationRecord) {
+	if !d.observed() {
+		return
+	}
+	for _, o := range d.observers {
+		if o.wants(r.Type) {
+			o.f(r)
+		}
+	}
+}
+
+// insertBefore inserts n as a child of parent, before ref (or as last child
+// if ref is nil), and notifies the observers.
+func (d *Document) insertBefore(parent, n, ref *html.Node) {
+	parent.InsertBefore(n, ref)
+	if d.observed() {
+		d.notify(&MutationRecord{
+			Type:            MutationChildList,
+			Target:          newSingleSelection(parent, d),
+			AddedNodes:      []*html.Node{n},
+			PreviousSibling: n.PrevSibling,
+			NextSibling:     n.NextSibling,
+		})
+	}
+}
+
+// appendChild adds n as last child of parent and notifies the observers.
+func (d *Document) appendChild(parent, n *html.Node) {
+	d.insertBefore(parent, n, nil)
+}
+
+// removeChild removes n from the children of parent and notifies the
+// observers.
+func (d *Document) removeChild(parent, n *html.Node) {
+	prev, next := n.PrevSibling, n.NextSibling
+	parent.RemoveChild(n)
+	if d.observed() {
+		d.notify(&MutationRecord{
+			Type:            MutationChildList,
+			Target:          newSingleSelection(parent, d),
+			RemovedNodes:    []*html.Node{n},
+			PreviousSibling: prev,
+			NextSibling:     next,
+		})
+	}
+}
+
+// setData sets the text of the text or comment node n to data and notifies
+// the observers.
+func (d *Document) setData(n *html.Node, data string) {
+	old := n.Data
+	n.Data = data
+	if d.observed() && old != data {
+		d.notify(&MutationRecord{
+			Type:     MutationCharacterData,
+			Target:   newSingleSelection(n, d),
+			OldValue: old,
+			NewValue: data,
+		})
+	}
+}
+
+// attrChanged notifies the observers that the attribute attrName of n may
+// have changed from old (had indicates if it was present). No record is
+// emitted if the attribute is unchanged.
+func (d *Document) attrChanged(n *html.Node, attrName, old string, had bool) {
+	if !d.observed() {
+		return
+	}
+	d.notifyAttr(n, "", attrName, getAttributePtr(attrName, n), old, had)
+}
+
+// attrChangedNS is like attrChanged for the attribute attrName of the
+// namespace ns.
+func (d *Document) attrChangedNS(n *html.Node, ns, attrName, old string, had bool) {
+	if !d.observed() {
+		return
+	}
+	d.notifyAttr(n, ns, attrName, getAttributeNSPtr(ns, attrName, n), old, had)
+}
+
+// notifyAttr notifies the observers that the attribute of n changed to attr,
+// which is nil if it is missing, unless it is unchanged.
+func (d *Document) notifyAttr(n *html.Node, ns, attrName string, attr *html.Attribute, old string, had bool) {
+	var val string
+	if attr != nil {
+		val = attr.Val
+	}
+	if (attr != nil) == had && val == old {
+		return
+	}
+	d.notify(&MutationRecord{
+		Type:               MutationAttributes,
+		Target:             newSingleSelection(n, d),
+		AttributeName:      attrName,
+		AttributeNamespace: ns,
+		OldValue:           old,
+		NewValue:           val,
+	})
+}
+
+// attrValue returns the value of the attribute attrName of n and whether it
+// is present.
+func attrValue(n *html.Node, attrName string) (string, bool) {
+	if attr := getAttributePtr(attrName, n); attr != nil {
+		return attr.Val, true
+	}
+	return "", false
+}
+
+// childNodes returns the children of n.
+func childNodes(n *html.Node) []*html.Node {
+	var nodes []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		nodes = append(nodes, c)
+	}
+	return nodes
+}
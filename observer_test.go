@@ -0,0 +1,172 @@
+package goquery
+
+import (
+	"testing"
+)
+
+func collectMutations(doc *Document, types ...MutationType) *[]*MutationRecord {
+	var records []*MutationRecord
+	doc.Observe(func(r *MutationRecord) {
+		records = append(records, r)
+	}, types...)
+	return &records
+}
+
+func TestObserveChildList(t *testing.T) {
+	doc := Doc2Clone()
+	records := collectMutations(doc)
+
+	doc.Find("#main").AppendHtml("<span>new</span>")
+	if len(*records) != 1 {
+		t.Fatalf("want 1 record, got %d", len(*records))
+	}
+	r := (*records)[0]
+	if r.Type != MutationChildList {
+		t.Errorf("want type %s, got %s", MutationChildList, r.Type)
+	}
+	if !r.Target.Is("#main") {
+		t.Errorf("want target #main, got %s", NodeName(r.Target))
+	}
+	assertLength(t, r.AddedNodes, 1)
+	assertLength(t, r.RemovedNodes, 0)
+	if r.AddedNodes[0].Data != "span" {
+		t.Errorf("want added span, got %s", r.AddedNodes[0].Data)
+	}
+}
+
+func TestObserveMove(t *testing.T) {
+	doc := Doc2Clone()
+	records := collectMutations(doc)
+
+	doc.Find("#main").AppendSelection(doc.Find("#nf6"))
+	if len(*records) != 2 {
+		t.Fatalf("want 2 records, got %d", len(*records))
+	}
+	removed, added := (*records)[0], (*records)[1]
+	assertLength(t, removed.RemovedNodes, 1)
+	assertLength(t, added.AddedNodes, 1)
+	if removed.RemovedNodes[0] != added.AddedNodes[0] {
+		t.Error("want the same node to be removed and added")
+	}
+	if !added.Target.Is("#main") {
+		t.Errorf("want target #main, got %s", NodeName(added.Target))
+	}
+}
+
+func TestObserveRemoveAndUnwrap(t *testing.T) {
+	doc := Doc2Clone()
+	records := collectMutations(doc)
+
+	doc.Find("#nf1").Remove()
+	doc.Find("#nf2").Unwrap()
+
+	var added, removed int
+	for _, r := range *records {
+		added += len(r.AddedNodes)
+		removed += len(r.RemovedNodes)
+	}
+	if removed == 0 || added == 0 {
+		t.Errorf("want both added and removed nodes, got %d added, %d removed", added, removed)
+	}
+	if r := (*records)[0]; r.RemovedNodes[0].Data != "div" {
+		t.Errorf("want first record to remove #nf1, got %+v", r.RemovedNodes[0])
+	}
+}
+
+func TestObserveAttributes(t *testing.T) {
+	doc := Doc2Clone()
+	records := collectMutations(doc, MutationAttributes)
+
+	sel := doc.Find("#main")
+	sel.SetAttr("data-x", "1")
+	sel.SetAttr("data-x", "2")
+	sel.SetAttr("data-x", "2")
+	sel.RemoveAttr("data-x")
+	sel.RemoveAttr("data-x")
+	sel.AddClass("a b")
+	sel.ToggleClass("a")
+	sel.RemoveClass()
+
+	cases := []struct {
+		name, old, new string
+	}{
+		{"data-x", "", "1"},
+		{"data-x", "1", "2"},
+		{"data-x", "2", ""},
+		{"class", "", "a b"},
+		{"class", "a b", "b"},
+		{"class", "b", ""},
+	}
+	if len(*records) != len(cases) {
+		t.Fatalf("want %d records, got %d", len(cases), len(*records))
+	}
+	for i, c := range cases {
+		r := (*records)[i]
+		if r.Type != MutationAttributes || r.AttributeName != c.name || r.OldValue != c.old || r.NewValue != c.new {
+			t.Errorf("[%d] want %s %q -> %q, got %s %s %q -> %q", i, c.name, c.old, c.new,
+				r.Type, r.AttributeName, r.OldValue, r.NewValue)
+		}
+	}
+}
+
+func TestObserveAttributesNS(t *testing.T) {
+	doc := loadString(t, `<svg><a href="/plain"></a></svg>`)
+	records := collectMutations(doc, MutationAttributes)
+
+	sel := doc.Find("a")
+	sel.SetAttrNS("xlink", "href", "#x")
+	sel.RemoveAttrNS("", "href")
+	sel.RemoveAttrNS("", "href")
+
+	if len(*records) != 2 {
+		t.Fatalf("want 2 records, got %d", len(*records))
+	}
+	if r := (*records)[0]; r.AttributeNamespace != "xlink" || r.AttributeName != "href" || r.OldValue != "" || r.NewValue != "#x" {
+		t.Errorf("unexpected record %+v", r)
+	}
+	if r := (*records)[1]; r.AttributeNamespace != "" || r.AttributeName != "href" || r.OldValue != "/plain" || r.NewValue != "" {
+		t.Errorf("unexpected record %+v", r)
+	}
+}
+
+func TestObserveSetText(t *testing.T) {
+	doc := loadString(t, `<html><body><p id="p">old <b>text</b></p></body></html>`)
+	records := collectMutations(doc)
+
+	doc.Find("#p").SetText("new <text>")
+	if len(*records) != 1 {
+		t.Fatalf("want 1 record, got %d", len(*records))
+	}
+	r := (*records)[0]
+	if r.Type != MutationCharacterData || r.OldValue != "old text" || r.NewValue != "new <text>" {
+		t.Errorf("unexpected record %s %q -> %q", r.Type, r.OldValue, r.NewValue)
+	}
+	assertLength(t, r.RemovedNodes, 2)
+	assertLength(t, r.AddedNodes, 1)
+}
+
+func TestObserveDisconnect(t *testing.T) {
+	doc := Doc2Clone()
+	var count int
+	o := doc.Observe(func(r *MutationRecord) { count++ })
+
+	doc.Find("#main").SetAttr("a", "b")
+	o.Disconnect()
+	doc.Find("#main").SetAttr("a", "c")
+	if count != 1 {
+		t.Errorf("want 1 record, got %d", count)
+	}
+}
+
+func TestObserveWrap(t *testing.T) {
+	doc := Doc2Clone()
+	records := collectMutations(doc, MutationChildList)
+
+	doc.Find("#nf1").WrapHtml("<section></section>")
+	if doc.Find("section > #nf1").Length() != 1 {
+		t.Fatal("want #nf1 to be wrapped")
+	}
+	if len(*records) == 0 {
+		t.Error("want records for Wrap, got none")
+	}
+}
@@ -0,0 +1,193 @@
+package goquery
+
+import (
+	"reflect"
+	"sort"
+
+	"golang.org/x/net/html"
+)
+
+// DocumentPosition is the bitmask returned by CompareDocumentPosition, as
+// defined by the DOM specification.
+type DocumentPosition uint16
+
+// The bits of a DocumentPosition.
+const (
+	DocumentPositionDisconnected           DocumentPosition = 0x01
+	DocumentPositionPreceding              DocumentPosition = 0x02
+	DocumentPositionFollowing              DocumentPosition = 0x04
+	DocumentPositionContains               DocumentPosition = 0x08
+	DocumentPositionContainedBy            DocumentPosition = 0x10
+	DocumentPositionImplementationSpecific DocumentPosition = 0x20
+)
+
+// CompareDocumentPosition returns the position of b relative to a, like the
+// compareDocumentPosition method of the DOM: b precedes or follows a in
+// document order, and may also contain a or be contained by it. It returns 0
+// if a and b are the same node.
+//
+// If a and b are not in the same tree, the position has the Disconnected and
+// ImplementationSpecific bits set, with Preceding or Following, consistently
+// for all the nodes of the two trees.
+func CompareDocumentPosition(a, b *html.Node) DocumentPosition {
+	if a == b {
+		return 0
+	}
+	pa, pb := ancestorsFromRoot(a), ancestorsFromRoot(b)
+	if pa[0] != pb[0] {
+		pos := DocumentPositionDisconnected | DocumentPositionImplementationSpecific
+		if reflect.ValueOf(pa[0]).Pointer() < reflect.ValueOf(pb[0]).Pointer() {
+			return pos | DocumentPositionFollowing
+		}
+		return pos | DocumentPositionPreceding
+	}
+
+	k := 1
+	for k < len(pa) && k < len(pb) && pa[k] == pb[k] {
+		k++
+	}
+	switch {
+	case k == len(pa):
+		return DocumentPositionContainedBy | DocumentPositionFollowing
+	case k == len(pb):
+		return DocumentPositionContains | DocumentPositionPreceding
+	}
+	// pa[k] and pb[k] are siblings
+	for sib := pa[k].NextSibling; sib != nil; sib = sib.NextSibling {
+		if sib == pb[k] {
+			return DocumentPositionFollowing
+		}
+	}
+	return DocumentPositionPreceding
+}
+
+// SortDocumentOrder returns a new Selection with the nodes of the current
+// Selection sorted in document order, without duplicates, like jQuery's
+// uniqueSort. The nodes that are not in the same tree are grouped by tree, in
+// the order of the first node of each tree in the Selection.
+//
+// Documents can also be configured with the DocumentOrder option, so that
+// all the methods that return a new Selection return its nodes in document
+// order.
+func (s *Selection) SortDocumentOrder() *Selection {
+	return pushStack(s, sortDocumentOrder(s.Nodes))
+}
+
+// sortDocumentOrder returns the nodes sorted in document order, without
+// duplicates, as described by SortDocumentOrder. It returns the nodes
+// unchanged if they are already sorted, and a sorted copy otherwise.
+func sortDocumentOrder(nodes []*html.Node) []*html.Node {
+	if len(nodes) < 2 {
+		return nodes
+	}
+
+	// the trees, in the order of their first node
+	trees := make(map[*html.Node]int)
+	tree := make([]int, len(nodes))
+	for i, n := range nodes {
+		root := n
+		for root.Parent != nil {
+			root = root.Parent
+		}
+		t, ok := trees[root]
+		if !ok {
+			t = len(trees)
+			trees[root] = t
+		}
+		tree[i] = t
+	}
+
+	// the nodes are checked in order with one comparison per node, before
+	// building an index of the trees, as the nodes of most selections are
+	// already sorted
+	sorted := true
+	for i := 1; i < len(nodes) && sorted; i++ {
+		sorted = tree[i-1] < tree[i] || (tree[i-1] == tree[i] && follows(nodes[i-1], nodes[i]))
+	}
+	if sorted {
+		return nodes
+	}
+
+	// below minNodesForSet, the nodes are compared by walking up the tree,
+	// above it, by their index in a walk of the trees
+	less := func(a, b *html.Node) bool {
+		return CompareDocumentPosition(a, b)&DocumentPositionFollowing != 0
+	}
+	if len(nodes) >= minNodesForSet {
+		index := make(map[*html.Node]int)
+		for root := range trees {
+			var walk func(*html.Node)
+			walk = func(n *html.Node) {
+				index[n] = len(index)
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					walk(c)
+				}
+			}
+			walk(root)
+		}
+		less = func(a, b *html.Node) bool {
+			return index[a] < index[b]
+		}
+	}
+
+	order := make([]int, len(nodes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if tree[a] != tree[b] {
+			return tree[a] < tree[b]
+		}
+		return less(nodes[a], nodes[b])
+	})
+	result := make([]*html.Node, 0, len(nodes))
+	for _, i := range order {
+		if len(result) == 0 || result[len(result)-1] != nodes[i] {
+			result = append(result, nodes[i])
+		}
+	}
+	return result
+}
+
+// ancestorsFromRoot returns the ancestors of n, from the root of its tree,
+// followed by n.
+func ancestorsFromRoot(n *html.Node) []*html.Node {
+	var path []*html.Node
+	for ; n != nil; n = n.Parent {
+		path = append(path, n)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// follows returns true if b follows a in document order, for two nodes of the
+// same tree. Unlike CompareDocumentPosition, it does not allocate.
+func follows(a, b *html.Node) bool {
+	if a == b {
+		return false
+	}
+	da, db := nodeDepth(a), nodeDepth(b)
+	pa, pb := a, b
+	for ; da > db; da-- {
+		pa = pa.Parent
+	}
+	for ; db > da; db-- {
+		pb = pb.Parent
+	}
+	if pa == pb {
+		// one contains the other, which precedes it
+		return pa == a
+	}
+	for pa.Parent != pb.Parent {
+		pa, pb = pa.Parent, pb.Parent
+	}
+	for sib := pa.NextSibling; sib != nil; sib = sib.NextSibling {
+		if sib == pb {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package goquery
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestCompareDocumentPosition(t *testing.T) {
+	doc := loadString(t, `<div id="a"><p id="b">x</p><p id="c"><b id="d">y</b></p></div><div id="e"></div>`)
+	node := func(id string) *html.Node { return doc.Find("#" + id).Nodes[0] }
+
+	cases := []struct {
+		a, b string
+		want DocumentPosition
+	}{
+		{"a", "a", 0},
+		{"b", "c", DocumentPositionFollowing},
+		{"c", "b", DocumentPositionPreceding},
+		{"a", "d", DocumentPositionContainedBy | DocumentPositionFollowing},
+		{"d", "a", DocumentPositionContains | DocumentPositionPreceding},
+		{"d", "e", DocumentPositionFollowing},
+		{"e", "b", DocumentPositionPreceding},
+	}
+	for _, c := range cases {
+		if got := CompareDocumentPosition(node(c.a), node(c.b)); got != c.want {
+			t.Errorf("%s, %s: want %#x, got %#x", c.a, c.b, c.want, got)
+		}
+	}
+
+	// disconnected nodes are ordered consistently
+	other := Doc().Find("p").Nodes[0]
+	ab, ba := CompareDocumentPosition(node("b"), other), CompareDocumentPosition(other, node("d"))
+	if ab&DocumentPositionDisconnected == 0 || ab&DocumentPositionImplementationSpecific == 0 {
+		t.Errorf("want a disconnected position, got %#x", ab)
+	}
+	if ab&(DocumentPositionPreceding|DocumentPositionFollowing) == ba&(DocumentPositionPreceding|DocumentPositionFollowing) {
+		t.Errorf("want opposite positions, got %#x and %#x", ab, ba)
+	}
+}
+
+func TestFollows(t *testing.T) {
+	doc := loadString(t, `<div><p>x<b>y</b></p><p><i>z</i></p></div><div></div>`)
+	var nodes []*html.Node
+	walkDescendants(doc.rootNode, true, false, func(n *html.Node) bool {
+		nodes = append(nodes, n)
+		return true
+	})
+	for _, a := range nodes {
+		for _, b := range nodes {
+			want := CompareDocumentPosition(a, b)&DocumentPositionFollowing != 0
+			if got := follows(a, b); got != want {
+				t.Errorf("%s, %s: want %t, got %t", nodeName(a), nodeName(b), want, got)
+			}
+		}
+	}
+}
+
+func TestSortDocumentOrder(t *testing.T) {
+	doc := loadString(t, `<h1>1</h1><table></table><h2>2</h2><table></table><h2>3</h2>`)
+	sel := doc.Find("h2").AddSelection(doc.Find("h1")).AddSelection(doc.Find("table"))
+	if got := joinNodeNames(sel); got != "h2,h2,h1,table,table" {
+		t.Fatalf("want the nodes in the order they were added, got %s", got)
+	}
+	sorted := sel.SortDocumentOrder()
+	if got := joinNodeNames(sorted); got != "h1,table,h2,table,h2" {
+		t.Errorf("want the nodes in document order, got %s", got)
+	}
+	if sorted.End() != sel {
+		t.Error("want the previous selection on the stack")
+	}
+
+	// duplicates are removed, and trees are kept in the order of their first node
+	clones := doc.Find("h2").Clone()
+	dup := &Selection{Nodes: append([]*html.Node{clones.Nodes[1], doc.Find("h1").Nodes[0]}, sel.Nodes...), document: doc}
+	if got := joinNodeNames(dup.SortDocumentOrder()); got != "h2,h1,table,h2,table,h2" {
+		t.Errorf("want the nodes in document order, got %s", got)
+	}
+}
+
+func TestSortDocumentOrderLarge(t *testing.T) {
+	doc := loadString(t, "<ul>"+strings.Repeat("<li><b></b></li>", minNodesForSet)+"</ul>")
+	all := doc.Find("*")
+	reversed := make([]*html.Node, all.Length())
+	for i, n := range all.Nodes {
+		reversed[len(reversed)-1-i] = n
+	}
+	sorted := (&Selection{Nodes: reversed, document: doc}).SortDocumentOrder()
+	if !sorted.IsSelection(all) || sorted.Length() != all.Length() {
+		t.Fatal("want the same nodes")
+	}
+	for i, n := range sorted.Nodes {
+		if n != all.Nodes[i] {
+			t.Fatalf("%d: want the nodes in document order", i)
+		}
+	}
+}
+
+func TestDocumentOrderOption(t *testing.T) {
+	src := `<h1>1</h1><table></table><h2>2</h2><table></table><h2>3</h2>`
+	doc, err := NewDocumentFromReaderWithOptions(strings.NewReader(src), DocumentOptions{DocumentOrder: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := joinNodeNames(doc.Find("h2").Union(doc.Find("table"))); got != "table,h2,table,h2" {
+		t.Errorf("want the nodes in document order, got %s", got)
+	}
+	// the next heading after each table
+	if got := doc.Find("table").NextAllFiltered("h2").First().Text(); got != "2" {
+		t.Errorf("want 2, got %q", got)
+	}
+	if got := joinNodeNames(doc.Find("h2").Last().PrevAll()); got != "h1,table,h2,table" {
+		t.Errorf("want the nodes in document order, got %s", got)
+	}
+	if !CloneDocument(doc).DocumentOrder {
+		t.Error("want the option on the clone")
+	}
+}
+
+func joinNodeNames(s *Selection) string {
+	var names []string
+	for _, n := range s.Nodes {
+		names = append(names, n.Data)
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,385 @@
+package goquery
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// PathOptions configures the selectors built by CSSPathWithOptions and
+// XPathPathWithOptions. The zero value uses the default attributes and
+// ignores nothing.
+type PathOptions struct {
+	// Attributes are the names of the attributes, other than id and class,
+	// that are used to identify the elements, in order of preference. If it
+	// is nil, DefaultPathAttributes is used.
+	Attributes []string
+
+	// IgnoreAttributes are the names of the attributes that are never used
+	// to identify the elements, which may include "id" and "class".
+	IgnoreAttributes []string
+
+	// IgnoreValues are the patterns of the ids, classes and attribute values
+	// that are never used to identify the elements, such as the generated
+	// class names of CSS modules.
+	IgnoreValues []*regexp.Regexp
+}
+
+// DefaultPathAttributes are the attributes used to identify the elements,
+// other than id and class, if PathOptions.Attributes is nil.
+var DefaultPathAttributes = []string{
+	"name", "data-testid", "data-test", "data-qa", "role", "type", "aria-label",
+	"for", "title", "alt",
+}
+
+// CSSPath returns the shortest CSS selector that only matches the first node
+// in the Selection, in its document. The selector identifies the element and,
+// as needed, its ancestors, preferably by id, then by tag name, class or
+// attribute, then by position (:nth-child), joined with the child
+// combinator. Use Map to get the selectors of all the nodes.
+//
+// It returns an empty string if the selection is empty, or if its first node
+// is not an element.
+func (s *Selection) CSSPath() string {
+	return s.CSSPathWithOptions(PathOptions{})
+}
+
+// CSSPathWithOptions is like CSSPath, configured by opts.
+func (s *Selection) CSSPathWithOptions(opts PathOptions) string {
+	if len(s.Nodes) == 0 {
+		return ""
+	}
+	return newPathBuilder(opts, false).path(s.Nodes[0])
+}
+
+// XPathPath returns the shortest XPath expression that only selects the
+// first node in the Selection, in its document, built the same way as the
+// selector of CSSPath, with the position of the elements among the
+// siblings with the same name instead of :nth-child. Unlike CSSPath, it
+// also supports the text and comment nodes.
+//
+// It returns an empty string if the selection is empty, or if its first node
+// is not an element, a text or a comment.
+func (s *Selection) XPathPath() string {
+	return s.XPathPathWithOptions(PathOptions{})
+}
+
+// XPathPathWithOptions is like XPathPath, configured by opts.
+func (s *Selection) XPathPathWithOptions(opts PathOptions) string {
+	if len(s.Nodes) == 0 {
+		return ""
+	}
+	return newPathBuilder(opts, true).path(s.Nodes[0])
+}
+
+// pathStep identifies an element relative to its parent. out is the step as
+// written in the path, and css its equivalent CSS selector, used to check the
+// matches of the path.
+type pathStep struct {
+	out, css string
+	id       bool
+}
+
+type pathBuilder struct {
+	opts       PathOptions
+	attributes []string
+	xpath      bool
+}
+
+func newPathBuilder(opts PathOptions, xpath bool) *pathBuilder {
+	b := &pathBuilder{opts: opts, attributes: opts.Attributes, xpath: xpath}
+	if b.attributes == nil {
+		b.attributes = DefaultPathAttributes
+	}
+	return b
+}
+
+func (b *pathBuilder) path(n *html.Node) string {
+	switch n.Type {
+	case html.ElementNode:
+	case html.TextNode, html.CommentNode:
+		if !b.xpath || n.Parent == nil || n.Parent.Type != html.ElementNode {
+			return ""
+		}
+		kind := "text()"
+		if n.Type == html.CommentNode {
+			kind = "comment()"
+		}
+		k := 1
+		for sib := n.PrevSibling; sib != nil; sib = sib.PrevSibling {
+			if sib.Type == n.Type {
+				k++
+			}
+		}
+		return b.path(n.Parent) + "/" + kind + "[" + strconv.Itoa(k) + "]"
+	default:
+		return ""
+	}
+
+	root := n
+	for root.Parent != nil {
+		root = root.Parent
+	}
+
+	var steps []pathStep
+	for c := n; c != nil && c.Type == html.ElementNode; c = c.Parent {
+		chosen := -1
+		candidates := b.candidates(c)
+		for i, st := range candidates {
+			if st.id {
+				// the id anchors the path if it is unique in the document
+				if matches := findPath(root, []pathStep{st}); len(matches) == 1 && matches[0] == c {
+					return b.join(append([]pathStep{st}, steps...), false)
+				}
+				continue
+			}
+			if !siblingUnique(c, st.css) {
+				continue
+			}
+			if chosen < 0 {
+				chosen = i
+			}
+			path := append([]pathStep{st}, steps...)
+			if matches := findPath(root, path); len(matches) == 1 && matches[0] == n {
+				return b.join(path, false)
+			}
+		}
+		if chosen < 0 {
+			// the name of the element cannot be selected, which the position
+			// among the siblings otherwise always is
+			return ""
+		}
+		steps = append([]pathStep{candidates[chosen]}, steps...)
+	}
+	return b.join(steps, root.Type == html.DocumentNode)
+}
+
+// join returns the path of the steps, absolute if it starts at the root
+// element of the document.
+func (b *pathBuilder) join(steps []pathStep, absolute bool) string {
+	parts := make([]string, len(steps))
+	for i, st := range steps {
+		parts[i] = st.out
+	}
+	if !b.xpath {
+		return strings.Join(parts, " > ")
+	}
+	if absolute {
+		return "/" + strings.Join(parts, "/")
+	}
+	return "//" + strings.Join(parts, "/")
+}
+
+// findPath returns the nodes matched by the CSS equivalent of the steps, using
+// Find.
+func findPath(root *html.Node, steps []pathStep) []*html.Node {
+	parts := make([]string, len(steps))
+	for i, st := range steps {
+		parts[i] = st.css
+	}
+	return newSingleSelection(root, nil).Find(strings.Join(parts, " > ")).Nodes
+}
+
+// siblingUnique returns true if the selector matches n and none of its
+// siblings.
+func siblingUnique(n *html.Node, selector string) bool {
+	m := compileMatcher(selector)
+	if !m.Match(n) {
+		return false
+	}
+	if n.Parent == nil {
+		return true
+	}
+	for sib := n.Parent.FirstChild; sib != nil; sib = sib.NextSibling {
+		if sib != n && sib.Type == html.ElementNode && m.Match(sib) {
+			return false
+		}
+	}
+	return true
+}
+
+// candidates returns the steps that may identify n, in order of preference.
+func (b *pathBuilder) candidates(n *html.Node) []pathStep {
+	tag := cssIdent(n.Data)
+	xtag := strings.ToLower(n.Data)
+	switch {
+	case n.Namespace != "":
+		xtag = "*[local-name()=" + xpathLiteral(n.Data) + "]"
+	case !isNCName(xtag):
+		// such as g:plusone, which is not a prefixed name in HTML
+		xtag = "*[name()=" + xpathLiteral(xtag) + "]"
+	}
+
+	var steps []pathStep
+	if id, ok := b.value(n, "id"); ok && id != "" {
+		steps = append(steps, pathStep{
+			out: "*[@id=" + xpathLiteral(id) + "]",
+			css: "#" + cssIdent(id),
+			id:  true,
+		})
+		if !b.xpath {
+			steps[0].out = steps[0].css
+		}
+	}
+	steps = append(steps, pathStep{out: xtag, css: tag})
+
+	if classes, ok := b.value(n, "class"); ok {
+		for _, class := range strings.Fields(classes) {
+			if b.ignored(class) {
+				continue
+			}
+			steps = append(steps, pathStep{
+				out: fmt.Sprintf(`%s[contains(concat(" ", normalize-space(@class), " "), %s)]`, xtag, xpathLiteral(" "+class+" ")),
+				css: tag + "." + cssIdent(class),
+			})
+		}
+	}
+	for _, name := range b.attributes {
+		if val, ok := b.value(n, name); ok {
+			steps = append(steps, pathStep{
+				out: xtag + "[@" + name + "=" + xpathLiteral(val) + "]",
+				css: tag + "[" + cssIdent(name) + "=" + cssString(val) + "]",
+			})
+		}
+	}
+
+	// the position among the element siblings for CSS, and among the
+	// siblings with the same name for XPath
+	k, kType := 1, 1
+	for sib := n.PrevSibling; sib != nil; sib = sib.PrevSibling {
+		if sib.Type == html.ElementNode {
+			k++
+			if sib.Data == n.Data && sib.Namespace == n.Namespace {
+				kType++
+			}
+		}
+	}
+	if b.xpath {
+		steps = append(steps, pathStep{
+			out: xtag + "[" + strconv.Itoa(kType) + "]",
+			css: tag + ":nth-of-type(" + strconv.Itoa(kType) + ")",
+		})
+	} else {
+		steps = append(steps, pathStep{
+			out: tag + ":nth-child(" + strconv.Itoa(k) + ")",
+			css: tag + ":nth-child(" + strconv.Itoa(k) + ")",
+		})
+	}
+
+	if !b.xpath {
+		for i := range steps {
+			steps[i].out = steps[i].css
+		}
+	}
+	return steps
+}
+
+// value returns the value of the attribute of n, unless it is ignored.
+func (b *pathBuilder) value(n *html.Node, name string) (string, bool) {
+	for _, ignored := range b.opts.IgnoreAttributes {
+		if ignored == name {
+			return "", false
+		}
+	}
+	val, ok := attrValue(n, name)
+	if !ok || (name != "class" && b.ignored(val)) {
+		return "", false
+	}
+	return val, true
+}
+
+// ignored returns true if the value matches one of the IgnoreValues.
+func (b *pathBuilder) ignored(val string) bool {
+	for _, re := range b.opts.IgnoreValues {
+		if re.MatchString(val) {
+			return true
+		}
+	}
+	return false
+}
+
+// cssIdent escapes s as a CSS identifier.
+func cssIdent(s string) string {
+	var sb strings.Builder
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r >= 0x80:
+			sb.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 || (i == 1 && s[0] == '-') {
+				// an identifier cannot start with a digit
+				fmt.Fprintf(&sb, "\\%x ", r)
+			} else {
+				sb.WriteRune(r)
+			}
+		case r == '-':
+			if i == 0 && len(s) == 1 {
+				sb.WriteString("\\-")
+			} else {
+				sb.WriteRune(r)
+			}
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&sb, "\\%x ", r)
+		default:
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// cssString returns s as a double-quoted CSS string.
+func cssString(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&sb, "\\%x ", r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// isNCName returns true if s is a name without a colon, that may be used as
+// an XPath name test.
+func isNCName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_', unicode.IsLetter(r):
+		case i > 0 && (r == '-' || r == '.' || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r)):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// xpathLiteral returns s as an XPath string literal. XPath has no escapes, so
+// a string with both kinds of quotes is built with concat.
+func xpathLiteral(s string) string {
+	if !strings.Contains(s, `"`) {
+		return `"` + s + `"`
+	}
+	if !strings.Contains(s, "'") {
+		return "'" + s + "'"
+	}
+	parts := strings.Split(s, `"`)
+	for i, p := range parts {
+		parts[i] = `"` + p + `"`
+	}
+	return "concat(" + strings.Join(parts, `, '"', `) + ")"
+}
@@ -0,0 +1,104 @@
+package goquery
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestCSSPathUnique(t *testing.T) {
+	for _, d := range []*Document{Doc(), Doc2(), DocB()} {
+		d.Find("*").Each(func(i int, s *Selection) {
+			path := s.CSSPath()
+			if found := d.Find(path); found.Length() != 1 || found.Nodes[0] != s.Nodes[0] {
+				t.Errorf("%s matches %d nodes", path, found.Length())
+			}
+		})
+	}
+}
+
+func TestCSSPath(t *testing.T) {
+	doc := loadString(t, `<body><div id="main"><ul class="list x1_Ab3"><li>a</li><li class="sel">b</li><li>c</li></ul>`+
+		`<ul class="list"><li>d</li><li>e</li></ul><form><input name="q"><input name="q" type="submit"></form></div>`+
+		`<p id="dup">1</p><p id="dup">2</p><p id="1a.b">3</p></body>`)
+
+	cases := []struct {
+		sel  *Selection
+		opts PathOptions
+		want string
+	}{
+		{doc.Find("#main"), PathOptions{}, "#main"},
+		{doc.Find(".sel"), PathOptions{}, "li.sel"},
+		{doc.Find("li").Eq(2), PathOptions{}, "li:nth-child(3)"},
+		{doc.Find("li").Eq(0), PathOptions{}, "ul.x1_Ab3 > li:nth-child(1)"},
+		{doc.Find("li").Eq(4), PathOptions{}, "ul:nth-child(2) > li:nth-child(2)"},
+		{doc.Find("li").Eq(0), PathOptions{IgnoreValues: []*regexp.Regexp{regexp.MustCompile(`_[A-Za-z0-9]{3}$`)}},
+			"ul:nth-child(1) > li:nth-child(1)"},
+		{doc.Find("input").Last(), PathOptions{}, `input[type="submit"]`},
+		{doc.Find("input").Last(), PathOptions{Attributes: []string{"name"}}, "input:nth-child(2)"},
+		{doc.Find("#main"), PathOptions{IgnoreAttributes: []string{"id"}}, "div"},
+		{doc.Find("p").Eq(1), PathOptions{}, "p:nth-child(3)"},
+		{doc.Find("p").Eq(2), PathOptions{}, `#\31 a\.b`},
+		{doc.Find("li").First().Contents(), PathOptions{}, ""},
+		{doc.Find("nothing"), PathOptions{}, ""},
+	}
+	for i, c := range cases {
+		got := c.sel.CSSPathWithOptions(c.opts)
+		if got != c.want {
+			t.Errorf("%d: want %q, got %q", i, c.want, got)
+		}
+		if got != "" {
+			if found := doc.Find(got); found.Length() != 1 || found.Nodes[0] != c.sel.Nodes[0] {
+				t.Errorf("%d: %s matches %d nodes", i, got, found.Length())
+			}
+		}
+	}
+}
+
+func TestXPathPath(t *testing.T) {
+	doc := loadString(t, `<body><div id="main"><p class="a">one<!--c-->two</p><p title='say "hi"'>x</p><p>y</p></div>`+
+		`<div><p>z</p><svg><rect/><rect/></svg></div></body>`)
+
+	cases := []struct {
+		sel  *Selection
+		want string
+	}{
+		{doc.Find("#main"), `//*[@id="main"]`},
+		{doc.Find("p.a"), `//p[contains(concat(" ", normalize-space(@class), " "), " a ")]`},
+		{doc.Find("p").Eq(1), `//p[@title='say "hi"']`},
+		{doc.Find("p").Eq(2), `//p[3]`},
+		{doc.Find("p").Eq(3), `//div[2]/p`},
+		{doc.Find("p.a").Contents().Eq(2), `//p[contains(concat(" ", normalize-space(@class), " "), " a ")]/text()[2]`},
+		{doc.Find("p.a").Contents().Eq(1), `//p[contains(concat(" ", normalize-space(@class), " "), " a ")]/comment()[1]`},
+		{doc.Find("rect").Last(), `//*[local-name()="rect"][2]`},
+		{doc.Find("html"), `//html`},
+	}
+	for i, c := range cases {
+		if got := c.sel.XPathPath(); got != c.want {
+			t.Errorf("%d: want %s, got %s", i, c.want, got)
+		}
+	}
+}
+
+func TestXPathLiteral(t *testing.T) {
+	cases := []struct{ in, want string }{
+		{`a`, `"a"`},
+		{`a"b`, `'a"b'`},
+		{`a"b'c`, `concat("a", '"', "b'c")`},
+	}
+	for _, c := range cases {
+		if got := xpathLiteral(c.in); got != c.want {
+			t.Errorf("want %s, got %s", c.want, got)
+		}
+	}
+}
+
+func TestXPathPathPrefixedName(t *testing.T) {
+	doc := loadString(t, `<body><div><g:plusone></g:plusone><g:plusone size="x"></g:plusone></div></body>`)
+
+	if got, want := doc.Find(`g\:plusone`).Last().XPathPath(), `//*[name()="g:plusone"][2]`; got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+	if got, want := doc.Find(`g\:plusone`).Last().CSSPath(), `g\:plusone:nth-child(2)`; got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,215 @@
+package goquery
+
+import (
+	"fmt"
+	"sort"
+
+	"golang.org/x/net/html"
+)
+
+// Position is the location of a node in the source of its document, as
+// returned by Selection.Position.
+//
+// The span of an element goes from the start of its start tag to the end of
+// its end tag. If the end tag is omitted, it ends with the content of the
+// element, and if the start tag is omitted (e.g. the html or tbody elements
+// inserted by the parser), it is the span of the content of the element.
+type Position struct {
+	// Offset and EndOffset are the byte offsets in the source of the start
+	// and the end of the node.
+	Offset, EndOffset int
+
+	// Line and Column are the line and column of Offset, and EndLine and
+	// EndColumn those of EndOffset. Lines and columns start at 1, and
+	// columns are counted in bytes.
+	Line, Column       int
+	EndLine, EndColumn int
+
+	// Synthetic indicates that the node does not come from the source, and
+	// the other fields are not set. This is the case of the nodes created
+	// after the document was parsed, such as by the manipulation methods,
+	// of the nodes inserted by the parser with no content, and of all the
+	// nodes of the documents parsed without the Positions option.
+	Synthetic bool
+}
+
+// String returns the position as "line:column-line:column", or "synthetic".
+func (p Position) String() string {
+	if p.Synthetic {
+		return "synthetic"
+	}
+	return fmt.Sprintf("%d:%d-%d:%d", p.Line, p.Column, p.EndLine, p.EndColumn)
+}
+
+// Position returns the position in the source of the first node in the
+// Selection. The positions are only recorded for the documents parsed with
+// the Positions option of NewDocumentFromReaderWithOptions, or with
+// NewDocumentFromReaderWithSource.
+func (s *Selection) Position() Position {
+	if len(s.Nodes) == 0 || s.document == nil || s.document.source == nil {
+		return Position{Synthetic: true}
+	}
+	ds := s.document.source
+	rec := ds.nodes[s.Nodes[0]]
+	if rec == nil || !rec.hasSpan {
+		return Position{Synthetic: true}
+	}
+
+	p := Position{Offset: rec.start, EndOffset: rec.end}
+	p.Line, p.Column = ds.lineColumn(rec.start)
+	p.EndLine, p.EndColumn = ds.lineColumn(rec.end)
+	return p
+}
+
+// lineColumn returns the line and column of the byte offset in the source.
+func (ds *documentSource) lineColumn(offset int) (int, int) {
+	line := sort.SearchInts(ds.lines, offset+1) - 1
+	return line + 1, offset - ds.lines[line] + 1
+}
+
+// NodeAt returns the innermost element of the document whose span in the
+// source covers the byte offset, or an empty selection if there is none.
+// Like Selection.Position, it requires the document to be parsed with the
+// Positions option. The spans are those of the elements as parsed, so an
+// element created after the document was parsed is never returned.
+func (d *Document) NodeAt(offset int) *Selection {
+	nodes := d.innermostInRange(offset, offset+1)
+	var inner *html.Node
+	depth := -1
+	for _, n := range nodes {
+		if dn := nodeDepth(n); dn > depth {
+			inner, depth = n, dn
+		}
+	}
+	if inner == nil {
+		return pushStack(d.Selection, nil)
+	}
+	return pushStack(d.Selection, []*html.Node{inner})
+}
+
+// NodesInRange returns the innermost elements of the document whose span in
+// the source overlaps the range of byte offsets from start to end (excluded),
+// i.e. the elements that overlap the range and contain no other element that
+// does. If end is not after start, it is the range of the byte at start. See
+// NodeAt for the conditions of the lookup.
+func (d *Document) NodesInRange(start, end int) *Selection {
+	return pushStack(d.Selection, d.innermostInRange(start, max(end, start+1)))
+}
+
+func (d *Document) innermostInRange(start, end int) []*html.Node {
+	ds := d.source
+	if ds == nil {
+		return nil
+	}
+	ds.indexOnce.Do(func() {
+		ds.index = newSpanIndex(ds)
+	})
+
+	var nodes []*html.Node
+	ds.index.overlapping(start, end, func(n *html.Node) {
+		if nodeInDocument(n, d.rootNode) {
+			nodes = append(nodes, n)
+		}
+	})
+
+	// remove the ancestors of the other elements
+	ancestors := make(map[*html.Node]bool)
+	for _, n := range nodes {
+		for p := n.Parent; p != nil && !ancestors[p]; p = p.Parent {
+			ancestors[p] = true
+		}
+	}
+	result := nodes[:0]
+	for _, n := range nodes {
+		if !ancestors[n] {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// nodeInDocument returns true if n is a descendant of root.
+func nodeInDocument(n, root *html.Node) bool {
+	for ; n != nil; n = n.Parent {
+		if n == root {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeDepth returns the number of ancestors of n.
+func nodeDepth(n *html.Node) int {
+	depth := 0
+	for p := n.Parent; p != nil; p = p.Parent {
+		depth++
+	}
+	return depth
+}
+
+// spanIndex is an interval tree of the spans of the elements of a document.
+// The spans are sorted by start offset, and form an implicit balanced binary
+// tree where the root of each range of spans is the one in the middle, with
+// maxEnd the largest end offset of the spans of its subtree.
+type spanIndex struct {
+	spans  []indexedSpan
+	maxEnd []int
+}
+
+type indexedSpan struct {
+	start, end int
+	node       *html.Node
+}
+
+func newSpanIndex(ds *documentSource) *spanIndex {
+	idx := &spanIndex{}
+	for n, rec := range ds.nodes {
+		if n.Type == html.ElementNode && rec.hasSpan && rec.end > rec.start {
+			idx.spans = append(idx.spans, indexedSpan{rec.start, rec.end, n})
+		}
+	}
+	sort.Slice(idx.spans, func(i, j int) bool {
+		a, b := idx.spans[i], idx.spans[j]
+		if a.start != b.start {
+			return a.start < b.start
+		}
+		// the outer elements first, in document order
+		return a.end > b.end
+	})
+	idx.maxEnd = make([]int, len(idx.spans))
+	idx.build(0, len(idx.spans))
+	return idx
+}
+
+func (idx *spanIndex) build(lo, hi int) int {
+	if lo >= hi {
+		return -1
+	}
+	mid := (lo + hi) / 2
+	idx.maxEnd[mid] = max(idx.spans[mid].end, idx.build(lo, mid), idx.build(mid+1, hi))
+	return idx.maxEnd[mid]
+}
+
+// overlapping calls f with the nodes whose span overlaps the range from start
+// to end, in the order of their start offsets.
+func (idx *spanIndex) overlapping(start, end int, f func(*html.Node)) {
+	var visit func(lo, hi int)
+	visit = func(lo, hi int) {
+		if lo >= hi {
+			return
+		}
+		mid := (lo + hi) / 2
+		if idx.maxEnd[mid] <= start {
+			// all the spans of the subtree end before the range
+			return
+		}
+		visit(lo, mid)
+		if span := idx.spans[mid]; span.start < end {
+			if span.end > start {
+				f(span.node)
+			}
+			visit(mid+1, hi)
+		}
+	}
+	visit(0, len(idx.spans))
+}
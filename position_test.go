@@ -0,0 +1,184 @@
+package goquery
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPosition(t *testing.T) {
+	src := "<!DOCTYPE html>\n<html>\n<body>\n  <div id=a>Hi <b>there</b></div>\n  <ul><li>one\n  <li>two</ul>\n</body>\n</html>"
+	doc, err := NewDocumentFromReaderWithOptions(strings.NewReader(src), DocumentOptions{Positions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		sel  *Selection
+		text string
+		pos  string
+	}{
+		{doc.Find("div"), `<div id=a>Hi <b>there</b></div>`, "4:3-4:34"},
+		{doc.Find("b"), `<b>there</b>`, "4:16-4:28"},
+		{doc.Find("div").Contents().First(), `Hi `, "4:13-4:16"},
+		// the end tag of the first li is omitted
+		{doc.Find("li").First(), "<li>one\n  ", "5:7-6:3"},
+		{doc.Find("li").Last(), `<li>two`, "6:3-6:10"},
+		{doc.Find("html"), src[16:], "2:1-8:8"},
+	}
+	for _, c := range cases {
+		p := c.sel.Position()
+		if got := src[p.Offset:p.EndOffset]; got != c.text {
+			t.Errorf("want span %q, got %q", c.text, got)
+		}
+		if got := p.String(); got != c.pos {
+			t.Errorf("%s: want position %s, got %s", c.text, c.pos, got)
+		}
+	}
+
+	// the head is inserted by the parser, with no content
+	if p := doc.Find("head").Position(); !p.Synthetic {
+		t.Errorf("want a synthetic position for the head, got %s", p)
+	}
+}
+
+func TestPositionSynthetic(t *testing.T) {
+	doc, err := NewDocumentFromReaderWithOptions(strings.NewReader("<p>a</p>"), DocumentOptions{Positions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Find("p").AppendHtml("<b>new</b>").Clone().AppendTo("body")
+
+	for _, sel := range []*Selection{doc.Find("b"), doc.Find("p").Last(), doc.Find("span")} {
+		if p := sel.Position(); !p.Synthetic || p.String() != "synthetic" {
+			t.Errorf("want a synthetic position, got %s", p)
+		}
+	}
+	if p := doc.Find("p").First().Position(); p.Synthetic {
+		t.Error("want the position of the parsed node")
+	}
+
+	// positions are not recorded by default
+	if p := Doc().Find("body").Position(); !p.Synthetic {
+		t.Errorf("want a synthetic position, got %s", p)
+	}
+}
+
+func TestNodeAt(t *testing.T) {
+	src := "<html><body>\n<div id=a>Hi <b>there</b></div>\n<p>one</p><p>two</p>\n</body></html>"
+	doc, err := NewDocumentFromReaderWithOptions(strings.NewReader(src), DocumentOptions{Positions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		offset int
+		want   string
+	}{
+		{strings.Index(src, "Hi"), "div"},
+		{strings.Index(src, "there"), "b"},
+		{strings.Index(src, "<b>"), "b"},
+		{strings.Index(src, "</div>") + 5, "div"},
+		{strings.Index(src, "\n<p>"), "body"},
+		{0, "html"},
+		{len(src), ""},
+	}
+	for _, c := range cases {
+		if got := doc.NodeAt(c.offset); (got.Length() == 0 && c.want != "") || (got.Length() > 0 && NodeName(got) != c.want) {
+			t.Errorf("%d: want %q, got %q", c.offset, c.want, NodeName(got))
+		}
+	}
+
+	// the elements removed from the document are not returned
+	doc.Find("b").Remove()
+	if got := NodeName(doc.NodeAt(strings.Index(src, "there"))); got != "div" {
+		t.Errorf("want div, got %q", got)
+	}
+	if got := Doc().NodeAt(10); got.Length() != 0 {
+		t.Error("want no node without positions")
+	}
+}
+
+func TestNodeAtConcurrent(t *testing.T) {
+	src := "<div><p>one</p><p>two</p></div>"
+	doc, err := NewDocumentFromReaderWithOptions(strings.NewReader(src), DocumentOptions{Positions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the index of the spans is built by the first lookup
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := doc.NodeAt(strings.Index(src, "two")).Text(); got != "two" {
+				t.Errorf("want two, got %q", got)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestNodesInRange(t *testing.T) {
+	src := "<div><p>one <b>two</b></p><p>three</p></div><ul><li>a</li></ul>"
+	doc, err := NewDocumentFromReaderWithOptions(strings.NewReader(src), DocumentOptions{Positions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		start, end int
+		want       string
+	}{
+		{strings.Index(src, "one"), strings.Index(src, "three"), "b,p"},
+		{strings.Index(src, "one"), strings.Index(src, "<b>"), "p"},
+		{strings.Index(src, "two"), len(src), "b,p,li"},
+		{strings.Index(src, "a</li>"), strings.Index(src, "a</li>"), "li"},
+	}
+	for _, c := range cases {
+		var names []string
+		doc.NodesInRange(c.start, c.end).Each(func(_ int, s *Selection) {
+			names = append(names, NodeName(s))
+		})
+		if got := strings.Join(names, ","); got != c.want {
+			t.Errorf("%d-%d: want %s, got %s", c.start, c.end, c.want, got)
+		}
+	}
+}
+
+func TestNodeAtLargeDocument(t *testing.T) {
+	f, err := os.Open("testdata/gowiki.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := NewDocumentFromReaderWithOptions(f, DocumentOptions{Positions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// compare with a linear search of the deepest element covering the offset
+	all := doc.Find("*")
+	for offset := 0; offset < len(doc.source.src); offset += 997 {
+		var want *Selection
+		depth := -1
+		all.Each(func(_ int, s *Selection) {
+			p := s.Position()
+			if !p.Synthetic && p.Offset <= offset && offset < p.EndOffset && nodeDepth(s.Nodes[0]) > depth {
+				want, depth = s, nodeDepth(s.Nodes[0])
+			}
+		})
+		got := doc.NodeAt(offset)
+		if want == nil {
+			if got.Length() != 0 {
+				t.Errorf("%d: want no element, got %s", offset, NodeName(got))
+			}
+			continue
+		}
+		if got.Length() != 1 || got.Nodes[0] != want.Nodes[0] {
+			t.Errorf("%d: want %s at %s, got %s", offset, NodeName(want), want.Position(), NodeName(got))
+		}
+	}
+}
@@ -31,7 +31,9 @@ func (s *Selection) AttrOr(attrName, defaultValue string) string {
 // RemoveAttr removes the named attribute from each element in the set of matched elements.
 func (s *Selection) RemoveAttr(attrName string) *Selection {
 	for _, n := range s.Nodes {
+		old, had := attrValue(n, attrName)
 		removeAttr(n, attrName)
+		s.document.attrChanged(n, attrName, old, had)
 	}
 
 	return s
@@ -43,8 +45,65 @@ func (s *Selection) SetAttr(attrName, val string) *Selection {
 		attr := getAttributePtr(attrName, n)
 		if attr == nil {
 			n.Attr = append(n.Attr, html.Attribute{Key: attrName, Val: val})
+			s.document.attrChanged(n, attrName, "", false)
 		} else {
+			old := attr.Val
 			attr.Val = val
+			s.document.attrChanged(n, attrName, old, true)
+		}
+	}
+
+	return s
+}
+
+// AttrNS gets the value of the attribute of the specified namespace and local
+// name for the first element in the Selection, e.g. AttrNS("xlink", "href")
+// for the xlink:href attribute of SVG. Unlike Attr, which only compares the
+// names and returns the first attribute of that name in any namespace, it
+// tells apart the attributes of the same name in different namespaces. The
+// namespace is either the short name set by the HTML parser ("xlink", "xml"
+// or "xmlns"), the URL of the namespace, or empty for the attributes without
+// a namespace.
+func (s *Selection) AttrNS(ns, attrName string) (string, bool) {
+	if len(s.Nodes) != 0 {
+		if attr := getAttributeNSPtr(shortNamespace(ns), attrName, s.Nodes[0]); attr != nil {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
+
+// RemoveAttrNS removes the attribute of the specified namespace and local name
+// from each element in the set of matched elements. The namespace is as for
+// AttrNS.
+func (s *Selection) RemoveAttrNS(ns, attrName string) *Selection {
+	ns = shortNamespace(ns)
+	for _, n := range s.Nodes {
+		var old string
+		attr := getAttributeNSPtr(ns, attrName, n)
+		if attr != nil {
+			old = attr.Val
+			removeAttrNS(n, ns, attrName)
+		}
+		s.document.attrChangedNS(n, ns, attrName, old, attr != nil)
+	}
+
+	return s
+}
+
+// SetAttrNS sets the attribute of the specified namespace and local name on
+// each element in the set of matched elements. The namespace is as for AttrNS.
+func (s *Selection) SetAttrNS(ns, attrName, val string) *Selection {
+	ns = shortNamespace(ns)
+	for _, n := range s.Nodes {
+		attr := getAttributeNSPtr(ns, attrName, n)
+		if attr == nil {
+			n.Attr = append(n.Attr, html.Attribute{Namespace: ns, Key: attrName, Val: val})
+			s.document.attrChangedNS(n, ns, attrName, "", false)
+		} else {
+			old := attr.Val
+			attr.Val = val
+			s.document.attrChangedNS(n, ns, attrName, old, true)
 		}
 	}
 
@@ -83,15 +142,20 @@ func (s *Selection) Length() int {
 }
 
 // Html gets the HTML contents of the first element in the set of matched
-// elements. It includes text and comment nodes.
+// elements. It includes text and comment nodes. The contents of the elements
+// of the documents created by NewXMLDocumentFromReader are rendered as XML.
 func (s *Selection) Html() (ret string, e error) {
 	// Since there is no .innerHtml, the HTML content must be re-created from
 	// the nodes using html.Render.
 	var builder strings.Builder
 
 	if len(s.Nodes) > 0 {
+		render := html.Render
+		if s.isXML() {
+			render = renderXML
+		}
 		for c := s.Nodes[0].FirstChild; c != nil; c = c.NextSibling {
-			e = html.Render(&builder, c)
+			e = render(&builder, c)
 			if e != nil {
 				return
 			}
@@ -113,6 +177,7 @@ func (s *Selection) AddClass(class ...string) *Selection {
 
 	tcls := getClassesSlice(classStr)
 	for _, n := range s.Nodes {
+		old, had := attrValue(n, "class")
 		curClasses, attr := getClassesAndAttr(n)
 		for _, newClass := range tcls {
 			if !strings.Contains(curClasses, " "+newClass+" ") {
@@ -121,6 +186,7 @@ func (s *Selection) AddClass(class ...string) *Selection {
 		}
 
 		setClasses(n, attr, curClasses)
+		s.document.attrChanged(n, "class", old, had)
 	}
 
 	return s
@@ -162,6 +228,7 @@ func (s *Selection) RemoveClass(class ...string) *Selection {
 	}
 
 	for _, n := range s.Nodes {
+		old, had := attrValue(n, "class")
 		if remove {
 			removeAttr(n, "class")
 		} else {
@@ -172,6 +239,7 @@ func (s *Selection) RemoveClass(class ...string) *Selection {
 
 			setClasses(n, attr, classes)
 		}
+		s.document.attrChanged(n, "class", old, had)
 	}
 
 	return s
@@ -189,6 +257,7 @@ func (s *Selection) ToggleClass(class ...string) *Selection {
 	tcls := getClassesSlice(classStr)
 
 	for _, n := range s.Nodes {
+		old, had := attrValue(n, "class")
 		classes, attr := getClassesAndAttr(n)
 		for _, tcl := range tcls {
 			spaceAroundTcl := " " + tcl + " "
@@ -200,6 +269,7 @@ func (s *Selection) ToggleClass(class ...string) *Selection {
 		}
 
 		setClasses(n, attr, classes)
+		s.document.attrChanged(n, "class", old, had)
 	}
 
 	return s
@@ -218,6 +288,19 @@ func getAttributePtr(attrName string, n *html.Node) *html.Attribute {
 	return nil
 }
 
+func getAttributeNSPtr(ns, attrName string, n *html.Node) *html.Attribute {
+	if n == nil {
+		return nil
+	}
+
+	for i, a := range n.Attr {
+		if a.Namespace == ns && a.Key == attrName {
+			return &n.Attr[i]
+		}
+	}
+	return nil
+}
+
 // Get and normalize the "class" attribute from the node.
 func getClassesAndAttr(n *html.Node) (classes string, attr *html.Attribute) {
 	// Applies only to element nodes
@@ -255,6 +338,16 @@ func removeAttr(n *html.Node, attrName string) {
 	}
 }
 
+func removeAttrNS(n *html.Node, ns, attrName string) {
+	for i, a := range n.Attr {
+		if a.Namespace == ns && a.Key == attrName {
+			n.Attr[i], n.Attr[len(n.Attr)-1], n.Attr =
+				n.Attr[len(n.Attr)-1], html.Attribute{}, n.Attr[:len(n.Attr)-1]
+			return
+		}
+	}
+}
+
 func setClasses(n *html.Node, attr *html.Attribute, classes string) {
 	classes = strings.TrimSpace(classes)
 	if classes == "" {
@@ -250,3 +250,40 @@ func TestToggleClass(t *testing.T) {
 		t.Errorf("Expected #nf1 to have no classes, have %q", a)
 	}
 }
+
+func TestAttrNS(t *testing.T) {
+	doc := loadString(t, `<svg><a href="/plain" xlink:href="#x"></a></svg>`)
+	sel := doc.Find("a")
+
+	if val, ok := sel.AttrNS("xlink", "href"); !ok || val != "#x" {
+		t.Errorf("want xlink:href %q, got %q %v", "#x", val, ok)
+	}
+	if val, ok := sel.AttrNS("http://www.w3.org/1999/xlink", "href"); !ok || val != "#x" {
+		t.Errorf("want xlink:href by URL %q, got %q %v", "#x", val, ok)
+	}
+	if val, ok := sel.AttrNS("", "href"); !ok || val != "/plain" {
+		t.Errorf("want href %q, got %q %v", "/plain", val, ok)
+	}
+	if _, ok := sel.AttrNS("xml", "href"); ok {
+		t.Error("want no xml:href attribute")
+	}
+
+	sel.SetAttrNS("xlink", "href", "#y").SetAttrNS("xlink", "title", "t")
+	if val, _ := sel.AttrNS("xlink", "href"); val != "#y" {
+		t.Errorf("want xlink:href %q, got %q", "#y", val)
+	}
+	if val, _ := sel.AttrNS("", "href"); val != "/plain" {
+		t.Errorf("want href unchanged, got %q", val)
+	}
+	if h, _ := OuterHtml(sel); h != `<a href="/plain" xlink:href="#y" xlink:title="t"></a>` {
+		t.Errorf("unexpected html %q", h)
+	}
+
+	sel.RemoveAttrNS("xlink", "href")
+	if _, ok := sel.AttrNS("xlink", "href"); ok {
+		t.Error("want xlink:href removed")
+	}
+	if _, ok := sel.AttrNS("", "href"); !ok {
+		t.Error("want href kept")
+	}
+}
@@ -0,0 +1,505 @@
+package goquery
+
+import (
+	"errors"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// ErrRangeBoundary is returned by the methods of Range when a boundary point
+// is invalid: the selection is empty, its node is a doctype or has no parent
+// when one is required, or the offset is out of bounds.
+var ErrRangeBoundary = errors.New("goquery: invalid range boundary point")
+
+// Range is a range of the content of a document, like the Range of the DOM.
+// It goes from a start to an end boundary point, each made of a node and an
+// offset in that node: the offset is a number of children, or a number of
+// bytes in the text of the text and comment nodes.
+//
+// For example, the content between the h2 with id "intro" and the next h2,
+// whatever their depth, is extracted with:
+//
+//	h2, intro := doc.Find("h2"), doc.Find("h2#intro")
+//	r := doc.NewRange()
+//	r.SetStartAfter(intro)
+//	r.SetEndBefore(h2.Eq(h2.IndexOfSelection(intro) + 1))
+//	content := r.Extract()
+//
+// Unlike the DOM ranges, a Range is not updated when the document is changed
+// by other means than its own methods. Its offsets are then clamped to the
+// length of their node, and it is collapsed at its start if its boundary
+// points are no longer in the same tree, or in order, when its content is
+// used.
+type Range struct {
+	document    *Document
+	startNode   *html.Node
+	startOffset int
+	endNode     *html.Node
+	endOffset   int
+}
+
+// NewRange returns a new Range of the document, collapsed at the start of the
+// document.
+func (d *Document) NewRange() *Range {
+	return &Range{document: d, startNode: d.rootNode, endNode: d.rootNode}
+}
+
+// StartContainer returns the node of the start boundary point of the range.
+func (r *Range) StartContainer() *Selection {
+	return newSingleSelection(r.startNode, r.document)
+}
+
+// StartOffset returns the offset of the start boundary point of the range.
+func (r *Range) StartOffset() int {
+	return r.startOffset
+}
+
+// EndContainer returns the node of the end boundary point of the range.
+func (r *Range) EndContainer() *Selection {
+	return newSingleSelection(r.endNode, r.document)
+}
+
+// EndOffset returns the offset of the end boundary point of the range.
+func (r *Range) EndOffset() int {
+	return r.endOffset
+}
+
+// Collapsed returns true if the start and end boundary points of the range
+// are the same.
+func (r *Range) Collapsed() bool {
+	return r.startNode == r.endNode && r.startOffset == r.endOffset
+}
+
+// Collapse sets the end boundary point of the range to its start if toStart
+// is true, and the start to the end otherwise.
+func (r *Range) Collapse(toStart bool) {
+	if toStart {
+		r.endNode, r.endOffset = r.startNode, r.startOffset
+	} else {
+		r.startNode, r.startOffset = r.endNode, r.endOffset
+	}
+}
+
+// SetStart sets the start boundary point of the range to the offset in the
+// first node of the selection. If it is after the end of the range, or in
+// another tree, the range is collapsed at the new start.
+func (r *Range) SetStart(s *Selection, offset int) error {
+	if len(s.Nodes) == 0 {
+		return ErrRangeBoundary
+	}
+	return r.setStart(s.Nodes[0], offset)
+}
+
+// SetEnd sets the end boundary point of the range to the offset in the first
+// node of the selection. If it is before the start of the range, or in
+// another tree, the range is collapsed at the new end.
+func (r *Range) SetEnd(s *Selection, offset int) error {
+	if len(s.Nodes) == 0 {
+		return ErrRangeBoundary
+	}
+	return r.setEnd(s.Nodes[0], offset)
+}
+
+// SetStartBefore sets the start of the range just before the first node of
+// the selection.
+func (r *Range) SetStartBefore(s *Selection) error {
+	parent, i, err := childBoundary(s)
+	if err != nil {
+		return err
+	}
+	return r.setStart(parent, i)
+}
+
+// SetStartAfter sets the start of the range just after the first node of the
+// selection.
+func (r *Range) SetStartAfter(s *Selection) error {
+	parent, i, err := childBoundary(s)
+	if err != nil {
+		return err
+	}
+	return r.setStart(parent, i+1)
+}
+
+// SetEndBefore sets the end of the range just before the first node of the
+// selection.
+func (r *Range) SetEndBefore(s *Selection) error {
+	parent, i, err := childBoundary(s)
+	if err != nil {
+		return err
+	}
+	return r.setEnd(parent, i)
+}
+
+// SetEndAfter sets the end of the range just after the first node of the
+// selection.
+func (r *Range) SetEndAfter(s *Selection) error {
+	parent, i, err := childBoundary(s)
+	if err != nil {
+		return err
+	}
+	return r.setEnd(parent, i+1)
+}
+
+// SelectNode sets the range to contain the first node of the selection.
+func (r *Range) SelectNode(s *Selection) error {
+	parent, i, err := childBoundary(s)
+	if err != nil {
+		return err
+	}
+	r.startNode, r.startOffset = parent, i
+	r.endNode, r.endOffset = parent, i+1
+	return nil
+}
+
+// SelectNodeContents sets the range to contain the content of the first node
+// of the selection.
+func (r *Range) SelectNodeContents(s *Selection) error {
+	if len(s.Nodes) == 0 || s.Nodes[0].Type == html.DoctypeNode {
+		return ErrRangeBoundary
+	}
+	n := s.Nodes[0]
+	r.startNode, r.startOffset = n, 0
+	r.endNode, r.endOffset = n, nodeLength(n)
+	return nil
+}
+
+// CommonAncestor returns the deepest node that contains the start and end
+// boundary points of the range.
+func (r *Range) CommonAncestor() *Selection {
+	r.normalize()
+	return pushStack(r.document.Selection, []*html.Node{r.commonAncestor()})
+}
+
+// Contents returns the nodes that are entirely in the range, without their
+// descendants, in document order. The text and comment nodes that are only
+// partly in the range are not returned.
+func (r *Range) Contents() *Selection {
+	r.normalize()
+	var nodes []*html.Node
+	var collect func(sn *html.Node, so int, en *html.Node, eo int)
+	collect = func(sn *html.Node, so int, en *html.Node, eo int) {
+		if sn == en && (so == eo || isCharacterData(sn)) {
+			return
+		}
+		_, first, contained, last := splitRange(sn, so, en, eo)
+		if first != nil && !isCharacterData(first) {
+			collect(sn, so, first, nodeLength(first))
+		}
+		nodes = append(nodes, contained...)
+		if last != nil && !isCharacterData(last) {
+			collect(last, 0, en, eo)
+		}
+	}
+	collect(r.startNode, r.startOffset, r.endNode, r.endOffset)
+	return pushStack(r.document.Selection, nodes)
+}
+
+// Clone returns a copy of the content of the range, like cloneContents in the
+// DOM: the nodes entirely in the range are deep-cloned, and the nodes that
+// are partly in it are cloned with only their content in the range. The
+// document is not changed. It returns the top-level nodes of the copy, which
+// have no parent.
+func (r *Range) Clone() *Selection {
+	r.normalize()
+	frag := &html.Node{Type: html.DocumentNode}
+	r.contents(r.startNode, r.startOffset, r.endNode, r.endOffset, frag, false)
+	return pushStack(r.document.Selection, detachChildren(frag))
+}
+
+// Extract removes the content of the range from the document and returns it,
+// like extractContents in the DOM. The nodes entirely in the range are moved
+// out of the document, and the nodes that are partly in it stay in the
+// document, with their content in the range moved to clones of them. It
+// returns the top-level nodes of the content, which have no parent, and
+// collapses the range where the content was.
+func (r *Range) Extract() *Selection {
+	frag := &html.Node{Type: html.DocumentNode}
+	r.extract(frag)
+	return pushStack(r.document.Selection, detachChildren(frag))
+}
+
+// Delete removes the content of the range from the document, like
+// deleteContents in the DOM, and collapses the range where the content was.
+func (r *Range) Delete() {
+	r.extract(&html.Node{Type: html.DocumentNode})
+}
+
+func (r *Range) setStart(n *html.Node, offset int) error {
+	if err := checkBoundary(n, offset); err != nil {
+		return err
+	}
+	if rootNode(n) != rootNode(r.endNode) || compareBoundaries(n, offset, r.endNode, r.endOffset) > 0 {
+		r.endNode, r.endOffset = n, offset
+	}
+	r.startNode, r.startOffset = n, offset
+	return nil
+}
+
+func (r *Range) setEnd(n *html.Node, offset int) error {
+	if err := checkBoundary(n, offset); err != nil {
+		return err
+	}
+	if rootNode(n) != rootNode(r.startNode) || compareBoundaries(n, offset, r.startNode, r.startOffset) < 0 {
+		r.startNode, r.startOffset = n, offset
+	}
+	r.endNode, r.endOffset = n, offset
+	return nil
+}
+
+// normalize makes the boundary points of the range valid again after changes
+// of the document by other means than its own methods.
+func (r *Range) normalize() {
+	r.startOffset = clampOffset(r.startNode, r.startOffset)
+	r.endOffset = clampOffset(r.endNode, r.endOffset)
+	if rootNode(r.startNode) != rootNode(r.endNode) ||
+		compareBoundaries(r.startNode, r.startOffset, r.endNode, r.endOffset) > 0 {
+		r.Collapse(true)
+	}
+}
+
+func (r *Range) commonAncestor() *html.Node {
+	ca := r.startNode
+	for !nodeContainsOrIs(ca, r.endNode) {
+		ca = ca.Parent
+	}
+	return ca
+}
+
+// extract moves the content of the range to frag, and collapses the range.
+func (r *Range) extract(frag *html.Node) {
+	r.normalize()
+	sn, so, en := r.startNode, r.startOffset, r.endNode
+	if r.Collapsed() {
+		return
+	}
+
+	// the range is collapsed after the partly contained ancestor of the
+	// start, if any
+	if !nodeContainsOrIs(sn, en) {
+		ref := sn
+		for !nodeContainsOrIs(ref.Parent, en) {
+			ref = ref.Parent
+		}
+		sn, so = ref.Parent, childIndex(ref)+1
+	}
+	r.contents(r.startNode, r.startOffset, r.endNode, r.endOffset, frag, true)
+	r.startNode, r.startOffset = sn, so
+	r.endNode, r.endOffset = sn, so
+}
+
+// contents appends the content of the range from (sn, so) to (en, eo) to
+// frag, moving it out of the document if extract is true, as described by
+// the DOM algorithms to clone and extract the content of a range.
+func (r *Range) contents(sn *html.Node, so int, en *html.Node, eo int, frag *html.Node, extract bool) {
+	if sn == en && so == eo {
+		return
+	}
+	d := r.document
+	if sn == en && isCharacterData(sn) {
+		frag.AppendChild(cloneCharacterData(sn, sn.Data[so:eo]))
+		if extract {
+			d.setData(sn, sn.Data[:so]+sn.Data[eo:])
+		}
+		return
+	}
+
+	ca, first, contained, last := splitRange(sn, so, en, eo)
+	if first != nil {
+		if isCharacterData(first) {
+			frag.AppendChild(cloneCharacterData(sn, sn.Data[so:]))
+			if extract {
+				d.setData(sn, sn.Data[:so])
+			}
+		} else {
+			clone := shallowClone(first)
+			frag.AppendChild(clone)
+			r.contents(sn, so, first, nodeLength(first), clone, extract)
+		}
+	}
+	for _, c := range contained {
+		if extract {
+			d.removeChild(ca, c)
+			frag.AppendChild(c)
+		} else {
+			frag.AppendChild(cloneNode(c))
+		}
+	}
+	if last != nil {
+		if isCharacterData(last) {
+			frag.AppendChild(cloneCharacterData(en, en.Data[:eo]))
+			if extract {
+				d.setData(en, en.Data[eo:])
+			}
+		} else {
+			clone := shallowClone(last)
+			frag.AppendChild(clone)
+			r.contents(last, 0, en, eo, clone, extract)
+		}
+	}
+}
+
+// splitRange returns the common ancestor of the boundary points (sn, so) and
+// (en, eo), and its children that contain the start or the end but not both
+// (first and last, which may be nil) and that are entirely in the range
+// (contained).
+func splitRange(sn *html.Node, so int, en *html.Node, eo int) (ca, first *html.Node, contained []*html.Node, last *html.Node) {
+	ca = sn
+	for !nodeContainsOrIs(ca, en) {
+		ca = ca.Parent
+	}
+
+	begin, end := childAt(ca, so), childAt(ca, eo)
+	if ca != sn {
+		first = sn
+		for first.Parent != ca {
+			first = first.Parent
+		}
+		begin = first.NextSibling
+	}
+	if ca != en {
+		last = en
+		for last.Parent != ca {
+			last = last.Parent
+		}
+		end = last
+	}
+	for c := begin; c != nil && c != end; c = c.NextSibling {
+		contained = append(contained, c)
+	}
+	return ca, first, contained, last
+}
+
+// compareBoundaries returns -1, 0 or 1 if the boundary point (a, ao) is
+// before, equal to or after (b, bo), which are in the same tree.
+func compareBoundaries(a *html.Node, ao int, b *html.Node, bo int) int {
+	if a == b {
+		switch {
+		case ao < bo:
+			return -1
+		case ao > bo:
+			return 1
+		}
+		return 0
+	}
+	pos := CompareDocumentPosition(a, b)
+	if pos&DocumentPositionPreceding != 0 {
+		return -compareBoundaries(b, bo, a, ao)
+	}
+	if pos&DocumentPositionContainedBy != 0 {
+		child := b
+		for child.Parent != a {
+			child = child.Parent
+		}
+		if childIndex(child) < ao {
+			return 1
+		}
+	}
+	return -1
+}
+
+// checkBoundary returns an error if (n, offset) is not a valid boundary
+// point.
+func checkBoundary(n *html.Node, offset int) error {
+	if n.Type == html.DoctypeNode || offset < 0 || offset > nodeLength(n) {
+		return ErrRangeBoundary
+	}
+	if isCharacterData(n) && offset < len(n.Data) && !utf8.RuneStart(n.Data[offset]) {
+		// the offset splits a character
+		return ErrRangeBoundary
+	}
+	return nil
+}
+
+// clampOffset returns the offset, at most the length of n and, in a text or
+// comment node, at the start of a character.
+func clampOffset(n *html.Node, offset int) int {
+	if l := nodeLength(n); offset > l {
+		offset = l
+	}
+	if isCharacterData(n) {
+		for offset > 0 && offset < len(n.Data) && !utf8.RuneStart(n.Data[offset]) {
+			offset--
+		}
+	}
+	return offset
+}
+
+// childBoundary returns the parent of the first node of the selection and its
+// index in the children of the parent.
+func childBoundary(s *Selection) (*html.Node, int, error) {
+	if len(s.Nodes) == 0 || s.Nodes[0].Parent == nil {
+		return nil, 0, ErrRangeBoundary
+	}
+	return s.Nodes[0].Parent, childIndex(s.Nodes[0]), nil
+}
+
+// nodeLength returns the length of n as defined by the DOM: the number of
+// bytes of the text of a text or comment node, 0 for a doctype, and the
+// number of children otherwise.
+func nodeLength(n *html.Node) int {
+	switch {
+	case isCharacterData(n):
+		return len(n.Data)
+	case n.Type == html.DoctypeNode:
+		return 0
+	}
+	l := 0
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		l++
+	}
+	return l
+}
+
+// childIndex returns the index of n in the children of its parent.
+func childIndex(n *html.Node) int {
+	i := 0
+	for sib := n.PrevSibling; sib != nil; sib = sib.PrevSibling {
+		i++
+	}
+	return i
+}
+
+// childAt returns the child of n at the index i, or nil if there is none.
+func childAt(n *html.Node, i int) *html.Node {
+	c := n.FirstChild
+	for ; c != nil && i > 0; i-- {
+		c = c.NextSibling
+	}
+	return c
+}
+
+// nodeContainsOrIs returns true if n is an inclusive ancestor of other.
+func nodeContainsOrIs(n, other *html.Node) bool {
+	return n == other || nodeContains(n, other)
+}
+
+func isCharacterData(n *html.Node) bool {
+	return n.Type == html.TextNode || n.Type == html.CommentNode
+}
+
+func cloneCharacterData(n *html.Node, data string) *html.Node {
+	return &html.Node{Type: n.Type, Data: data}
+}
+
+// shallowClone returns a copy of n without its children.
+func shallowClone(n *html.Node) *html.Node {
+	nn := &html.Node{
+		Type:      n.Type,
+		DataAtom:  n.DataAtom,
+		Data:      n.Data,
+		Namespace: n.Namespace,
+		Attr:      make([]html.Attribute, len(n.Attr)),
+	}
+	copy(nn.Attr, n.Attr)
+	return nn
+}
+
+// detachChildren removes the children of n and returns them.
+func detachChildren(n *html.Node) []*html.Node {
+	nodes := childNodes(n)
+	for _, c := range nodes {
+		n.RemoveChild(c)
+	}
+	return nodes
+}
@@ -0,0 +1,190 @@
+package goquery
+
+import (
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func renderNodes(t *testing.T, s *Selection) string {
+	var out string
+	for _, n := range s.Nodes {
+		h, err := OuterHtml(newSingleSelection(n, s.document))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out += h
+	}
+	return out
+}
+
+func TestRangeExtractBetweenHeadings(t *testing.T) {
+	doc := loadString(t, `<div><h2 id="intro">Intro</h2><p>one</p><section><p>two</p><h2>Next</h2><p>three</p></section></div>`)
+	h2, intro := doc.Find("h2"), doc.Find("h2#intro")
+	r := doc.NewRange()
+	if err := r.SetStartAfter(intro); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetEndBefore(h2.Eq(h2.IndexOfSelection(intro) + 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := NodeName(r.CommonAncestor()); got != "div" {
+		t.Errorf("want div, got %s", got)
+	}
+	if got := renderNodes(t, r.Contents()); got != "<p>one</p><p>two</p>" {
+		t.Errorf("want the contained nodes, got %s", got)
+	}
+	if got := renderNodes(t, r.Clone()); got != "<p>one</p><section><p>two</p></section>" {
+		t.Errorf("want a clone of the content, got %s", got)
+	}
+
+	got := r.Extract()
+	if h := renderNodes(t, got); h != "<p>one</p><section><p>two</p></section>" {
+		t.Errorf("want the extracted content, got %s", h)
+	}
+	for _, n := range got.Nodes {
+		if n.Parent != nil {
+			t.Error("want the extracted nodes detached")
+		}
+	}
+	if h, _ := doc.Find("div").Html(); h != `<h2 id="intro">Intro</h2><section><h2>Next</h2><p>three</p></section>` {
+		t.Errorf("want the content removed from the document, got %s", h)
+	}
+	if !r.Collapsed() || r.StartContainer().Nodes[0] != doc.Find("div").Nodes[0] || r.StartOffset() != 1 {
+		t.Errorf("want the range collapsed after the intro, got %s %d", NodeName(r.StartContainer()), r.StartOffset())
+	}
+}
+
+func TestRangeText(t *testing.T) {
+	doc := loadString(t, `<p>Hello <b>big</b> world</p>`)
+	p := doc.Find("p")
+	r := doc.NewRange()
+	if err := r.SetStart(p.Contents().First(), 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetEnd(doc.Find("b").Contents(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := renderNodes(t, r.Contents()); got != "" {
+		t.Errorf("want no contained nodes, got %s", got)
+	}
+	if got := renderNodes(t, r.Clone()); got != "lo <b>b</b>" {
+		t.Errorf("want the cloned content, got %s", got)
+	}
+	r.Delete()
+	if h, _ := p.Html(); h != "Hel<b>ig</b> world" {
+		t.Errorf("want the content deleted, got %s", h)
+	}
+
+	// a range in a single text node
+	r.SelectNodeContents(doc.Find("b").Contents())
+	if err := r.SetStart(doc.Find("b").Contents(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := renderNodes(t, r.Extract()); got != "g" {
+		t.Errorf("want g, got %s", got)
+	}
+	if got := doc.Find("b").Text(); got != "i" {
+		t.Errorf("want i, got %s", got)
+	}
+}
+
+func TestRangeBoundaries(t *testing.T) {
+	doc := loadString(t, `<p>a</p><p>b</p><p>été</p>`)
+	p := doc.Find("p")
+	r := doc.NewRange()
+	if err := r.SelectNode(p.Eq(1)); err != nil {
+		t.Fatal(err)
+	}
+	if got := renderNodes(t, r.Contents()); got != "<p>b</p>" {
+		t.Errorf("want the selected node, got %s", got)
+	}
+
+	// a start after the end collapses the range
+	if err := r.SetStart(p.Eq(2), 0); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Collapsed() || r.EndContainer().Nodes[0] != p.Nodes[2] {
+		t.Error("want the range collapsed at the new start")
+	}
+	if err := r.SetEndBefore(p.Eq(0)); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Collapsed() || r.StartContainer().Nodes[0] != p.Nodes[0].Parent {
+		t.Error("want the range collapsed at the new end")
+	}
+	r.Collapse(true)
+
+	for i, err := range []error{
+		r.SetStart(p.Eq(0), 2),
+		r.SetEnd(p.Eq(2).Contents(), 1),
+		r.SetStartBefore(doc.Selection),
+		r.SelectNode(p.Eq(5)),
+		r.SelectNodeContents(newSingleSelection(&html.Node{Type: html.DoctypeNode}, doc)),
+	} {
+		if err != ErrRangeBoundary {
+			t.Errorf("%d: want ErrRangeBoundary, got %v", i, err)
+		}
+	}
+}
+
+func TestRangeChangedDocument(t *testing.T) {
+	doc := loadString(t, `<div><p>hello</p><p>world</p></div><div>x</div>`)
+	start := doc.Find("p").First().Contents().Nodes[0]
+	r := doc.NewRange()
+	if err := r.SetStart(doc.Find("p").First().Contents(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetEnd(doc.Find("p").Last().Contents(), 4); err != nil {
+		t.Fatal(err)
+	}
+
+	// the text of the end is shortened
+	doc.Find("p").Last().Contents().Nodes[0].Data = "w"
+	if got := renderNodes(t, r.Clone()); got != "<p>ello</p><p>w</p>" {
+		t.Errorf("want the end offset clamped, got %s", got)
+	}
+
+	// the end is no longer in the document
+	doc.Find("p").Last().SetText("w")
+	if got := r.Contents().Length(); got != 0 {
+		t.Errorf("want an empty range, got %d nodes", got)
+	}
+	if got := r.Extract().Length(); got != 0 {
+		t.Errorf("want nothing extracted, got %d nodes", got)
+	}
+	if !r.Collapsed() {
+		t.Error("want the range collapsed")
+	}
+
+	if err := r.SetEnd(doc.Find("div").Last(), 1); err != nil {
+		t.Fatal(err)
+	}
+	doc.Find("div").First().Remove()
+	r.Delete()
+	if got := r.CommonAncestor().Nodes[0]; got != start {
+		t.Error("want the range collapsed at its start")
+	}
+	if got := doc.Find("div").Text(); got != "x" {
+		t.Errorf("want the document unchanged, got %q", got)
+	}
+}
+
+func TestRangeObserved(t *testing.T) {
+	doc := loadString(t, `<p>one</p><p>two</p><p>three</p>`)
+	var types []MutationType
+	doc.Observe(func(r *MutationRecord) { types = append(types, r.Type) })
+
+	p := doc.Find("p")
+	r := doc.NewRange()
+	r.SetStart(p.Eq(0).Contents(), 1)
+	r.SetEnd(p.Eq(2).Contents(), 2)
+	r.Delete()
+	if len(types) != 3 || types[0] != MutationCharacterData || types[1] != MutationChildList || types[2] != MutationCharacterData {
+		t.Errorf("want the changes to be observed, got %v", types)
+	}
+	if h, _ := doc.Find("body").Html(); h != "<p>o</p><p>ree</p>" {
+		t.Errorf("want the content deleted, got %s", h)
+	}
+}
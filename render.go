@@ -0,0 +1,647 @@
+package goquery
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// QuoteStyle is the quote character used around attribute values by
+// RenderWithOptions.
+type QuoteStyle int
+
+// Quote styles.
+const (
+	// QuoteDouble quotes attribute values with double quotes.
+	QuoteDouble QuoteStyle = iota
+	// QuoteSingle quotes attribute values with single quotes.
+	QuoteSingle
+)
+
+// VoidStyle is the syntax used by RenderWithOptions for the elements that
+// have no end tag.
+type VoidStyle int
+
+// Void element styles.
+const (
+	// VoidSlash renders void elements as <br/>, like html.Render.
+	VoidSlash VoidStyle = iota
+	// VoidNoSlash renders void elements as <br>.
+	VoidNoSlash
+	// VoidXHTML renders void elements as <br />, and the foreign (SVG and
+	// MathML) elements that have no child as self-closing elements, such as
+	// <circle r="1" />.
+	VoidXHTML
+)
+
+// RenderOptions configures the output of RenderWithOptions. The zero value
+// renders the same HTML as html.Render.
+type RenderOptions struct {
+	// Indent is the string used to indent each level of nested block
+	// elements. If it is empty, the HTML is rendered as-is, otherwise the
+	// block elements are rendered on their own line, and the whitespace
+	// around them is dropped. The inline content, including the block
+	// elements within inline elements, and the elements whose whitespace is
+	// significant, such as pre, textarea or script, are left unchanged.
+	Indent string
+
+	// SortAttributes renders the attributes of each element sorted by
+	// name.
+	SortAttributes bool
+
+	// Quote is the quote character used around attribute values.
+	Quote QuoteStyle
+
+	// Void is the syntax used for the void elements, such as br or img.
+	Void VoidStyle
+
+	// ASCII escapes all non-ASCII characters of the text and attribute
+	// values as numeric character references. The characters in comments
+	// and in the raw text of elements such as script or style cannot be
+	// escaped and are rendered as-is.
+	ASCII bool
+
+	// Minify renders the smallest HTML that parses back to the same tree,
+	// except for the whitespace and comments that it drops: the optional
+	// end tags are omitted, the attribute values are quoted only if
+	// required, the empty and boolean attributes are rendered as a bare
+	// name, the whitespace outside of the elements where it is significant
+	// is collapsed, and dropped next to block elements, and comments are
+	// stripped. Indent and Void are ignored, void elements are rendered as
+	// <br>.
+	Minify bool
+
+	// PreserveSource renders the nodes of a document created by
+	// NewDocumentFromReaderWithSource, or with the Positions option of
+	// NewDocumentFromReaderWithOptions, from their source: the start tags,
+	// end tags, text and comments that are unchanged since the document was
+	// parsed are written as they are in the source, along with the source
+	// between them that is not part of the tree (such as end tags that were
+	// ignored by the parser), so that an unchanged document renders exactly
+	// as its source. Only the changed and new nodes are serialized, with the
+	// other options. Indent and Minify are ignored. It has no effect on the
+	// documents created by other means.
+	PreserveSource bool
+
+	// KeepConditionalComments keeps the Internet Explorer conditional
+	// comments, such as <!--[if IE]>...<![endif]-->, when minifying.
+	KeepConditionalComments bool
+}
+
+// RenderWithOptions renders the HTML of the first item in the selection and
+// writes it to the writer, configured by opts. With the zero RenderOptions,
+// it behaves the same as Render. The nodes of the documents created by
+// NewXMLDocumentFromReader are rendered as XML, and opts is ignored.
+func RenderWithOptions(w io.Writer, s *Selection, opts RenderOptions) error {
+	if s.Length() == 0 {
+		return nil
+	}
+	if s.isXML() {
+		return renderXML(w, s.Get(0))
+	}
+	bw := bufio.NewWriter(w)
+	r := &renderer{w: bw, opts: opts, root: s.Get(0)}
+	if opts.PreserveSource && s.document != nil && s.document.source != nil {
+		r.opts.Indent, r.opts.Minify = "", false
+		r.src = newSourceRender(s.document.source)
+	}
+	if err := r.render(r.root, 0); err != nil && err != errPlaintext {
+		return err
+	}
+	return bw.Flush()
+}
+
+// OuterHtmlWithOptions returns the outer HTML rendering of the first item in
+// the selection, configured by opts. See RenderWithOptions.
+func OuterHtmlWithOptions(s *Selection, opts RenderOptions) (string, error) {
+	var builder strings.Builder
+	if err := RenderWithOptions(&builder, s, opts); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+// errPlaintext is returned by renderer.render after a plaintext element,
+// which must be the last element of the output.
+var errPlaintext = errors.New("goquery: plaintext element rendered")
+
+// renderer writes HTML nodes. Write errors are kept by the bufio.Writer and
+// returned when it is flushed.
+type renderer struct {
+	w    *bufio.Writer
+	opts RenderOptions
+
+	// root is the rendered node, whose siblings are not rendered.
+	root *html.Node
+
+	// src is set to render the nodes from their source.
+	src *sourceRender
+
+	// spaceEnded indicates that the last node rendered when minifying is a
+	// text that ends with whitespace, ignoring the stripped comments.
+	spaceEnded bool
+
+	// inline is the number of inline elements being rendered, in which no
+	// whitespace is added when indenting, as it would be part of their text.
+	inline int
+}
+
+func (r *renderer) render(n *html.Node, depth int) error {
+	if r.src != nil {
+		return r.renderSource(n)
+	}
+	return r.renderNode(n, depth)
+}
+
+// renderNode serializes n, its children are rendered by render.
+func (r *renderer) renderNode(n *html.Node, depth int) error {
+	switch n.Type {
+	case html.ElementNode:
+		r.spaceEnded = false
+		err := r.renderElement(n, depth)
+		r.spaceEnded = false
+		return err
+	case html.TextNode:
+		if n.Parent != nil && childTextNodesAreLiteral(n.Parent) {
+			r.w.WriteString(n.Data)
+		} else if r.opts.Minify {
+			text := minifiedText(n)
+			if r.spaceEnded {
+				// the texts around the stripped comments are merged when
+				// parsed, their whitespace is collapsed across them
+				text = strings.TrimPrefix(text, " ")
+			}
+			if text != "" {
+				r.spaceEnded = strings.HasSuffix(text, " ")
+			}
+			r.escape(text)
+		} else {
+			r.escape(n.Data)
+		}
+		return nil
+	case html.CommentNode:
+		if r.opts.Minify && !r.keepComment(n) {
+			return nil
+		}
+	case html.DocumentNode:
+		return r.renderChildren(n, depth)
+	case html.RawNode:
+		r.spaceEnded = false
+		r.w.WriteString(n.Data)
+		return nil
+	}
+	// comments, doctypes and invalid nodes are rendered by html.Render,
+	// there is nothing to configure.
+	r.spaceEnded = false
+	return html.Render(r.w, n)
+}
+
+func (r *renderer) renderElement(n *html.Node, depth int) error {
+	if void, err := r.startTag(n); void || err != nil {
+		return err
+	}
+	if !isPrettyBlock(n) {
+		r.inline++
+		defer func() { r.inline-- }()
+	}
+
+	// the parser drops the first newline of those elements
+	if c := n.FirstChild; c != nil && c.Type == html.TextNode && strings.HasPrefix(c.Data, "\n") {
+		switch n.Data {
+		case "pre", "listing", "textarea":
+			r.w.WriteByte('\n')
+		}
+	}
+
+	if childTextNodesAreLiteral(n) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if err := r.render(c, depth+1); err != nil {
+				return err
+			}
+		}
+		if n.Data == "plaintext" {
+			return errPlaintext
+		}
+	} else if err := r.renderChildren(n, depth); err != nil {
+		return err
+	}
+
+	if r.opts.Minify && r.canOmitEndTag(n) {
+		return nil
+	}
+	r.endTag(n)
+	return nil
+}
+
+// endTag writes the end tag of the element n.
+func (r *renderer) endTag(n *html.Node) {
+	r.w.WriteString("</")
+	r.w.WriteString(n.Data)
+	r.w.WriteByte('>')
+}
+
+// startTag writes the start tag of the element n, and returns true if it is
+// a void or self-closing element, which has no content and no end tag.
+func (r *renderer) startTag(n *html.Node) (bool, error) {
+	r.w.WriteByte('<')
+	r.w.WriteString(n.Data)
+
+	attrs := n.Attr
+	if r.opts.SortAttributes && len(attrs) > 1 {
+		attrs = append([]html.Attribute(nil), attrs...)
+		sort.SliceStable(attrs, func(i, j int) bool {
+			if attrs[i].Namespace != attrs[j].Namespace {
+				return attrs[i].Namespace < attrs[j].Namespace
+			}
+			return attrs[i].Key < attrs[j].Key
+		})
+	}
+	quote := byte('"')
+	if r.opts.Quote == QuoteSingle {
+		quote = '\''
+	}
+	for _, a := range attrs {
+		r.w.WriteByte(' ')
+		if a.Namespace != "" {
+			r.w.WriteString(a.Namespace)
+			r.w.WriteByte(':')
+		}
+		r.w.WriteString(a.Key)
+		if r.opts.Minify {
+			if a.Val == "" || (n.Namespace == "" && a.Namespace == "" &&
+				booleanAttributes[a.Key] && strings.EqualFold(a.Val, a.Key)) {
+				continue
+			}
+			if strings.IndexAny(a.Val, unquotedAttrForbidden) < 0 {
+				r.w.WriteByte('=')
+				r.escape(a.Val)
+				continue
+			}
+		}
+		r.w.WriteByte('=')
+		r.w.WriteByte(quote)
+		r.escape(a.Val)
+		r.w.WriteByte(quote)
+	}
+
+	if n.Namespace == "" && voidElements[n.Data] {
+		if n.FirstChild != nil {
+			return true, fmt.Errorf("goquery: void element <%s> has child nodes", n.Data)
+		}
+		if r.opts.Minify {
+			// an unquoted attribute value would include the slash
+			r.w.WriteByte('>')
+			return true, nil
+		}
+		switch r.opts.Void {
+		case VoidSlash:
+			r.w.WriteString("/>")
+		case VoidNoSlash:
+			r.w.WriteByte('>')
+		case VoidXHTML:
+			r.w.WriteString(" />")
+		}
+		return true, nil
+	}
+	if n.Namespace != "" && n.FirstChild == nil && r.opts.Void == VoidXHTML && !r.opts.Minify {
+		r.w.WriteString(" />")
+		return true, nil
+	}
+	r.w.WriteByte('>')
+	return false, nil
+}
+
+// renderChildren renders the children of n, which is at the given depth. If
+// the options require indentation and n holds block elements, and is not in
+// an inline element, each block element and each run of inline content is
+// rendered on its own line.
+func (r *renderer) renderChildren(n *html.Node, depth int) error {
+	if r.opts.Indent == "" || r.opts.Minify || r.inline > 0 || !hasBlockLayout(n) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if err := r.render(c, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// the children of the document are not indented
+	childDepth := depth + 1
+	if n.Type == html.DocumentNode {
+		childDepth = depth
+	}
+	first := true
+	newline := func(depth int) {
+		if !first || n.Type != html.DocumentNode {
+			r.w.WriteByte('\n')
+			r.w.WriteString(strings.Repeat(r.opts.Indent, depth))
+		}
+		first = false
+	}
+
+	for c := n.FirstChild; c != nil; {
+		if isPrettyBlock(c) {
+			newline(childDepth)
+			if err := r.render(c, childDepth); err != nil {
+				return err
+			}
+			c = c.NextSibling
+			continue
+		}
+
+		// render the run of inline content up to the next block, with the
+		// whitespace at both ends dropped.
+		var run []*html.Node
+		for ; c != nil && !isPrettyBlock(c); c = c.NextSibling {
+			run = append(run, c)
+		}
+		start, end := 0, len(run)
+		for start < end && isWhitespaceText(run[start]) {
+			start++
+		}
+		for end > start && isWhitespaceText(run[end-1]) {
+			end--
+		}
+		if start == end {
+			continue
+		}
+		newline(childDepth)
+		for i, rc := range run[start:end] {
+			if rc.Type != html.TextNode {
+				if err := r.render(rc, childDepth); err != nil {
+					return err
+				}
+				continue
+			}
+			text := rc.Data
+			if i == 0 {
+				text = strings.TrimLeft(text, whitespace)
+			}
+			if i == end-start-1 {
+				text = strings.TrimRight(text, whitespace)
+			}
+			r.escape(text)
+		}
+	}
+	if n.Type != html.DocumentNode {
+		newline(depth)
+	}
+	return nil
+}
+
+// escape writes s escaped as html.EscapeString does, and with the non-ASCII
+// characters escaped if required by the options.
+func (r *renderer) escape(s string) {
+	s = html.EscapeString(s)
+	if !r.opts.ASCII {
+		r.w.WriteString(s)
+		return
+	}
+	for len(s) > 0 {
+		i := 0
+		for i < len(s) && s[i] < utf8.RuneSelf {
+			i++
+		}
+		r.w.WriteString(s[:i])
+		if i == len(s) {
+			return
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		fmt.Fprintf(r.w, "&#x%X;", c)
+		s = s[i+size:]
+	}
+}
+
+// whitespace is the set of HTML whitespace characters.
+const whitespace = " \t\n\f\r"
+
+func isWhitespaceText(n *html.Node) bool {
+	return n.Type == html.TextNode && strings.Trim(n.Data, whitespace) == ""
+}
+
+// hasBlockLayout returns true if the children of n can be indented, that is
+// if n is the document or an element whose whitespace is not significant,
+// and it holds at least one block element.
+func hasBlockLayout(n *html.Node) bool {
+	if n.Type == html.DocumentNode {
+		return true
+	}
+	if n.Type != html.ElementNode || isWhitespaceSensitive(n) || isTextOnlyElement(n) {
+		return false
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && isPrettyBlock(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPrettyBlock returns true if n is rendered on its own line when
+// indenting: the block elements, the elements that are not rendered as
+// text, and the comments and doctypes that are between them.
+func isPrettyBlock(n *html.Node) bool {
+	switch n.Type {
+	case html.DoctypeNode:
+		return true
+	case html.CommentNode:
+		p := n.PrevSibling
+		for p != nil && isWhitespaceText(p) {
+			p = p.PrevSibling
+		}
+		return p == nil || isPrettyBlock(p)
+	case html.ElementNode:
+		if isBlockElement(n) {
+			return true
+		}
+		if n.Namespace == "" {
+			switch n.DataAtom {
+			case atom.Base, atom.Noscript, atom.Script, atom.Style:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// childTextNodesAreLiteral returns true if the text of the children of n is
+// rendered as-is, as done by html.Render.
+func childTextNodesAreLiteral(n *html.Node) bool {
+	if n.Namespace != "" {
+		return false
+	}
+	switch n.Data {
+	case "iframe", "noembed", "noframes", "noscript", "plaintext", "script", "style", "xmp":
+		// a fostered element in a foreign element is not HTML
+		for p := n.Parent; p != nil; p = p.Parent {
+			if p.Namespace != "" {
+				return isHTMLIntegrationPoint(p)
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// booleanAttributes are the HTML attributes whose value is a boolean, set by
+// the presence of the attribute.
+var booleanAttributes = map[string]bool{
+	"allowfullscreen": true, "async": true, "autofocus": true,
+	"autoplay": true, "checked": true, "controls": true, "default": true,
+	"defer": true, "disabled": true, "formnovalidate": true, "hidden": true,
+	"inert": true, "ismap": true, "itemscope": true, "loop": true,
+	"multiple": true, "muted": true, "nomodule": true, "novalidate": true,
+	"open": true, "playsinline": true, "readonly": true, "required": true,
+	"reversed": true, "selected": true,
+}
+
+// unquotedAttrForbidden are the characters that cannot appear in an unquoted
+// attribute value.
+const unquotedAttrForbidden = whitespace + "\"'=<>`"
+
+// keepComment returns true if the comment n is kept when minifying.
+func (r *renderer) keepComment(n *html.Node) bool {
+	return r.opts.KeepConditionalComments &&
+		(strings.HasPrefix(n.Data, "[if") || strings.HasPrefix(n.Data, "<![endif]"))
+}
+
+// minifiedText returns the text of the text node n with its whitespace
+// collapsed, and dropped next to block elements, unless it is in an element
+// where whitespace is significant.
+func minifiedText(n *html.Node) string {
+	parent := n.Parent
+	if parent == nil {
+		return n.Data
+	}
+	for p := parent; p != nil; p = p.Parent {
+		if isWhitespaceSensitive(p) || isTextOnlyElement(p) {
+			return n.Data
+		}
+	}
+
+	text := collapseWhitespace(n.Data)
+	if isBlockBoundary(parent, minifiedSibling(n, false)) {
+		text = strings.TrimLeft(text, " ")
+	}
+	if isBlockBoundary(parent, minifiedSibling(n, true)) {
+		text = strings.TrimRight(text, " ")
+	}
+	return text
+}
+
+// minifiedSibling returns the next (or previous) sibling of n that is not a
+// comment or whitespace, or nil.
+func minifiedSibling(n *html.Node, next bool) *html.Node {
+	for {
+		if next {
+			n = n.NextSibling
+		} else {
+			n = n.PrevSibling
+		}
+		if n == nil || (n.Type != html.CommentNode && !isWhitespaceText(n)) {
+			return n
+		}
+	}
+}
+
+// nextRendered returns the next sibling of n that is rendered when
+// minifying, or nil.
+func (r *renderer) nextRendered(n *html.Node) *html.Node {
+	for c := n.NextSibling; c != nil; c = c.NextSibling {
+		switch {
+		case c.Type == html.CommentNode && !r.keepComment(c):
+		case c.Type == html.TextNode && minifiedText(c) == "":
+		default:
+			return c
+		}
+	}
+	return nil
+}
+
+// paragraphClosers are the elements whose start tag implies the end of a p
+// element.
+var paragraphClosers = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true,
+	atom.Blockquote: true, atom.Details: true, atom.Dialog: true,
+	atom.Div: true, atom.Dl: true, atom.Fieldset: true, atom.Figcaption: true,
+	atom.Figure: true, atom.Footer: true, atom.Form: true, atom.H1: true,
+	atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Header: true, atom.Hgroup: true, atom.Hr: true, atom.Main: true,
+	atom.Menu: true, atom.Nav: true, atom.Ol: true, atom.P: true,
+	atom.Pre: true, atom.Section: true, atom.Table: true, atom.Ul: true,
+}
+
+// canOmitEndTag returns true if the end tag of the HTML element n can be
+// omitted, following the rules of the HTML specification
+// (https://html.spec.whatwg.org/multipage/syntax.html#optional-tags).
+func (r *renderer) canOmitEndTag(n *html.Node) bool {
+	if n.Namespace != "" || n == r.root {
+		return false
+	}
+	next := r.nextRendered(n)
+	nextIs := func(tags ...atom.Atom) bool {
+		if next == nil || next.Type != html.ElementNode || next.Namespace != "" {
+			return false
+		}
+		for _, tag := range tags {
+			if next.DataAtom == tag {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch n.DataAtom {
+	case atom.Html, atom.Body:
+		return next == nil || next.Type != html.CommentNode
+	case atom.Head, atom.Colgroup, atom.Caption:
+		if next != nil && next.Type == html.TextNode {
+			return strings.IndexAny(minifiedText(next), whitespace) != 0
+		}
+		return next == nil || next.Type != html.CommentNode
+	case atom.Li:
+		return next == nil || nextIs(atom.Li)
+	case atom.Dt:
+		return nextIs(atom.Dt, atom.Dd)
+	case atom.Dd:
+		return next == nil || nextIs(atom.Dd, atom.Dt)
+	case atom.P:
+		if next == nil {
+			parent := n.Parent
+			if parent == nil || parent.Type != html.ElementNode {
+				return true
+			}
+			switch parent.DataAtom {
+			case atom.A, atom.Audio, atom.Del, atom.Ins, atom.Map, atom.Noscript, atom.Video:
+				return false
+			}
+			// autonomous custom elements
+			return parent.Namespace != "" || !strings.Contains(parent.Data, "-")
+		}
+		return next.Type == html.ElementNode && next.Namespace == "" && paragraphClosers[next.DataAtom]
+	case atom.Rt, atom.Rp:
+		return next == nil || nextIs(atom.Rt, atom.Rp)
+	case atom.Optgroup:
+		return next == nil || nextIs(atom.Optgroup, atom.Hr)
+	case atom.Option:
+		return next == nil || nextIs(atom.Option, atom.Optgroup, atom.Hr)
+	case atom.Thead:
+		return nextIs(atom.Tbody, atom.Tfoot)
+	case atom.Tbody:
+		return next == nil || nextIs(atom.Tbody, atom.Tfoot)
+	case atom.Tfoot:
+		return next == nil
+	case atom.Tr:
+		return next == nil || nextIs(atom.Tr)
+	case atom.Td, atom.Th:
+		return next == nil || nextIs(atom.Td, atom.Th)
+	}
+	return false
+}
@@ -0,0 +1,223 @@
+package goquery
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderWithOptionsDefault(t *testing.T) {
+	for _, d := range []*Document{Doc(), Doc2(), Doc3(), DocB(), DocW()} {
+		want, err := OuterHtml(d.Selection)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := OuterHtmlWithOptions(d.Selection, RenderOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("want the same output as html.Render for %s", d.Find("title").Text())
+		}
+	}
+}
+
+func TestRenderWithOptionsIndent(t *testing.T) {
+	doc := loadString(t, `<!DOCTYPE html><html><head><title>T</title><script>if (a < b) {}</script></head>`+
+		`<body><div id="a">  Hello <b>world</b>  <p>para <i>it</i></p><!-- c --><pre>  keep
+  this </pre><ul><li>one</li><li>two <span>x</span></li></ul></div></body></html>`)
+
+	got, err := OuterHtmlWithOptions(doc.Selection, RenderOptions{Indent: "  "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<!DOCTYPE html>
+<html>
+  <head>
+    <title>T</title>
+    <script>if (a < b) {}</script>
+  </head>
+  <body>
+    <div id="a">
+      Hello <b>world</b>
+      <p>para <i>it</i></p>
+      <!-- c -->
+      <pre>  keep
+  this </pre>
+      <ul>
+        <li>one</li>
+        <li>two <span>x</span></li>
+      </ul>
+    </div>
+  </body>
+</html>`
+	if got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+
+	// the indented output parses to the same text content
+	doc2 := loadString(t, got)
+	if a, b := doc.Find("pre").Text(), doc2.Find("pre").Text(); a != b {
+		t.Errorf("want pre text %q, got %q", a, b)
+	}
+}
+
+func TestRenderWithOptionsIndentInline(t *testing.T) {
+	doc := loadString(t, `<div><p>x</p><a href=x><div>block</div></a><span><p>a</p> <p>b</p></span></div>`)
+
+	got, err := OuterHtmlWithOptions(doc.Find("body > div"), RenderOptions{Indent: "  "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<div>
+  <p>x</p>
+  <a href="x"><div>block</div></a><span><p>a</p> <p>b</p></span>
+</div>`
+	if got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+
+	// no whitespace is added to the text of the inline elements
+	doc2 := loadString(t, got)
+	for _, sel := range []string{"a", "span"} {
+		if a, b := doc.Find(sel).Text(), doc2.Find(sel).Text(); a != b {
+			t.Errorf("%s: want text %q, got %q", sel, a, b)
+		}
+	}
+}
+
+func TestRenderWithOptionsAttributes(t *testing.T) {
+	doc := loadString(t, `<p z="1" a='it&apos;s "x"' m="é">ç</p><br><svg><circle r="1"></circle></svg>`)
+
+	cases := []struct {
+		sel  string
+		opts RenderOptions
+		want string
+	}{
+		{"p", RenderOptions{SortAttributes: true}, `<p a="it&#39;s &#34;x&#34;" m="é" z="1">ç</p>`},
+		{"p", RenderOptions{Quote: QuoteSingle}, `<p z='1' a='it&#39;s &#34;x&#34;' m='é'>ç</p>`},
+		{"p", RenderOptions{ASCII: true}, `<p z="1" a="it&#39;s &#34;x&#34;" m="&#xE9;">&#xE7;</p>`},
+		{"br", RenderOptions{}, `<br/>`},
+		{"br", RenderOptions{Void: VoidNoSlash}, `<br>`},
+		{"br", RenderOptions{Void: VoidXHTML}, `<br />`},
+		{"svg", RenderOptions{}, `<svg><circle r="1"></circle></svg>`},
+		{"svg", RenderOptions{Void: VoidXHTML}, `<svg><circle r="1" /></svg>`},
+	}
+	for _, c := range cases {
+		got, err := OuterHtmlWithOptions(doc.Find(c.sel), c.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("%s %+v: want %s, got %s", c.sel, c.opts, c.want, got)
+		}
+	}
+}
+
+func TestRenderWithOptionsVoidChildren(t *testing.T) {
+	doc := loadString(t, `<br>`)
+	br := doc.Find("br")
+	br.Nodes[0].AppendChild(Text("x"))
+	var sb strings.Builder
+	if err := RenderWithOptions(&sb, br, RenderOptions{}); err == nil {
+		t.Error("want an error for a void element with children")
+	}
+}
+
+func TestRenderWithOptionsMinify(t *testing.T) {
+	doc := loadString(t, `<!DOCTYPE html><html><head><title>T</title><!--[if IE]><p>ie</p><![endif]--></head><body>
+<div id="a" class="x y" data-e="" hidden="hidden">  Hello   <b>world</b>  <p>para <i>it</i></p><!-- c --><pre>
+ keep  </pre><ul> <li>one</li> <li>two</li> </ul><table><tr><td>1</td><td>2</td></tr></table>
+<select><option selected>a</option><option>b</option></select><input value="a/b"><a href="?a=1&b=2">l</a><p>last</p></div></body></html>`)
+
+	got, err := OuterHtmlWithOptions(doc.Selection, RenderOptions{Minify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<!DOCTYPE html><html><head><title>T</title><body>` +
+		`<div id=a class="x y" data-e hidden>Hello <b>world</b><p>para <i>it</i><pre> keep  </pre>` +
+		`<ul><li>one<li>two</ul><table><tbody><tr><td>1<td>2</table>` +
+		`<select><option selected>a<option>b</select><input value=a/b><a href="?a=1&amp;b=2">l</a><p>last</div>`
+	if got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+
+	got, err = OuterHtmlWithOptions(doc.Find("head"), RenderOptions{Minify: true, KeepConditionalComments: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<head><title>T</title><!--[if IE]><p>ie</p><![endif]--></head>`; got != want {
+		t.Errorf("want %s, got %s", want, got)
+	}
+}
+
+func TestRenderWithOptionsMinifyRoundTrip(t *testing.T) {
+	for _, d := range []*Document{Doc(), Doc2(), Doc3(), DocB(), DocW()} {
+		min, err := OuterHtmlWithOptions(d.Selection, RenderOptions{Minify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(min) >= len(mustOuterHtml(t, d.Selection)) {
+			t.Errorf("want minified output to be smaller for %s", d.Find("title").Text())
+		}
+
+		// the minified HTML parses to the same elements, and minifies to
+		// the same output
+		reparsed := loadString(t, min)
+		if a, b := elementSignature(d.Selection), elementSignature(reparsed.Selection); a != b {
+			t.Errorf("want the same elements for %s", d.Find("title").Text())
+		}
+		if again, _ := OuterHtmlWithOptions(reparsed.Selection, RenderOptions{Minify: true}); again != min {
+			t.Errorf("want minification to be stable for %s", d.Find("title").Text())
+		}
+	}
+}
+
+func TestRenderWithOptionsMinifyStrippedComments(t *testing.T) {
+	doc := loadString(t, "<p>a <!--x--> b <!--y-->\n<!--z--> c</p><script>1</script> <!--s-->\n <script>2</script>")
+	got, err := OuterHtmlWithOptions(doc.Find("body"), RenderOptions{Minify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<body><p>a b c</p><script>1</script> <script>2</script></body>"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	// the whitespace is collapsed on the first minification
+	for _, d := range []*Document{doc, loadDoc("metalreview.html")} {
+		min, err := OuterHtmlWithOptions(d.Selection, RenderOptions{Minify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(min, "</script>  <script") {
+			t.Error("want the whitespace between the scripts collapsed")
+		}
+		again, _ := OuterHtmlWithOptions(loadString(t, min).Selection, RenderOptions{Minify: true})
+		if again != min {
+			t.Errorf("want minifying twice to give the same output")
+		}
+	}
+}
+
+func mustOuterHtml(t *testing.T, s *Selection) string {
+	h, err := OuterHtml(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func elementSignature(s *Selection) string {
+	var sb strings.Builder
+	s.Find("*").Each(func(_ int, el *Selection) {
+		n := el.Nodes[0]
+		sb.WriteString(n.Data)
+		for _, a := range n.Attr {
+			if booleanAttributes[a.Key] {
+				a.Val = ""
+			}
+			sb.WriteString(" " + a.Key + "=" + a.Val)
+		}
+		sb.WriteByte('\n')
+	})
+	return sb.String()
+}
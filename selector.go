@@ -0,0 +1,634 @@
+package goquery
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+// errSelectorSyntax is returned by the parsing of a nameSelector when the
+// selector is invalid.
+var errSelectorSyntax = errors.New("goquery: invalid selector")
+
+// nameSelector is a selector group that matches the names of the elements and
+// of the attributes ignoring their case, and their namespaces. It is used for
+// the selectors with uppercase names, which cascadia lowercases: it could not
+// match the elements and attributes with uppercase letters in their names
+// otherwise, such as those of the XML documents or the camelCase SVG elements.
+// It is also used for the selectors with namespace prefixes, such as svg|a or
+// [xlink|href], which cascadia does not support. The combinators and the names
+// are matched by the nameSelector, the other simple selectors of each compound
+// selector by cascadia.
+type nameSelector []complexSelector
+
+// complexSelector is a sequence of compound selectors separated by
+// combinators: combinators[i] is the combinator between compounds[i] and
+// compounds[i+1], one of ' ', '>', '+' and '~'.
+type complexSelector struct {
+	compounds   []compoundSelector
+	combinators []byte
+}
+
+// compoundSelector matches an element by its name and namespace, the
+// attribute selectors with uppercase names or namespaces, and the rest of the
+// simple selectors.
+type compoundSelector struct {
+	ns    namespacePrefix
+	name  string // empty for any name
+	attrs []attrSelector
+	rest  Matcher // nil if there is no other simple selector
+}
+
+// attrSelector is an attribute selector: op is empty for the presence of the
+// attribute, or one of the operators supported by cascadia, except #=.
+type attrSelector struct {
+	ns       namespacePrefix
+	prefixed bool // the name has a namespace prefix, possibly *|
+	name     string
+	op       string
+	val      string
+	fold     bool
+}
+
+// namespacePrefix is the namespace prefix of a type or attribute selector,
+// which matches any namespace if it is not set, as the selectors without a
+// prefix do, e.g. *|a or a.
+type namespacePrefix struct {
+	prefix string // empty for the nodes without a namespace, e.g. |a
+	set    bool
+}
+
+// match returns true if ns, the Namespace of an element or of an attribute of
+// the element n, is the namespace of the prefix. The prefixes svg, math,
+// xlink, xml and xmlns are the namespaces of the same short name, the other
+// prefixes are resolved by the xmlns declarations in scope at n.
+func (p namespacePrefix) match(n *html.Node, ns string) bool {
+	if !p.set {
+		return true
+	}
+	switch p.prefix {
+	case "", "svg", "math", "xlink", "xml", "xmlns":
+		return ns == p.prefix
+	}
+	url, ok := lookupNamespace(n, p.prefix)
+	return ok && ns == shortNamespace(url)
+}
+
+// compileNameSelector returns the nameSelector of the selector, or nil if
+// the selector has no uppercase name nor namespace prefix and can be compiled
+// by cascadia. An invalid selector returns a Matcher that matches nothing.
+func compileNameSelector(sel string) Matcher {
+	m, err := parseNameSelector(sel)
+	if err != nil {
+		return invalidMatcher{}
+	}
+	return m
+}
+
+// parseNameSelector is like compileNameSelector, but it returns the error of
+// an invalid selector.
+func parseNameSelector(sel string) (Matcher, error) {
+	if !hasUpper(sel) && strings.IndexByte(sel, '|') < 0 {
+		return nil, nil
+	}
+	var ns nameSelector
+	var needed bool
+	for _, g := range splitSelector(sel) {
+		cs, n, err := parseComplexSelector(g)
+		if err != nil {
+			return nil, err
+		}
+		ns = append(ns, cs)
+		needed = needed || n
+	}
+	if !needed {
+		return nil, nil
+	}
+	return ns, nil
+}
+
+func hasUpper(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c >= 'A' && c <= 'Z' {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns true if n matches one of the selectors of the group.
+func (ns nameSelector) Match(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	for _, cs := range ns {
+		if cs.match(n, len(cs.compounds)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchAll returns n and its descendants that match, in document order.
+func (ns nameSelector) MatchAll(n *html.Node) []*html.Node {
+	var result []*html.Node
+	if ns.Match(n) {
+		result = append(result, n)
+	}
+	walkDescendants(n, true, false, func(c *html.Node) bool {
+		if ns.Match(c) {
+			result = append(result, c)
+		}
+		return true
+	})
+	return result
+}
+
+// MatchFirst returns the first of the nodes returned by MatchAll, or nil.
+func (ns nameSelector) MatchFirst(n *html.Node) *html.Node {
+	if ns.Match(n) {
+		return n
+	}
+	var first *html.Node
+	walkDescendants(n, true, false, func(c *html.Node) bool {
+		if ns.Match(c) {
+			first = c
+			return false
+		}
+		return true
+	})
+	return first
+}
+
+// Filter returns the nodes that match.
+func (ns nameSelector) Filter(nodes []*html.Node) []*html.Node {
+	var result []*html.Node
+	for _, n := range nodes {
+		if ns.Match(n) {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// match returns true if n matches the compound selector i and the ones
+// before it, as required by the combinators.
+func (cs complexSelector) match(n *html.Node, i int) bool {
+	if !cs.compounds[i].match(n) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	switch cs.combinators[i-1] {
+	case ' ':
+		for p := n.Parent; p != nil && p.Type == html.ElementNode; p = p.Parent {
+			if cs.match(p, i-1) {
+				return true
+			}
+		}
+	case '>':
+		if p := n.Parent; p != nil && p.Type == html.ElementNode {
+			return cs.match(p, i-1)
+		}
+	case '+':
+		if p := prevElementSibling(n); p != nil {
+			return cs.match(p, i-1)
+		}
+	case '~':
+		for p := prevElementSibling(n); p != nil; p = prevElementSibling(p) {
+			if cs.match(p, i-1) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func prevElementSibling(n *html.Node) *html.Node {
+	for p := n.PrevSibling; p != nil; p = p.PrevSibling {
+		if p.Type == html.ElementNode {
+			return p
+		}
+	}
+	return nil
+}
+
+func (c compoundSelector) match(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if c.name != "" && !strings.EqualFold(n.Data, c.name) {
+		return false
+	}
+	if !c.ns.match(n, n.Namespace) {
+		return false
+	}
+	for _, a := range c.attrs {
+		if !a.match(n) {
+			return false
+		}
+	}
+	return c.rest == nil || c.rest.Match(n)
+}
+
+func (a attrSelector) match(n *html.Node) bool {
+	if a.op == "!=" {
+		for _, attr := range n.Attr {
+			if a.matchName(n, attr) && a.equal(attr.Val, a.val) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, attr := range n.Attr {
+		if a.matchName(n, attr) && a.matchValue(attr.Val) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchName returns true if attr, an attribute of n, has the name and the
+// namespace of the selector.
+func (a attrSelector) matchName(n *html.Node, attr html.Attribute) bool {
+	return strings.EqualFold(attr.Key, a.name) && a.ns.match(n, attr.Namespace)
+}
+
+func (a attrSelector) equal(s, t string) bool {
+	if a.fold {
+		return strings.EqualFold(s, t)
+	}
+	return s == t
+}
+
+func (a attrSelector) matchValue(v string) bool {
+	if a.fold {
+		v, a.val = strings.ToLower(v), strings.ToLower(a.val)
+	}
+	switch a.op {
+	case "":
+		return true
+	case "=":
+		return v == a.val
+	case "~=":
+		if a.val == "" || strings.ContainsAny(a.val, whitespace) {
+			return false
+		}
+		for _, f := range strings.Fields(v) {
+			if f == a.val {
+				return true
+			}
+		}
+		return false
+	case "|=":
+		return v == a.val || strings.HasPrefix(v, a.val+"-")
+	case "^=":
+		return a.val != "" && strings.HasPrefix(v, a.val)
+	case "$=":
+		return a.val != "" && strings.HasSuffix(v, a.val)
+	case "*=":
+		return a.val != "" && strings.Contains(v, a.val)
+	}
+	return false
+}
+
+// selectorScanner tracks the strings, escapes, brackets and parentheses of a
+// selector, to find its top-level characters.
+type selectorScanner struct {
+	quote    byte
+	escape   bool
+	brackets int
+	parens   int
+}
+
+// top returns true if the scanner is outside of strings, brackets and
+// parentheses.
+func (sc *selectorScanner) top() bool {
+	return sc.quote == 0 && !sc.escape && sc.brackets == 0 && sc.parens == 0
+}
+
+// next updates the state with the character c, and returns true if it is a
+// top-level character, outside of strings, brackets and parentheses.
+func (sc *selectorScanner) next(c byte) bool {
+	switch {
+	case sc.escape:
+		sc.escape = false
+		return false
+	case c == '\\':
+		sc.escape = true
+		return false
+	case sc.quote != 0:
+		if c == sc.quote {
+			sc.quote = 0
+		}
+		return false
+	case c == '"' || c == '\'':
+		sc.quote = c
+		return false
+	case c == '[':
+		sc.brackets++
+		return false
+	case c == ']':
+		sc.brackets--
+		return false
+	case c == '(':
+		sc.parens++
+		return false
+	case c == ')':
+		sc.parens--
+		return false
+	}
+	return sc.brackets == 0 && sc.parens == 0
+}
+
+// splitSelector splits a selector group at its top-level commas.
+func splitSelector(sel string) []string {
+	var parts []string
+	var sc selectorScanner
+	start := 0
+	for i := 0; i < len(sel); i++ {
+		if sc.next(sel[i]) && sel[i] == ',' {
+			parts = append(parts, sel[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, sel[start:])
+}
+
+// parseComplexSelector parses a complex selector, and returns true if it has
+// an uppercase name or a namespace prefix.
+func parseComplexSelector(sel string) (complexSelector, bool, error) {
+	var cs complexSelector
+	var needed bool
+	var sc selectorScanner
+	sel = strings.Trim(sel, whitespace)
+	start := 0
+	for i := 0; i <= len(sel); i++ {
+		if i < len(sel) && (!sc.next(sel[i]) || !strings.ContainsRune(whitespace+">+~", rune(sel[i]))) {
+			continue
+		}
+		if start == i {
+			return cs, false, errSelectorSyntax
+		}
+		c, n, err := parseCompoundSelector(sel[start:i])
+		if err != nil {
+			return cs, false, err
+		}
+		cs.compounds = append(cs.compounds, c)
+		needed = needed || n
+		if i == len(sel) {
+			break
+		}
+
+		// the combinator, surrounded by optional whitespace
+		for i < len(sel) && strings.IndexByte(whitespace, sel[i]) >= 0 {
+			i++
+		}
+		comb := byte(' ')
+		if i < len(sel) && strings.IndexByte(">+~", sel[i]) >= 0 {
+			comb = sel[i]
+			for i++; i < len(sel) && strings.IndexByte(whitespace, sel[i]) >= 0; i++ {
+			}
+		}
+		if i == len(sel) {
+			return cs, false, errSelectorSyntax
+		}
+		cs.combinators = append(cs.combinators, comb)
+		start = i
+		i--
+	}
+	return cs, needed, nil
+}
+
+// parseCompoundSelector parses a compound selector, and returns true if it
+// has an uppercase name or a namespace prefix.
+func parseCompoundSelector(sel string) (compoundSelector, bool, error) {
+	var c compoundSelector
+	ns, name, prefixed, i, err := parseQualifiedName(sel, 0)
+	if err != nil {
+		return c, false, err
+	}
+	c.ns = ns
+	if name != "*" {
+		c.name = name
+	}
+	needed := prefixed || hasUpper(c.name)
+
+	// the attribute selectors with an uppercase name or a namespace prefix
+	// are matched by c, the rest of the selector by cascadia
+	var rest strings.Builder
+	var sc selectorScanner
+	for i < len(sel) {
+		if sel[i] != '[' || !sc.top() {
+			sc.next(sel[i])
+			rest.WriteByte(sel[i])
+			i++
+			continue
+		}
+
+		end := i
+		for end < len(sel) {
+			sc.next(sel[end])
+			if end++; sc.brackets == 0 {
+				break
+			}
+		}
+		if sc.brackets != 0 {
+			return c, false, errSelectorSyntax
+		}
+		a, ok, err := parseAttrSelector(sel[i+1 : end-1])
+		if err != nil {
+			return c, false, err
+		}
+		if ok && (hasUpper(a.name) || a.prefixed) {
+			c.attrs = append(c.attrs, a)
+			needed = true
+		} else {
+			rest.WriteString(sel[i:end])
+		}
+		i = end
+	}
+
+	if rest.Len() > 0 {
+		m, err := cascadia.Compile("*" + rest.String())
+		if err != nil {
+			return c, false, err
+		}
+		c.rest = m
+	}
+	return c, needed, nil
+}
+
+// parseAttrSelector parses the content of an attribute selector. It returns
+// false if the selector is valid but not supported by attrSelector.
+func parseAttrSelector(sel string) (attrSelector, bool, error) {
+	var a attrSelector
+	var i int
+	var err error
+	a.ns, a.name, a.prefixed, i, err = parseQualifiedName(sel, skipSpace(sel, 0))
+	if err != nil || a.name == "" || a.name == "*" {
+		return a, false, errSelectorSyntax
+	}
+	i = skipSpace(sel, i)
+	if i == len(sel) {
+		return a, true, nil
+	}
+
+	if sel[i] == '=' {
+		a.op, i = "=", i+1
+	} else if i+1 < len(sel) && sel[i+1] == '=' && strings.IndexByte("~|^$*!#", sel[i]) >= 0 {
+		a.op, i = sel[i:i+2], i+2
+	} else {
+		return a, false, errSelectorSyntax
+	}
+	i = skipSpace(sel, i)
+	switch {
+	case i == len(sel):
+		return a, false, errSelectorSyntax
+	case sel[i] == '"' || sel[i] == '\'':
+		var ok bool
+		if a.val, i, ok = readString(sel, i); !ok {
+			return a, false, errSelectorSyntax
+		}
+	case isIdentStart(sel, i) || sel[i] >= '0' && sel[i] <= '9':
+		a.val, i = readIdent(sel, i)
+	default:
+		return a, false, errSelectorSyntax
+	}
+
+	i = skipSpace(sel, i)
+	if i < len(sel) {
+		switch flag := strings.ToLower(strings.Trim(sel[i:], whitespace)); flag {
+		case "i":
+			a.fold = true
+		case "s":
+		default:
+			return a, false, errSelectorSyntax
+		}
+	}
+	return a, a.op != "#=", nil
+}
+
+// parseQualifiedName parses the name of a type or attribute selector that
+// starts at s[i], with its optional namespace prefix, and returns the
+// prefix, the name, which is "*" for the universal selector and empty if
+// there is none, whether a prefix was given, and the index that follows.
+func parseQualifiedName(s string, i int) (namespacePrefix, string, bool, int, error) {
+	readName := func(i int) (string, int) {
+		switch {
+		case i == len(s):
+			return "", i
+		case s[i] == '*':
+			return "*", i + 1
+		case isIdentStart(s, i):
+			return readIdent(s, i)
+		}
+		return "", i
+	}
+
+	name, i := readName(i)
+	if i == len(s) || s[i] != '|' || i+1 < len(s) && s[i+1] == '=' {
+		return namespacePrefix{}, name, false, i, nil
+	}
+	var ns namespacePrefix
+	if name != "*" {
+		ns = namespacePrefix{prefix: name, set: true}
+	}
+	name, i = readName(i + 1)
+	if name == "" {
+		return ns, "", true, i, errSelectorSyntax
+	}
+	return ns, name, true, i, nil
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) && strings.IndexByte(whitespace, s[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+// isIdentStart returns true if an identifier starts at s[i].
+func isIdentStart(s string, i int) bool {
+	c := s[i]
+	if c == '-' && i+1 < len(s) {
+		c = s[i+1]
+	}
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '\\' || c >= utf8.RuneSelf
+}
+
+// readIdent reads the identifier that starts at s[i], and returns it with its
+// escapes resolved, and the index that follows it.
+func readIdent(s string, i int) (string, int) {
+	var sb strings.Builder
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == '\\':
+			var r rune
+			r, i = readEscape(s, i)
+			sb.WriteRune(r)
+		case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c >= utf8.RuneSelf:
+			sb.WriteByte(c)
+			i++
+		default:
+			return sb.String(), i
+		}
+	}
+	return sb.String(), i
+}
+
+// readString reads the quoted string that starts at s[i], and returns its
+// content with its escapes resolved, and the index that follows it.
+func readString(s string, i int) (string, int, bool) {
+	var sb strings.Builder
+	quote := s[i]
+	for i++; i < len(s); {
+		switch c := s[i]; c {
+		case quote:
+			return sb.String(), i + 1, true
+		case '\\':
+			if i+1 < len(s) && s[i+1] == '\n' {
+				i += 2
+				continue
+			}
+			var r rune
+			r, i = readEscape(s, i)
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+	return "", i, false
+}
+
+// readEscape reads the escape that starts at s[i], and returns its rune and
+// the index that follows it.
+func readEscape(s string, i int) (rune, int) {
+	i++
+	j := i
+	for j < len(s) && j-i < 6 && strings.IndexByte("0123456789abcdefABCDEF", s[j]) >= 0 {
+		j++
+	}
+	if j == i {
+		if i == len(s) {
+			return utf8.RuneError, i
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		return r, i + size
+	}
+	code, _ := strconv.ParseUint(s[i:j], 16, 32)
+	if j < len(s) && strings.IndexByte(whitespace, s[j]) >= 0 {
+		j++
+	}
+	if code == 0 || code > utf8.MaxRune {
+		return utf8.RuneError, j
+	}
+	return rune(code), j
+}
@@ -0,0 +1,115 @@
+package goquery
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNameSelector(t *testing.T) {
+	doc := loadString(t, `<div id="a"><svg viewBox="0 0 1 1"><linearGradient id="g" gradientUnits="x y"/><clipPath id="c"/>`+
+		`<clipPath id="d"/></svg><p id="p" class="X" title="a-b"></p></div>`)
+	cases := []struct {
+		sel  string
+		want []string
+	}{
+		{"linearGradient", []string{"g"}},
+		{"LINEARGRADIENT, clipPath", []string{"g", "c", "d"}},
+		{"#a svg > clipPath#d", []string{"d"}},
+		{"linearGradient + clipPath", []string{"c"}},
+		{"linearGradient ~ clipPath:not(#c)", []string{"d"}},
+		{"[gradientUnits]", []string{"g"}},
+		{"[gradientUnits~=y]", []string{"g"}},
+		{"[gradientUnits^='x ']", []string{"g"}},
+		{"[gradientUnits$=\"Y\" i]", []string{"g"}},
+		{"[gradientUnits*=z]", nil},
+		{"clipPath[id!=c]", []string{"d"}},
+		{"svg[viewBox] *", []string{"g", "c", "d"}},
+		{"div > :not(svg)", []string{"p"}},
+		{"p.X", []string{"p"}},
+		{"P[title|=a]", []string{"p"}},
+		{"linearGradient >", nil},
+		{"[viewBox", nil},
+	}
+	for _, c := range cases {
+		var got []string
+		doc.Find(c.sel).Each(func(i int, s *Selection) {
+			id, _ := s.Attr("id")
+			got = append(got, id)
+		})
+		if len(got) != len(c.want) {
+			t.Errorf("%s: want %v, got %v", c.sel, c.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s: want %v, got %v", c.sel, c.want, got)
+				break
+			}
+		}
+	}
+
+	// the selectors without uppercase names are compiled by cascadia
+	if _, ok := compileMatcher(`p.X, #A, [title="B"]`).(nameSelector); ok {
+		t.Error("want a cascadia selector")
+	}
+	if _, ok := compileMatcher("DIV > P").(nameSelector); !ok {
+		t.Error("want a nameSelector")
+	}
+}
+
+func TestNamespaceSelector(t *testing.T) {
+	doc := loadString(t, `<div id="d"><a id="h" href="/x"></a><svg id="s"><a id="sa" xlink:href="#x"></a>`+
+		`<foreignObject id="f"><a id="fa" href="/y"></a></foreignObject></svg><math id="m"><mi id="mi"></mi></math></div>`)
+	cases := []struct {
+		sel  string
+		want []string
+	}{
+		{"svg|a", []string{"sa"}},
+		{"|a", []string{"h", "fa"}},
+		{"*|a", []string{"h", "sa", "fa"}},
+		{"svg|*", []string{"s", "sa", "f"}},
+		{"math|mi, svg|foreignObject", []string{"f", "mi"}},
+		{"svg|svg > svg|a", []string{"sa"}},
+		{"[xlink|href]", []string{"sa"}},
+		{"[xlink|href='#x']", []string{"sa"}},
+		{"[|href]", []string{"h", "fa"}},
+		{"[*|href]", []string{"h", "sa", "fa"}},
+		{"a[href|=x]", nil},
+		{"svg|a#sa[xlink|href^='#']", []string{"sa"}},
+		{"foo|a", nil},
+		{"svg|", nil},
+		{"[xlink|]", nil},
+	}
+	for _, c := range cases {
+		var got []string
+		doc.Find(c.sel).Each(func(i int, s *Selection) {
+			id, _ := s.Attr("id")
+			got = append(got, id)
+		})
+		if !slices.Equal(got, c.want) {
+			t.Errorf("%s: want %v, got %v", c.sel, c.want, got)
+		}
+	}
+}
+
+func TestNamespaceSelectorXML(t *testing.T) {
+	doc, err := NewXMLDocumentFromReader(strings.NewReader(`<feed xmlns="http://www.w3.org/2005/Atom" ` +
+		`xmlns:media="http://search.yahoo.com/mrss/"><entry><media:content url="a.png" media:medium="image"/>` +
+		`<content/></entry></feed>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := doc.Find("media|content").Length(); got != 1 {
+		t.Errorf("want 1 media:content, got %d", got)
+	}
+	if got := doc.Find("entry > content").Length(); got != 2 {
+		t.Errorf("want 2 content elements, got %d", got)
+	}
+	if got, _ := doc.Find("[media|medium=image]").Attr("url"); got != "a.png" {
+		t.Errorf("want %q, got %q", "a.png", got)
+	}
+	if got := doc.Find("|content").Length(); got != 0 {
+		t.Errorf("want no content without namespace, got %d", got)
+	}
+}
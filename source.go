@@ -0,0 +1,678 @@
+package goquery
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// documentSource holds the source of a document created by
+// NewDocumentFromReaderWithSource, and where each node of the document, as
+// parsed, comes from in the source.
+type documentSource struct {
+	src   string
+	nodes map[*html.Node]*nodeSource
+
+	// lines are the offsets of the start of the lines of src.
+	lines []int
+
+	// index is the interval tree of the spans of the elements, built once
+	// on the first lookup, which may be concurrent.
+	indexOnce sync.Once
+	index     *spanIndex
+
+	// dropped are the spans of the tokens that no node is matched to,
+	// except for whitespace and the ignored end tags, such as the ignored
+	// start tags and the text merged into a node whose tokens are not
+	// contiguous.
+	dropped [][2]int
+}
+
+// nodeSource records the state of a node when its document was parsed, and
+// its span in the source.
+type nodeSource struct {
+	data               string
+	namespace          string
+	attr               []html.Attribute
+	parent, prev, next *html.Node
+	children           []*html.Node
+
+	// hasSpan indicates that the node, or the content of an element whose
+	// tags are implied, comes from the source between start and end. The
+	// start tag of an element ends at tagEnd, which is start if it is
+	// implied, and its end tag starts at closeStart, which is end if it is
+	// omitted.
+	hasSpan                        bool
+	start, tagEnd, closeStart, end int
+	startTag, endTag               bool
+
+	// selfClosing indicates that the start tag of a foreign element is
+	// self-closing, so the element has no content and no end tag, as long
+	// as it remains in foreign content.
+	selfClosing bool
+
+	// displaced indicates that the parser moved the node away from its
+	// position in the source, such as the content foster-parented out of a
+	// table.
+	displaced bool
+
+	// first and last are the indexes of the first and last tokens matched
+	// to the node, and endTok the index of the end tag of an element, or -1.
+	first, last, endTok int
+}
+
+// inPlace returns true if n is still at the position where it was parsed.
+func (rec *nodeSource) inPlace(n *html.Node) bool {
+	return n.Parent == rec.parent && n.PrevSibling == rec.prev && n.NextSibling == rec.next
+}
+
+// sameTag returns true if the name and attributes of n are unchanged.
+func (rec *nodeSource) sameTag(n *html.Node) bool {
+	if n.Data != rec.data || n.Namespace != rec.namespace || len(n.Attr) != len(rec.attr) {
+		return false
+	}
+	for i, a := range n.Attr {
+		if a != rec.attr[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sourceToken is a token of the source of a document.
+type sourceToken struct {
+	typ        html.TokenType
+	data       string
+	start, end int
+	node       *html.Node
+}
+
+// sourceAlignWindow is the maximum number of nodes and tokens skipped to
+// resume the alignment of the nodes with the tokens after a mismatch.
+const sourceAlignWindow = 16
+
+// sourceSearchWindow is the maximum number of tokens searched for the text
+// and comment nodes that were moved by the parser.
+const sourceSearchWindow = 256
+
+func newDocumentSource(src string, root *html.Node, context string, scripting bool) *documentSource {
+	ds := &documentSource{src: src, nodes: make(map[*html.Node]*nodeSource), lines: []int{0}}
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\n' {
+			ds.lines = append(ds.lines, i+1)
+		}
+	}
+
+	var nodes []*html.Node
+	var record func(n *html.Node)
+	record = func(n *html.Node) {
+		rec := &nodeSource{
+			data:      n.Data,
+			namespace: n.Namespace,
+			attr:      append([]html.Attribute(nil), n.Attr...),
+			parent:    n.Parent,
+			prev:      n.PrevSibling,
+			next:      n.NextSibling,
+			first:     -1,
+			last:      -1,
+			endTok:    -1,
+		}
+		ds.nodes[n] = rec
+		if n != root {
+			nodes = append(nodes, n)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			rec.children = append(rec.children, c)
+			record(c)
+		}
+	}
+	record(root)
+
+	tokens := tokenizeSource(src, context, scripting)
+	ds.align(nodes, tokens)
+	ds.matchEndTags(tokens)
+	ds.layout(root, 0, len(src), tokens)
+	for _, t := range tokens {
+		if t.node != nil {
+			continue
+		}
+		switch t.typ {
+		case html.EndTagToken:
+			// the unmatched </p> and </br> create an element
+			if t.data != "p" && t.data != "br" {
+				continue
+			}
+		case html.TextToken:
+			if strings.TrimLeft(t.data, whitespace) == "" {
+				continue
+			}
+		}
+		ds.dropped = append(ds.dropped, [2]int{t.start, t.end})
+	}
+	return ds
+}
+
+// tokenizeSource returns all the tokens of src, tokenized as by the parser
+// with scripting enabled or not, in the context of an element of that tag
+// name if context is not empty.
+func tokenizeSource(src, context string, scripting bool) []sourceToken {
+	var tokens []sourceToken
+	z := html.NewTokenizerFragment(strings.NewReader(src), context)
+	for off := 0; ; {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			// src is read to its end, which is the only error here
+			return tokens
+		}
+		t := sourceToken{typ: tt, start: off}
+		off += len(z.Raw())
+		t.end = off
+		switch tt {
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			name, _ := z.TagName()
+			t.data = string(name)
+			if tt == html.StartTagToken && !scripting && t.data == "noscript" {
+				// the parser does the same with scripting disabled
+				z.NextIsNotRawText()
+			}
+		default:
+			t.data = string(z.Text())
+		}
+		tokens = append(tokens, t)
+	}
+}
+
+// align matches the nodes, in document order, with the tokens they are
+// created from. The nodes inserted or moved by the parser, and the tokens
+// ignored by the parser, are skipped.
+func (ds *documentSource) align(nodes []*html.Node, tokens []sourceToken) {
+	var events []int
+	for i, t := range tokens {
+		if t.typ != html.EndTagToken {
+			events = append(events, i)
+		}
+	}
+
+	matches := func(i, j int) int {
+		if i >= len(nodes) || j >= len(events) {
+			return 0
+		}
+		return matchToken(nodes[i], tokens, events[j:])
+	}
+	assign := func(i, j, count int) {
+		rec := ds.nodes[nodes[i]]
+		rec.first, rec.last = events[j], events[j+count-1]
+		for _, k := range events[j : j+count] {
+			if tokens[k].typ == html.TextToken {
+				tokens[k].node = nodes[i]
+			}
+		}
+		tokens[rec.first].node = nodes[i]
+	}
+
+	// skipSpace skips the whitespace that the parser may have dropped
+	skipSpace := func(j int) int {
+		for j < len(events) && tokens[events[j]].typ == html.TextToken &&
+			strings.TrimLeft(tokens[events[j]].data, whitespace) == "" {
+			j++
+		}
+		return j
+	}
+
+	// confirmed returns true if the node i, or the next one if i was
+	// inserted by the parser, matches the token j.
+	confirmed := func(i, j int) bool {
+		for _, i := range []int{i, i + 1} {
+			if matches(i, j) > 0 || matches(i, skipSpace(j)) > 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	i, j := 0, 0
+	last := make([]int, len(nodes))
+	for i < len(nodes) && j < len(events) {
+		if count := matches(i, j); count > 0 {
+			assign(i, j, count)
+			last[i] = j + count
+			i, j = i+1, j+count
+			continue
+		}
+		last[i] = j
+
+		// resume at the nearest match, preferably confirmed by the next nodes
+		found, di, dj := false, 0, 0
+	search:
+		for d := 1; d <= sourceAlignWindow; d++ {
+			for k := 0; k <= d; k++ {
+				if count := matches(i+k, j+d-k); count > 0 {
+					if !found {
+						found, di, dj = true, k, d-k
+					}
+					if confirmed(i+k+1, j+d-k+count) {
+						di, dj = k, d-k
+						break search
+					}
+				}
+			}
+		}
+		if !found {
+			i++
+			continue
+		}
+		for k := 0; k < di; k++ {
+			last[i+k] = j
+		}
+		i, j = i+di, j+dj
+	}
+
+	// the text and comments moved by the parser, e.g. out of a table, come
+	// later in the source
+	for i, n := range nodes {
+		rec := ds.nodes[n]
+		if rec.first >= 0 || (n.Type != html.TextNode && n.Type != html.CommentNode) {
+			continue
+		}
+		for j := last[i]; j < len(events) && j < last[i]+sourceSearchWindow; j++ {
+			if tokens[events[j]].node == nil && matches(i, j) == 1 {
+				assign(i, j, 1)
+				break
+			}
+		}
+	}
+}
+
+// matchToken returns the number of tokens of events that n is created from,
+// or 0 if it does not match the first token. events are indexes of tokens.
+func matchToken(n *html.Node, tokens []sourceToken, events []int) int {
+	t := tokens[events[0]]
+	if t.node != nil {
+		return 0
+	}
+	switch n.Type {
+	case html.ElementNode:
+		if (t.typ == html.StartTagToken || t.typ == html.SelfClosingTagToken) && strings.EqualFold(n.Data, t.data) {
+			return 1
+		}
+	case html.DoctypeNode:
+		if t.typ == html.DoctypeToken {
+			return 1
+		}
+	case html.CommentNode:
+		if t.typ == html.CommentToken && t.data == n.Data {
+			return 1
+		}
+	case html.TextNode:
+		if t.typ != html.TextToken {
+			return 0
+		}
+		// the parser drops the newline at the start of some elements
+		if t.data == n.Data || t.data == "\n"+n.Data {
+			return 1
+		}
+		// the text of consecutive tokens is merged by the parser, e.g.
+		// around ignored tags
+		text := t.data
+		for k := 1; k < len(events) && strings.HasPrefix(n.Data, text); k++ {
+			switch tk := tokens[events[k]]; tk.typ {
+			case html.TextToken:
+				text += tk.data
+				if text == n.Data {
+					return k + 1
+				}
+			case html.StartTagToken, html.SelfClosingTagToken:
+			default:
+				return 0
+			}
+		}
+	}
+	return 0
+}
+
+// matchEndTags matches the end tags with the elements they close.
+func (ds *documentSource) matchEndTags(tokens []sourceToken) {
+	var open []*html.Node
+	for i, t := range tokens {
+		if n := t.node; n != nil {
+			// the open elements that are not ancestors of n were closed
+			// implicitly
+			for len(open) > 0 && !nodeContains(open[len(open)-1], n) {
+				open = open[:len(open)-1]
+			}
+			if n.Type == html.ElementNode {
+				open = append(open, n)
+			}
+			continue
+		}
+		if t.typ != html.EndTagToken {
+			continue
+		}
+		for k := len(open) - 1; k >= 0; k-- {
+			if strings.EqualFold(open[k].Data, t.data) {
+				ds.nodes[open[k]].endTok = i
+				tokens[i].node = open[k]
+				open = open[:k]
+				break
+			}
+		}
+	}
+}
+
+// layout computes the span of n and its descendants. lo and hi are the bounds
+// of the content of the parent of n.
+func (ds *documentSource) layout(n *html.Node, lo, hi int, tokens []sourceToken) {
+	rec := ds.nodes[n]
+	switch n.Type {
+	case html.TextNode, html.CommentNode, html.DoctypeNode:
+		if rec.first >= 0 {
+			rec.hasSpan = true
+			rec.start, rec.end = tokens[rec.first].start, tokens[rec.last].end
+			rec.tagEnd, rec.closeStart = rec.start, rec.end
+		}
+		return
+	case html.DocumentNode:
+		rec.hasSpan = true
+		rec.start, rec.tagEnd, rec.closeStart, rec.end = 0, 0, len(ds.src), len(ds.src)
+		lo, hi = 0, len(ds.src)
+	case html.ElementNode:
+		if rec.first >= 0 {
+			rec.startTag = true
+			rec.selfClosing = n.Namespace != "" && tokens[rec.first].typ == html.SelfClosingTagToken
+			rec.start, rec.tagEnd = tokens[rec.first].start, tokens[rec.first].end
+			lo = rec.tagEnd
+			if rec.endTok > rec.first {
+				rec.endTag = true
+				rec.closeStart, rec.end = tokens[rec.endTok].start, tokens[rec.endTok].end
+				hi = rec.closeStart
+			}
+		}
+	default:
+		return
+	}
+
+	cursor, first := lo, -1
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		ds.layout(c, cursor, hi, tokens)
+		crec := ds.nodes[c]
+		if !crec.hasSpan {
+			continue
+		}
+		if crec.start >= cursor && crec.end <= hi {
+			if first < 0 {
+				first = crec.start
+			}
+			cursor = crec.end
+		} else {
+			crec.displaced = true
+		}
+	}
+
+	switch {
+	case n.Type == html.DocumentNode:
+	case rec.startTag:
+		rec.hasSpan = true
+		if !rec.endTag {
+			rec.closeStart, rec.end = cursor, cursor
+		}
+	case first >= 0:
+		rec.hasSpan = true
+		rec.start, rec.tagEnd, rec.closeStart, rec.end = first, first, cursor, cursor
+	}
+}
+
+// sourceRender is the state of the rendering of a document from its source.
+type sourceRender struct {
+	*documentSource
+
+	clean map[*html.Node]bool
+	in    map[*html.Node]bool
+
+	// excluded are the sorted, disjoint spans of the source of the nodes that were
+	// changed, moved or removed, that must not be written as part of the
+	// source between the rendered nodes.
+	excluded [][2]int
+}
+
+func newSourceRender(ds *documentSource) *sourceRender {
+	sr := &sourceRender{
+		documentSource: ds,
+		clean:          make(map[*html.Node]bool),
+		in:             make(map[*html.Node]bool),
+	}
+	for n, rec := range ds.nodes {
+		if rec.hasSpan && (!rec.inPlace(n) || (rec.displaced && !sr.inSource(n))) {
+			sr.excluded = append(sr.excluded, [2]int{rec.start, rec.end})
+		}
+	}
+	sr.excluded = append(sr.excluded, ds.dropped...)
+	sort.Slice(sr.excluded, func(i, j int) bool {
+		return sr.excluded[i][0] < sr.excluded[j][0]
+	})
+
+	// merge the nested and overlapping spans
+	merged := sr.excluded[:0]
+	for _, span := range sr.excluded {
+		if last := len(merged) - 1; last >= 0 && span[0] <= merged[last][1] {
+			merged[last][1] = max(merged[last][1], span[1])
+			continue
+		}
+		merged = append(merged, span)
+	}
+	sr.excluded = merged
+	return sr
+}
+
+// isClean returns true if n and its descendants are unchanged since the
+// document was parsed.
+func (sr *sourceRender) isClean(n *html.Node) bool {
+	if clean, ok := sr.clean[n]; ok {
+		return clean
+	}
+	clean := func() bool {
+		rec := sr.nodes[n]
+		if rec == nil || !rec.sameTag(n) {
+			return false
+		}
+		i := 0
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if i >= len(rec.children) || rec.children[i] != c || !sr.isClean(c) {
+				return false
+			}
+			i++
+		}
+		return i == len(rec.children)
+	}()
+	sr.clean[n] = clean
+	return clean
+}
+
+// inSource returns true if the displaced node n is written as part of the
+// source around the position it was moved to. This is only the case if it is
+// unchanged, and followed by unchanged displaced nodes only, e.g. the content
+// after the end tag of the body.
+func (sr *sourceRender) inSource(n *html.Node) bool {
+	if in, ok := sr.in[n]; ok {
+		return in
+	}
+	rec := sr.nodes[n]
+	in := rec != nil && rec.displaced && sr.attached(n) && sr.isClean(n) &&
+		(n.NextSibling == nil || sr.inSource(n.NextSibling))
+	sr.in[n] = in
+	return in
+}
+
+// attached returns true if n and its ancestors are still at the position
+// where they were parsed, in the document.
+func (sr *sourceRender) attached(n *html.Node) bool {
+	for ; n.Parent != nil; n = n.Parent {
+		if rec := sr.nodes[n]; rec == nil || !rec.inPlace(n) {
+			return false
+		}
+	}
+	return n.Type == html.DocumentNode
+}
+
+// renderSource renders n from the source of its document if it is unchanged,
+// or serializes it.
+func (r *renderer) renderSource(n *html.Node) error {
+	sr := r.src
+	rec := sr.nodes[n]
+	if rec == nil {
+		return r.renderNode(n, 0)
+	}
+	if n != r.root && rec.displaced && sr.inSource(n) {
+		return nil
+	}
+
+	switch n.Type {
+	case html.DocumentNode:
+		if sr.isClean(n) {
+			r.w.WriteString(sr.src)
+			return nil
+		}
+		return r.renderSourceChildren(n, rec)
+	case html.ElementNode:
+	default:
+		// the source of a text depends on its parent, e.g. it is escaped
+		// unless it is in a script, and may lack the newline dropped at the
+		// start of a pre
+		if rec.hasSpan && !rec.displaced && rec.sameTag(n) && (n.Type != html.TextNode || sr.sameParent(n)) {
+			r.w.WriteString(sr.src[rec.start:rec.end])
+			return nil
+		}
+		return r.renderNode(n, 0)
+	}
+
+	sameTag := rec.sameTag(n)
+	inPlace := rec.inPlace(n) && n != r.root
+	serialized := false
+	switch {
+	case rec.startTag && sameTag && !rec.displaced && !(rec.selfClosing && (n.FirstChild != nil || n.Parent != rec.parent)):
+		r.w.WriteString(sr.src[rec.start:rec.tagEnd])
+		if rec.selfClosing || (n.Namespace == "" && voidElements[n.Data]) {
+			return nil
+		}
+	case !rec.startTag && sameTag && inPlace && optionalStartTags[n.Data] && sr.sameFirst(n):
+	default:
+		if void, err := r.startTag(n); void || err != nil {
+			return err
+		}
+		serialized = true
+	}
+
+	if err := r.renderSourceChildren(n, rec); err != nil {
+		return err
+	}
+
+	switch {
+	case rec.endTag && n.Data == rec.data && !rec.displaced:
+		r.w.WriteString(sr.src[rec.closeStart:rec.end])
+	case !serialized && inPlace && (sr.isClean(n) || documentSections[n.Data]) && sr.closedAfter(n) &&
+		(optionalEndTags[n.Data] || optionalStartTags[n.Data]):
+	default:
+		r.endTag(n)
+	}
+	return nil
+}
+
+// optionalStartTags are the elements that the parser creates when their start
+// tag is omitted.
+var optionalStartTags = map[string]bool{
+	"body": true, "colgroup": true, "head": true, "html": true, "tbody": true, "tr": true,
+}
+
+// documentSections are the elements whose end tag may be omitted whatever
+// their content, as long as they are followed by the same nodes.
+var documentSections = map[string]bool{"body": true, "head": true, "html": true}
+
+// sameFirst returns true if the first child of n, which implies the start tag
+// of n when it is omitted, is unchanged.
+func (sr *sourceRender) sameFirst(n *html.Node) bool {
+	rec := sr.nodes[n]
+	first := n.FirstChild
+	if first == nil || len(rec.children) == 0 {
+		return first == nil && len(rec.children) == 0
+	}
+	return first == rec.children[0] && sr.nodes[first].sameTag(first)
+}
+
+// sameParent returns true if n is at the position where it was parsed, in a
+// parent with the same name.
+func (sr *sourceRender) sameParent(n *html.Node) bool {
+	if !sr.nodes[n].inPlace(n) {
+		return false
+	}
+	prec := sr.nodes[n.Parent]
+	return prec != nil && prec.data == n.Parent.Data
+}
+
+// closedAfter returns true if n is implicitly closed by what follows it when
+// its end tag is omitted: its next sibling as it starts in the source, or the
+// end tag of its parent, which only closes the elements that are not special
+// if it is not an element with an end tag rule of its own.
+func (sr *sourceRender) closedAfter(n *html.Node) bool {
+	next := n.NextSibling
+	if next == nil {
+		parent := n.Parent
+		if parent == nil || parent.Type != html.ElementNode {
+			return true
+		}
+		switch n.Data {
+		case "option", "optgroup", "rb", "rp", "rt", "rtc":
+			return true
+		}
+		return isBlockElement(parent) || optionalEndTags[parent.Data]
+	}
+	rec := sr.nodes[next]
+	return rec != nil && rec.sameTag(next) && !rec.displaced
+}
+
+// renderSourceChildren renders the children of n, with the source between
+// them that is not part of the tree.
+func (r *renderer) renderSourceChildren(n *html.Node, rec *nodeSource) error {
+	if !rec.hasSpan || rec.displaced {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if err := r.renderSource(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	cursor := rec.tagEnd
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		crec := r.src.nodes[c]
+		if crec != nil && crec.hasSpan && !crec.displaced && crec.inPlace(c) &&
+			crec.start >= cursor && crec.end <= rec.closeStart {
+			r.writeSourceGap(cursor, crec.start)
+			cursor = crec.end
+		}
+		if err := r.renderSource(c); err != nil {
+			return err
+		}
+	}
+	r.writeSourceGap(cursor, rec.closeStart)
+	return nil
+}
+
+// writeSourceGap writes the source between start and end, except for the
+// excluded spans.
+func (r *renderer) writeSourceGap(start, end int) {
+	ex := r.src.excluded
+	i := sort.Search(len(ex), func(i int) bool { return ex[i][1] > start })
+	for ; i < len(ex) && start < end; i++ {
+		if ex[i][0] >= end {
+			break
+		}
+		if ex[i][0] > start {
+			r.w.WriteString(r.src.src[start:ex[i][0]])
+		}
+		start = max(start, ex[i][1])
+	}
+	if start < end {
+		r.w.WriteString(r.src.src[start:end])
+	}
+}
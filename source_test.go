@@ -0,0 +1,123 @@
+package goquery
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func loadSourceString(t *testing.T, src string) *Document {
+	doc, err := NewDocumentFromReaderWithSource(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func renderSource(t *testing.T, s *Selection) string {
+	got, err := OuterHtmlWithOptions(s, RenderOptions{PreserveSource: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestPreserveSourceUnchanged(t *testing.T) {
+	for _, f := range []string{"page.html", "page2.html", "page3.html", "gotesting.html", "gowiki.html", "metalreview.html"} {
+		b, err := os.ReadFile("testdata/" + f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc := loadSourceString(t, string(b))
+		if got := renderSource(t, doc.Selection); got != string(b) {
+			t.Errorf("%s: want the source unchanged", f)
+		}
+	}
+}
+
+func TestPreserveSourceMalformed(t *testing.T) {
+	for _, src := range []string{
+		"<p>x<table><tr><td>1</td></tr>oops<tr><td>2</table><b>1<p>2</b>3</p>",
+		"<ul><li>a<li>b</ul></p></br><span>c</div>\n",
+		"<html><body><p>a</p></body></html>\n\n",
+	} {
+		doc := loadSourceString(t, src)
+		if got := renderSource(t, doc.Selection); got != src {
+			t.Errorf("want %q, got %q", src, got)
+		}
+	}
+}
+
+func TestPreserveSourceChanges(t *testing.T) {
+	src := "<!DOCTYPE html>\n<HTML>\n<body>\n  <div ID=a class='x'>Hi <b>there</b> &amp; you</div>\n" +
+		"  <p>one<p>two\n  <ul><li>a<li>b</ul>\n</body>\n</HTML>\n"
+	cases := []struct {
+		change func(*Document)
+		want   string
+	}{
+		{
+			func(d *Document) { d.Find("div").SetAttr("data-x", "1") },
+			strings.Replace(src, "<div ID=a class='x'>", `<div id="a" class="x" data-x="1">`, 1),
+		},
+		{
+			func(d *Document) { d.Find("b").SetText("<you>") },
+			strings.Replace(src, "<b>there</b>", "<b>&lt;you&gt;</b>", 1),
+		},
+		{
+			func(d *Document) { d.Find("b").Remove() },
+			strings.Replace(src, "<b>there</b>", "", 1),
+		},
+		{
+			func(d *Document) { d.Find("div").AppendHtml("<i>!</i>") },
+			strings.Replace(src, "you</div>", "you<i>!</i></div>", 1),
+		},
+		{
+			// the omitted end tag is written when the next sibling changes
+			func(d *Document) { d.Find("li").Last().Remove() },
+			strings.Replace(src, "<li>a<li>b</ul>", "<li>a</li></ul>", 1),
+		},
+		{
+			func(d *Document) { d.Find("div").AppendSelection(d.Find("li").First()) },
+			strings.Replace(strings.Replace(src, "<li>a<li>b</ul>", "<li>b</li></ul>", 1),
+				"you</div>", "you<li>a</li></div>", 1),
+		},
+	}
+
+	for i, c := range cases {
+		doc := loadSourceString(t, src)
+		c.change(doc)
+		if got := renderSource(t, doc.Selection); got != c.want {
+			t.Errorf("%d: want\n%q\ngot\n%q", i, c.want, got)
+		}
+	}
+}
+
+func TestPreserveSourceImpliedEndTags(t *testing.T) {
+	// the end tags of the body and html elements stay omitted when their
+	// content changes
+	for _, src := range []string{"<p>a</p><p>b</p>", "<body><p>a</p><p>b</p>\n"} {
+		doc := loadSourceString(t, src)
+		doc.Find("p").Last().SetText("c")
+		if got, want := renderSource(t, doc.Selection), strings.Replace(src, "<p>b</p>", "<p>c</p>", 1); got != want {
+			t.Errorf("want %q, got %q", want, got)
+		}
+	}
+}
+
+func TestPreserveSourceSelection(t *testing.T) {
+	doc := loadSourceString(t, "<div>\n  <p CLASS=a>x &lt; y</p>\n</div>")
+	doc.Find("div").AppendHtml("<p>z</p>")
+	if got, want := renderSource(t, doc.Find("div")), "<div>\n  <p CLASS=a>x &lt; y</p>\n<p>z</p></div>"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got, want := renderSource(t, doc.Find("p").First()), "<p CLASS=a>x &lt; y</p>"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestPreserveSourceOtherDocuments(t *testing.T) {
+	doc := loadString(t, "<p CLASS=a>x</p>")
+	if got, want := renderSource(t, doc.Find("p")), `<p class="a">x</p>`; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,249 @@
+package goquery
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+// ErrStreamSelector is the error returned by Stream, wrapped with the reason,
+// when the selector is invalid or not supported.
+var ErrStreamSelector = errors.New("goquery: selector not supported by Stream")
+
+// streamPseudoClasses are the pseudo-classes supported by Stream, which only
+// depend on the element and its ancestors.
+var streamPseudoClasses = map[string]bool{
+	"checked": true, "input": true, "lang": true, "link": true, "not": true,
+	"root": true,
+}
+
+// Stream reads the HTML document from r and calls f with each element that
+// matches the selector, without building the tree of the whole document. Only
+// the open elements are kept while the document is read, and the subtree of
+// each matching element is built until its end tag, then passed to f and
+// discarded. It returns the first error returned by f, which stops the
+// reading, or the error of r other than io.EOF.
+//
+// The document is tokenized, not parsed: the elements are nested as their
+// tags are in the source, the end tags close the innermost open element of
+// the same name, and an element whose end tag may be omitted, such as p or
+// li, is also closed by a start tag of the same name. The elements that the
+// parser would insert, such as html, body or tbody, are not created, and
+// misnested tags are not fixed. As with Find, the content of the templates is
+// not searched.
+//
+// The selector may only use the type, universal, ID, class and attribute
+// selectors, with their namespace prefix as for Find, the descendant and
+// child combinators, and the :not(), :root, :link, :lang(), :checked and
+// :input pseudo-classes, which only depend on the element and its
+// ancestors. The sibling combinators (+ and ~) and the other pseudo-classes,
+// which depend on the siblings or the content of the elements, such as
+// :first-child or :contains(), are not supported, and an error wrapping
+// ErrStreamSelector is returned.
+//
+// The Selection passed to f holds the matching element, attached to its
+// ancestors, which only have the open elements as children. The nested
+// matching elements are passed to f in document order when the outermost one
+// ends. The element is detached from its parent when the calls to f for its
+// subtree return, and must not be modified by f.
+func Stream(r io.Reader, selector string, f func(*Selection) error) error {
+	if err := checkStreamSelector(selector); err != nil {
+		return err
+	}
+	m, err := parseNameSelector(selector)
+	if m == nil && err == nil {
+		m, err = cascadia.Compile(selector)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrStreamSelector, err)
+	}
+
+	root := &html.Node{Type: html.DocumentNode}
+	s := &streamer{m: m, f: f, doc: newDocument(root, nil), root: root, match: -1}
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if err := z.Err(); err != io.EOF {
+				return err
+			}
+			return s.closeTo(0)
+		}
+		if err := s.token(tt, z.Token()); err != nil {
+			return err
+		}
+	}
+}
+
+// checkStreamSelector returns an error if the selector uses a combinator or
+// a pseudo-class that is not supported by Stream.
+func checkStreamSelector(selector string) error {
+	var quote byte
+	var brackets int
+	for i := 0; i < len(selector); i++ {
+		c := selector[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\\':
+			i++
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			brackets++
+		case c == ']':
+			brackets--
+		case brackets > 0:
+		case c == '+' || c == '~':
+			return fmt.Errorf("%w: sibling combinator %q", ErrStreamSelector, c)
+		case c == ':':
+			j := i + 1
+			for j < len(selector) && (isAlphaNum(selector[j]) || selector[j] == '-' || selector[j] == ':') {
+				j++
+			}
+			if name := strings.ToLower(selector[i+1 : j]); !streamPseudoClasses[name] {
+				return fmt.Errorf("%w: pseudo-class :%s", ErrStreamSelector, name)
+			}
+			i = j - 1
+		}
+	}
+	return nil
+}
+
+func isAlphaNum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// streamer is the state of a Stream.
+type streamer struct {
+	m    Matcher
+	f    func(*Selection) error
+	doc  *Document
+	root *html.Node
+
+	// open are the open elements, from the outermost. match is the index
+	// in open of the outermost matching element, or -1 if there is none,
+	// and pending are the matching elements in its subtree.
+	open    []*html.Node
+	match   int
+	pending []*html.Node
+
+	// templates is the number of templates in open.
+	templates int
+}
+
+// token adds the node of the token to the open elements or the subtree of
+// the open matching element.
+func (s *streamer) token(tt html.TokenType, t html.Token) error {
+	parent := s.root
+	if len(s.open) > 0 {
+		parent = s.open[len(s.open)-1]
+	}
+
+	switch tt {
+	case html.StartTagToken, html.SelfClosingTagToken:
+		if optionalEndTags[t.Data] {
+			for i := len(s.open) - 1; i >= 0 && optionalEndTags[s.open[i].Data]; i-- {
+				if s.open[i].Data == t.Data {
+					if err := s.closeTo(i); err != nil {
+						return err
+					}
+					parent = s.root
+					if i > 0 {
+						parent = s.open[i-1]
+					}
+					break
+				}
+			}
+		}
+
+		n := &html.Node{Type: html.ElementNode, Data: t.Data, DataAtom: t.DataAtom, Attr: t.Attr}
+		var ns string
+		if parent.Type == html.ElementNode && isForeignContext(parent) {
+			ns = parent.Namespace
+		}
+		if t.Data == "svg" || t.Data == "math" {
+			ns = t.Data
+		}
+		if ns != "" {
+			// the names and attributes are adjusted as by the parser
+			setForeignNamespace(n, ns)
+		}
+		parent.AppendChild(n)
+		if s.templates == 0 && s.m.Match(n) {
+			s.pending = append(s.pending, n)
+			if s.match < 0 {
+				s.match = len(s.open)
+			}
+		}
+		if tt == html.SelfClosingTagToken || (n.Namespace == "" && voidElements[n.Data]) {
+			return s.closed(n, len(s.open))
+		}
+		if isTemplate(n) {
+			s.templates++
+		}
+		s.open = append(s.open, n)
+
+	case html.EndTagToken:
+		for i := len(s.open) - 1; i >= 0; i-- {
+			// the names of the SVG elements are not lowercase
+			if strings.EqualFold(s.open[i].Data, t.Data) {
+				return s.closeTo(i)
+			}
+		}
+
+	case html.TextToken:
+		if s.match >= 0 {
+			parent.AppendChild(&html.Node{Type: html.TextNode, Data: t.Data})
+		}
+
+	case html.CommentToken:
+		if s.match >= 0 {
+			parent.AppendChild(&html.Node{Type: html.CommentNode, Data: t.Data})
+		}
+	}
+	return nil
+}
+
+// closeTo closes the open elements from the index i.
+func (s *streamer) closeTo(i int) error {
+	for j := len(s.open) - 1; j >= i; j-- {
+		n := s.open[j]
+		s.open = s.open[:j]
+		if isTemplate(n) {
+			s.templates--
+		}
+		if err := s.closed(n, j); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// closed handles the end of the element n, at the index i of the open
+// elements: its subtree is passed to f if it is the outermost matching
+// element, and discarded unless it is in the subtree of such an element.
+func (s *streamer) closed(n *html.Node, i int) error {
+	switch {
+	case s.match == i:
+		s.match = -1
+		for _, p := range s.pending {
+			if err := s.f(newSingleSelection(p, s.doc)); err != nil {
+				return err
+			}
+		}
+		s.pending = s.pending[:0]
+	case s.match >= 0:
+		return nil
+	}
+	n.Parent.RemoveChild(n)
+	return nil
+}
@@ -0,0 +1,138 @@
+package goquery
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func streamHtml(t *testing.T, src, selector string) []string {
+	var got []string
+	err := Stream(strings.NewReader(src), selector, func(s *Selection) error {
+		h, err := OuterHtml(s)
+		if err != nil {
+			return err
+		}
+		got = append(got, h)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+func TestStream(t *testing.T) {
+	src := `<html><body><div id="a"><p class="x">one <b>1</b></p><p>two<p class="x">three</div>` +
+		`<ul><li><a href="/x">x</a><li><a>y</a></ul><img class="x" src="i.png"></body></html>`
+	cases := []struct {
+		selector string
+		want     []string
+	}{
+		{"p.x", []string{`<p class="x">one <b>1</b></p>`, `<p class="x">three</p>`}},
+		{"#a > p", []string{`<p class="x">one <b>1</b></p>`, `<p>two</p>`, `<p class="x">three</p>`}},
+		{"ul a[href]", []string{`<a href="/x">x</a>`}},
+		{"li", []string{`<li><a href="/x">x</a></li>`, `<li><a>y</a></li>`}},
+		{".x:not(p)", []string{`<img class="x" src="i.png"/>`}},
+		{"div b, a:link", []string{`<b>1</b>`, `<a href="/x">x</a>`}},
+	}
+	for _, c := range cases {
+		got := streamHtml(t, src, c.selector)
+		if strings.Join(got, "\n") != strings.Join(c.want, "\n") {
+			t.Errorf("%s: want %q, got %q", c.selector, c.want, got)
+		}
+	}
+}
+
+func TestStreamNamespace(t *testing.T) {
+	src := `<a href="/x">x</a><svg><a xlink:href="#y">y</a><foreignObject><a href="/z">z</a></foreignObject></svg>`
+	cases := []struct {
+		selector string
+		want     []string
+	}{
+		{"svg|a", []string{`<a xlink:href="#y">y</a>`}},
+		{"|a", []string{`<a href="/x">x</a>`, `<a href="/z">z</a>`}},
+		{"[xlink|href]", []string{`<a xlink:href="#y">y</a>`}},
+		{"svg|foreignObject", []string{`<foreignObject><a href="/z">z</a></foreignObject>`}},
+	}
+	for _, c := range cases {
+		got := streamHtml(t, src, c.selector)
+		if strings.Join(got, "\n") != strings.Join(c.want, "\n") {
+			t.Errorf("%s: want %q, got %q", c.selector, c.want, got)
+		}
+	}
+}
+
+func TestStreamNested(t *testing.T) {
+	got := streamHtml(t, `<div id="a"><div id="b"></div></div><template><div id="c"></div></template>`, "div")
+	want := []string{`<div id="a"><div id="b"></div></div>`, `<div id="b"></div>`}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestStreamAncestors(t *testing.T) {
+	var n int
+	err := Stream(strings.NewReader(`<div class="a"><p>1</p><span><p>2</p></span></div>`), "p", func(s *Selection) error {
+		n++
+		if !s.Closest("div").HasClass("a") {
+			t.Error("want the ancestors of the element")
+		}
+		if s.Parent().Children().Length() != 1 {
+			t.Error("want only the open elements as children of the ancestors")
+		}
+		return nil
+	})
+	if err != nil || n != 2 {
+		t.Errorf("want 2 calls and no error, got %d and %v", n, err)
+	}
+}
+
+func TestStreamError(t *testing.T) {
+	stop := errors.New("stop")
+	var n int
+	err := Stream(strings.NewReader(`<p>1</p><p>2</p>`), "p", func(s *Selection) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Errorf("want the error of f after 1 call, got %d and %v", n, err)
+	}
+
+	for _, sel := range []string{"p + p", "p ~ p", "li:first-child", "p:contains(x)", "p::before", "p:has(b)", "p["} {
+		err := Stream(strings.NewReader(`<p>1</p>`), sel, func(*Selection) error { return nil })
+		if !errors.Is(err, ErrStreamSelector) {
+			t.Errorf("%s: want ErrStreamSelector, got %v", sel, err)
+		}
+	}
+	for _, sel := range []string{`[title~="a"]`, `a[href^="x+y"]`, `:not(a, b) c`, `:root > body`} {
+		err := Stream(strings.NewReader(`<p>1</p>`), sel, func(*Selection) error { return nil })
+		if err != nil {
+			t.Errorf("%s: want no error, got %v", sel, err)
+		}
+	}
+}
+
+func TestStreamDocument(t *testing.T) {
+	b, err := os.ReadFile("testdata/gowiki.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sel := range []string{"a[class]", "#toc li", "td"} {
+		var got []string
+		err := Stream(strings.NewReader(string(b)), sel, func(s *Selection) error {
+			got = append(got, s.Text())
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := DocW().Find(sel).Map(func(i int, s *Selection) string {
+			return s.Text()
+		})
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("%s: want %d elements as Find, got %d", sel, len(want), len(got))
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package goquery
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// TemplateContent gets the content of each template element in the current
+// Selection, i.e. its child nodes. The content of the templates is inert:
+// Find and the other methods that search the descendants of the nodes skip
+// it, unless the search starts from the template itself or from its content.
+// It returns a new Selection object with the content nodes.
+func (s *Selection) TemplateContent() *Selection {
+	return pushStack(s, mapNodes(s.Nodes, func(i int, n *html.Node) []*html.Node {
+		if isTemplate(n) {
+			return childNodes(n)
+		}
+		return nil
+	}))
+}
+
+// ShadowRoot gets the declarative shadow root of each element in the current
+// Selection, i.e. its first template child with a shadowrootmode attribute,
+// open or closed. The shadow tree is the content of the template: it is
+// searched by Find from the returned template elements, or from the elements
+// themselves if the document has the Composed option. It returns a new
+// Selection object with the template elements.
+func (s *Selection) ShadowRoot() *Selection {
+	return pushStack(s, mapNodes(s.Nodes, func(i int, n *html.Node) []*html.Node {
+		if root := shadowRoot(n); root != nil {
+			return []*html.Node{root}
+		}
+		return nil
+	}))
+}
+
+// composed returns true if the descendants of the nodes are searched in the
+// composed tree.
+func (s *Selection) composed() bool {
+	return s.document != nil && s.document.Composed
+}
+
+// findAll returns the nodes of the document that match m, as Find does from
+// the document.
+func (d *Document) findAll(m Matcher) []*html.Node {
+	return matchDescendants(d.rootNode, m, d.Composed)
+}
+
+// matchDescendants returns the descendants of n that match m, skipping the
+// content of the templates below n, and in the composed tree if composed is
+// true. If m is a singleMatcher, at most one node is returned.
+func matchDescendants(n *html.Node, m Matcher, composed bool) []*html.Node {
+	if _, single := m.(singleMatcher); single {
+		if first := firstDescendant(n, m, composed); first != nil {
+			return []*html.Node{first}
+		}
+		return nil
+	}
+
+	var result []*html.Node
+	if composed {
+		walkDescendants(n, true, true, func(c *html.Node) bool {
+			if c.Type == html.ElementNode && m.Match(c) {
+				result = append(result, c)
+			}
+			return true
+		})
+		return result
+	}
+
+	// use the matcher to search the subtrees, and drop the matches in the
+	// templates
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		matches := m.MatchAll(c)
+		live := matches[:0]
+		for _, mn := range matches {
+			if !inTemplate(mn, n) {
+				live = append(live, mn)
+			}
+		}
+		result = append(result, live...)
+	}
+	return result
+}
+
+// firstDescendant returns the first of the nodes returned by matchDescendants,
+// or nil if there is none.
+func firstDescendant(n *html.Node, m Matcher, composed bool) *html.Node {
+	var first *html.Node
+	match := func(c *html.Node) bool {
+		if c.Type == html.ElementNode && m.Match(c) {
+			first = c
+			return false
+		}
+		return true
+	}
+	if composed {
+		walkDescendants(n, true, true, match)
+		return first
+	}
+
+	sm := singleMatcher{m}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		if first = sm.MatchFirst(c); first == nil {
+			continue
+		}
+		if !inTemplate(first, n) {
+			return first
+		}
+		// there may be a match after the inert one, c does not match
+		first = nil
+		if walkDescendants(c, false, false, match); first != nil {
+			return first
+		}
+	}
+	return nil
+}
+
+// walkDescendants calls f with the descendants of n in tree order, until it
+// returns false. The content of the templates is skipped, unless n is the
+// template and root is true. If composed is true, the composed tree is walked:
+// the children of a shadow host are the content of its shadow root, and those
+// of a slot are the nodes assigned to it or, if there is none, its own. It
+// returns false if f returned false.
+func walkDescendants(n *html.Node, root, composed bool, f func(*html.Node) bool) bool {
+	if isTemplate(n) && !root {
+		return true
+	}
+	if !composed {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if !f(c) || !walkDescendants(c, false, false, f) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, c := range composedChildren(n, true) {
+		if !f(c) || !walkDescendants(c, false, composed, f) {
+			return false
+		}
+	}
+	return true
+}
+
+// composedChildren returns the children of n, in the composed tree if composed
+// is true.
+func composedChildren(n *html.Node, composed bool) []*html.Node {
+	if composed {
+		if root := shadowRoot(n); root != nil {
+			return childNodes(root)
+		}
+		if n.Type == html.ElementNode && n.DataAtom == atom.Slot && n.Namespace == "" {
+			if assigned := assignedNodes(n); len(assigned) > 0 {
+				return assigned
+			}
+		}
+	}
+	return childNodes(n)
+}
+
+// shadowRoot returns the template that declares the shadow root of n, or nil.
+func shadowRoot(n *html.Node) *html.Node {
+	if n.Type != html.ElementNode {
+		return nil
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if !isTemplate(c) {
+			continue
+		}
+		if mode, ok := attrValue(c, "shadowrootmode"); ok {
+			if mode = strings.ToLower(mode); mode == "open" || mode == "closed" {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// assignedNodes returns the children of the shadow host that are assigned to
+// the slot: the elements with the slot attribute of the same name, and, for
+// the default slot with no name, the text nodes and the elements with no slot
+// attribute. Only the first slot of a name in the shadow tree is assigned
+// nodes.
+func assignedNodes(slot *html.Node) []*html.Node {
+	var root *html.Node
+	for p := slot.Parent; p != nil; p = p.Parent {
+		if isTemplate(p) {
+			root = p
+			break
+		}
+	}
+	if root == nil || root.Parent == nil || shadowRoot(root.Parent) != root {
+		return nil
+	}
+
+	name, _ := attrValue(slot, "name")
+	var first *html.Node
+	walkDescendants(root, true, false, func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Slot && n.Namespace == "" {
+			if sn, _ := attrValue(n, "name"); sn == name {
+				first = n
+				return false
+			}
+		}
+		return true
+	})
+	if first != slot {
+		return nil
+	}
+
+	var nodes []*html.Node
+	for c := root.Parent.FirstChild; c != nil; c = c.NextSibling {
+		switch {
+		case c == root:
+		case c.Type == html.ElementNode:
+			if sn, _ := attrValue(c, "slot"); sn == name {
+				nodes = append(nodes, c)
+			}
+		case c.Type == html.TextNode && name == "":
+			nodes = append(nodes, c)
+		}
+	}
+	return nodes
+}
+
+// inTemplate returns true if n is in the content of a template below root.
+func inTemplate(n, root *html.Node) bool {
+	for p := n.Parent; p != nil && p != root; p = p.Parent {
+		if isTemplate(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func isTemplate(n *html.Node) bool {
+	return n.Type == html.ElementNode && n.DataAtom == atom.Template && n.Namespace == ""
+}
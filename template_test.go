@@ -0,0 +1,106 @@
+package goquery
+
+import (
+	"strings"
+	"testing"
+)
+
+const shadowHTML = `<body>
+<p class="x">live</p>
+<template id="t"><p class="x">inert</p><template><p class="x">nested</p></template></template>
+<my-card id="c1">
+  <template shadowrootmode="open"><header><slot name="title">Untitled</slot></header><div class="body"><slot></slot></div><slot name="footer"><i>no footer</i></slot></template>
+  <h2 slot="title">Card</h2>
+  <p class="x">light</p>
+</my-card>
+</body>`
+
+func TestTemplateInert(t *testing.T) {
+	doc := loadString(t, shadowHTML)
+	if got := doc.Find("p.x").Text(); got != "livelight" {
+		t.Errorf("want the live paragraphs, got %q", got)
+	}
+	if got := doc.Find("template").Length(); got != 2 {
+		t.Errorf("want the templates of the document, got %d", got)
+	}
+	if got := doc.Find("body").Has("slot").Length(); got != 0 {
+		t.Errorf("want no slot outside of the templates, got %d", got)
+	}
+	if got := doc.FindMatcher(Single("p.x")).Text(); got != "live" {
+		t.Errorf("want live, got %q", got)
+	}
+	if got := loadString(t, `<template><p>a</p></template><p>b</p>`).FindMatcher(Single("p")).Text(); got != "b" {
+		t.Errorf("want the first live match, got %q", got)
+	}
+
+	// the search from the template includes its content, but not that of
+	// the nested template
+	tpl := doc.Find("#t")
+	if got := tpl.Find("p").Text(); got != "inert" {
+		t.Errorf("want inert, got %q", got)
+	}
+	content := tpl.TemplateContent()
+	if got := content.Length(); got != 2 {
+		t.Fatalf("want the 2 content nodes, got %d", got)
+	}
+	if got := content.Last().TemplateContent().Text(); got != "nested" {
+		t.Errorf("want nested, got %q", got)
+	}
+	if got := doc.Find("p").TemplateContent().Length(); got != 0 {
+		t.Errorf("want no content for the other elements, got %d", got)
+	}
+
+	// the targets of the manipulations are not looked for in the templates
+	doc.Find("i").AppendTo("p.x")
+	if got := tpl.Find("i").Length(); got != 0 {
+		t.Errorf("want no change in the template, got %d", got)
+	}
+}
+
+func TestShadowRoot(t *testing.T) {
+	doc := loadString(t, shadowHTML)
+	root := doc.Find("my-card").ShadowRoot()
+	if got := root.Length(); got != 1 || !root.Is("template[shadowrootmode]") {
+		t.Fatalf("want the shadow root template, got %d nodes", got)
+	}
+	if got := root.Find("slot").Length(); got != 3 {
+		t.Errorf("want the 3 slots, got %d", got)
+	}
+	if got := doc.Find("#t, p").ShadowRoot().Length(); got != 0 {
+		t.Errorf("want no shadow root, got %d", got)
+	}
+}
+
+func TestComposed(t *testing.T) {
+	doc, err := NewDocumentFromReaderWithOptions(strings.NewReader(shadowHTML), DocumentOptions{Composed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	card := doc.Find("my-card")
+	var names []string
+	card.Find("*").Each(func(_ int, s *Selection) {
+		names = append(names, NodeName(s))
+	})
+	if got := strings.Join(names, ","); got != "header,slot,h2,div,slot,p,slot,i" {
+		t.Errorf("want the composed tree, got %s", got)
+	}
+	if got := card.Find("header h2, .body p").Length(); got != 0 {
+		t.Errorf("want the selectors matched in the document tree, got %d", got)
+	}
+	if got := card.Find(".x").Text(); got != "light" {
+		t.Errorf("want light, got %q", got)
+	}
+	if got := card.Has("i").Length(); got != 1 {
+		t.Errorf("want the fallback content of the slot, got %d", got)
+	}
+	if got := doc.Find("p.x").Text(); got != "livelight" {
+		t.Errorf("want the live paragraphs, got %q", got)
+	}
+
+	// the unassigned children are not in the composed tree
+	card.AppendHtml(`<span slot="nowhere">x</span>`)
+	if got := doc.Find("span").Length(); got != 0 {
+		t.Errorf("want no unassigned span, got %d", got)
+	}
+}
@@ -0,0 +1,480 @@
+package goquery
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// TextOptions configures the methods that operate on the text of a
+// Selection, such as ReplaceTextWithOptions.
+type TextOptions struct {
+	// IncludeRawText includes the contents of script and style elements, which
+	// are skipped by default.
+	IncludeRawText bool
+
+	// IncludeAttributes also applies the operation to the attribute values of
+	// the elements, which are skipped by default.
+	IncludeAttributes bool
+}
+
+// ReplaceText replaces the matches of the regular expression in the text of
+// each element in the set of matched elements, including their descendants,
+// with the value returned by repl for that match. It returns the current
+// Selection object.
+//
+// The regular expression is applied to the logical text of each element, as
+// returned by Text, so a match may span multiple text nodes, e.g. "Acme Corp"
+// in "<b>Acme</b> Corp". Only the text nodes involved in a match are changed,
+// the markup is left untouched: the replacement is inserted in the text node
+// where the match starts, and the rest of the match is removed from the
+// following text nodes. Text nodes that end up empty are removed.
+//
+// The contents of script and style elements and the attribute values are
+// skipped, use ReplaceTextWithOptions to include them.
+func (s *Selection) ReplaceText(re *regexp.Regexp, repl func(string) string) *Selection {
+	return s.ReplaceTextWithOptions(re, repl, TextOptions{})
+}
+
+// ReplaceTextWithOptions is like ReplaceText, with the options to include the
+// contents of script and style elements and the attribute values.
+func (s *Selection) ReplaceTextWithOptions(re *regexp.Regexp, repl func(string) string, opts TextOptions) *Selection {
+	for _, root := range textRoots(s.Nodes) {
+		skip := isRawTextElement
+		if opts.IncludeRawText {
+			skip = nil
+		}
+		ix := newTextIndex([]*html.Node{root}, skip)
+		for _, m := range re.FindAllStringIndex(ix.text, -1) {
+			ix.replace(m[0], m[1], repl(ix.text[m[0]:m[1]]))
+		}
+		ix.apply(s.document)
+
+		if opts.IncludeAttributes {
+			replaceAttributes(s.document, root, re, repl, opts.IncludeRawText)
+		}
+	}
+	return s
+}
+
+// WrapText wraps each match of the regular expression in the text of each
+// element in the set of matched elements, including their descendants, inside
+// a clone of the first element of the given HTML. Like WrapHtml, the matched
+// text is inserted in the inner-most child of that element. It returns a new
+// Selection object containing the created wrappers, in document order.
+//
+// As with ReplaceText, a match may span multiple text nodes. The text nodes
+// are split at the boundaries of the matches, and the match is wrapped with
+// the least nesting that keeps the HTML valid: inline elements (such as b or
+// span) that are entirely part of the match are wrapped along with their
+// text, otherwise a separate wrapper is created for each part of the match,
+// e.g. wrapping "Acme Corp" in "<b>Acme</b> Corp" with "<mark>" gives
+// "<mark><b>Acme</b> Corp</mark>", while in "<b>The Acme</b> Corp" it gives
+// "<b>The <mark>Acme</mark></b><mark> Corp</mark>".
+//
+// The contents of elements that cannot contain other elements, such as
+// script, style, textarea and title, are skipped, as are empty matches.
+func (s *Selection) WrapText(re *regexp.Regexp, wrapperHtml string) *Selection {
+	wrapper := getFirstElement(parseHtml(wrapperHtml))
+	if wrapper == nil {
+		return pushStack(s, nil)
+	}
+
+	var wrappers []*html.Node
+	for _, root := range textRoots(s.Nodes) {
+		ix := newTextIndex([]*html.Node{root}, isTextOnlyElement)
+		var ranges [][2]int
+		for _, m := range re.FindAllStringIndex(ix.text, -1) {
+			if m[0] < m[1] {
+				ranges = append(ranges, [2]int{m[0], m[1]})
+			}
+		}
+		wrappers = append(wrappers, ix.wrap(s.document, []*html.Node{root}, ranges, wrapper)...)
+	}
+	return pushStack(s, wrappers)
+}
+
+// textEdit is a pending change to the text of a node of a textIndex.
+type textEdit struct {
+	start, end int
+	repl       string
+}
+
+// textIndex holds the text nodes of a subtree in document order, along with
+// their concatenated text, so that offsets in the logical text of the subtree
+// can be mapped to the text nodes.
+type textIndex struct {
+	text    string
+	nodes   []*html.Node
+	offsets []int
+	edits   [][]textEdit
+}
+
+// newTextIndex creates the textIndex of the subtrees of roots, in order. The
+// contents of the elements for which skip returns true are excluded from the
+// index.
+func newTextIndex(roots []*html.Node, skip func(*html.Node) bool) *textIndex {
+	var builder strings.Builder
+	ix := &textIndex{}
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			ix.nodes = append(ix.nodes, n)
+			ix.offsets = append(ix.offsets, builder.Len())
+			builder.WriteString(n.Data)
+			return
+		}
+		if n.Type == html.ElementNode && skip != nil && skip(n) {
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	for _, root := range roots {
+		f(root)
+	}
+	ix.text = builder.String()
+	return ix
+}
+
+// locate returns the index of the text node that holds the byte at offset off
+// of the text. For off == len(text), it returns the index of the last node.
+// It returns -1 if there is no text node.
+func (ix *textIndex) locate(off int) int {
+	i := sort.Search(len(ix.nodes), func(i int) bool {
+		return ix.offsets[i]+len(ix.nodes[i].Data) > off
+	})
+	if i == len(ix.nodes) {
+		i--
+	}
+	return i
+}
+
+// replace records the replacement of the text between start and end with
+// repl. The replacement is inserted in the node that holds start, the text
+// of the other nodes in the range is removed. Calls must be made in order of
+// increasing, non-overlapping ranges, and take effect when apply is called.
+func (ix *textIndex) replace(start, end int, repl string) {
+	first := ix.locate(start)
+	if first < 0 {
+		return
+	}
+	if ix.edits == nil {
+		ix.edits = make([][]textEdit, len(ix.nodes))
+	}
+	for i := first; i < len(ix.nodes); i++ {
+		off := ix.offsets[i]
+		if i > first && off >= end {
+			break
+		}
+		e := textEdit{
+			start: max(start, off) - off,
+			end:   min(end, off+len(ix.nodes[i].Data)) - off,
+		}
+		if i == first {
+			e.repl = repl
+		}
+		ix.edits[i] = append(ix.edits[i], e)
+	}
+}
+
+// apply applies the recorded replacements to the text nodes, removing those
+// that end up empty. The index must not be used after the call.
+func (ix *textIndex) apply(d *Document) {
+	for i, edits := range ix.edits {
+		if len(edits) == 0 {
+			continue
+		}
+		n := ix.nodes[i]
+		var builder strings.Builder
+		pos := 0
+		for _, e := range edits {
+			builder.WriteString(n.Data[pos:e.start])
+			builder.WriteString(e.repl)
+			pos = e.end
+		}
+		builder.WriteString(n.Data[pos:])
+
+		if data := builder.String(); data != "" || n.Parent == nil {
+			d.setData(n, data)
+		} else {
+			d.removeChild(n.Parent, n)
+		}
+	}
+	ix.edits = nil
+}
+
+// replaceAttributes applies the regular expression replacement to the values
+// of the attributes of root and its descendant elements.
+func replaceAttributes(d *Document, root *html.Node, re *regexp.Regexp, repl func(string) string, includeRaw bool) {
+	if root.Type == html.ElementNode {
+		if !includeRaw && isRawTextElement(root) {
+			return
+		}
+		for i := range root.Attr {
+			old := root.Attr[i].Val
+			if val := re.ReplaceAllStringFunc(old, repl); val != old {
+				root.Attr[i].Val = val
+				d.attrChanged(root, root.Attr[i].Key, old, true)
+			}
+		}
+	}
+	for c := root.FirstChild; c != nil; c = c.NextSibling {
+		replaceAttributes(d, c, re, repl, includeRaw)
+	}
+}
+
+// isRawTextElement returns true if n is a script or style element, whose
+// contents are not text from the user's perspective.
+func isRawTextElement(n *html.Node) bool {
+	if n.Type != html.ElementNode || n.Namespace != "" {
+		return false
+	}
+	return n.DataAtom == atom.Script || n.DataAtom == atom.Style
+}
+
+// textRoots returns the nodes that are not descendants of another node of
+// the slice, so that each subtree is processed only once.
+func textRoots(nodes []*html.Node) []*html.Node {
+	if len(nodes) < 2 {
+		return nodes
+	}
+	roots := make([]*html.Node, 0, len(nodes))
+	for _, n := range nodes {
+		if !sliceContains(nodes, n) && !isInSlice(roots, n) {
+			roots = append(roots, n)
+		}
+	}
+	return roots
+}
+
+// split splits the text nodes of the index so that each offset of offsets
+// falls on the boundary of a text node, and updates the index accordingly.
+// The offsets must be sorted.
+func (ix *textIndex) split(d *Document, offsets []int) {
+	var nodes []*html.Node
+	var starts []int
+	oi := 0
+	for i, n := range ix.nodes {
+		start := ix.offsets[i]
+		nodes, starts = append(nodes, n), append(starts, start)
+		for ; oi < len(offsets) && offsets[oi] <= start; oi++ {
+		}
+		for ; oi < len(offsets) && offsets[oi] < start+len(n.Data); oi++ {
+			if n.Parent == nil {
+				continue
+			}
+			off := offsets[oi]
+			next := &html.Node{Type: html.TextNode, Data: n.Data[off-start:]}
+			d.setData(n, n.Data[:off-start])
+			d.insertBefore(n.Parent, next, n.NextSibling)
+			n, start = next, off
+			nodes, starts = append(nodes, n), append(starts, start)
+		}
+	}
+	ix.nodes, ix.offsets = nodes, starts
+}
+
+// wrap wraps the text of each range of the index inside a clone of wrapper,
+// and returns the created wrappers. The text nodes are split as needed, see
+// Selection.WrapText for details. Inline ancestors are wrapped only up to the
+// roots of the index, exclusively. The ranges must be sorted and must not
+// overlap.
+func (ix *textIndex) wrap(d *Document, roots []*html.Node, ranges [][2]int, wrapper *html.Node) []*html.Node {
+	if len(ranges) == 0 {
+		return nil
+	}
+	offsets := make([]int, 0, 2*len(ranges))
+	for _, r := range ranges {
+		offsets = append(offsets, r[0], r[1])
+	}
+	ix.split(d, offsets)
+
+	var wrappers []*html.Node
+	for _, r := range ranges {
+		// The text nodes that make up the range.
+		inRange := make(map[*html.Node]bool)
+		var nodes []*html.Node
+		for i := ix.locate(r[0]); i >= 0 && i < len(ix.nodes) && ix.offsets[i] < r[1]; i++ {
+			if n := ix.nodes[i]; n.Data != "" && n.Parent != nil && !isTextOnlyElement(n.Parent) {
+				inRange[n] = true
+				nodes = append(nodes, n)
+			}
+		}
+
+		// Lift each text node to its highest inline ancestor that holds only
+		// text of the range, and group the siblings.
+		var tops []*html.Node
+		for _, n := range nodes {
+			for p := n.Parent; p != nil && !isInSlice(roots, p) && isInlineElement(p) && allTextIn(p, inRange); p = p.Parent {
+				n = p
+			}
+			if !isInSlice(tops, n) && (len(tops) == 0 || !nodeContains(tops[len(tops)-1], n)) {
+				tops = append(tops, n)
+			}
+		}
+
+		for len(tops) > 0 {
+			first, last := tops[0], tops[0]
+			tops = tops[1:]
+			for len(tops) > 0 && adjacentSiblings(last, tops[0], inRange) {
+				last, tops = tops[0], tops[1:]
+			}
+			wrappers = append(wrappers, wrapSiblings(d, first, last, wrapper))
+		}
+	}
+	return wrappers
+}
+
+// wrapSiblings wraps the siblings from first to last inside the inner-most
+// child of a clone of wrapper, and returns the clone.
+func wrapSiblings(d *Document, first, last, wrapper *html.Node) *html.Node {
+	wrap := cloneNode(wrapper)
+	parent := first.Parent
+	d.insertBefore(parent, wrap, first)
+
+	inner := wrap
+	for c := getFirstChildEl(inner); c != nil; c = getFirstChildEl(inner) {
+		inner = c
+	}
+	for n := first; n != nil; {
+		next := n.NextSibling
+		d.removeChild(parent, n)
+		d.appendChild(inner, n)
+		if n == last {
+			break
+		}
+		n = next
+	}
+	return wrap
+}
+
+// adjacentSiblings returns true if b follows a in the same parent, with only
+// nodes that hold no text of the index in between (e.g. comments or images).
+func adjacentSiblings(a, b *html.Node, inRange map[*html.Node]bool) bool {
+	if a.Parent != b.Parent {
+		return false
+	}
+	for n := a.NextSibling; n != nil; n = n.NextSibling {
+		if n == b {
+			return true
+		}
+		if hasText(n) {
+			return false
+		}
+	}
+	return false
+}
+
+// allTextIn returns true if all the non-empty text nodes of the subtree of n
+// are in the set.
+func allTextIn(n *html.Node, set map[*html.Node]bool) bool {
+	if n.Type == html.TextNode {
+		return n.Data == "" || set[n]
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if !allTextIn(c, set) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasText returns true if the subtree of n holds a non-empty text node.
+func hasText(n *html.Node) bool {
+	if n.Type == html.TextNode {
+		return n.Data != ""
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if hasText(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// inlineElements is the set of HTML elements that are phrasing content and
+// only hold phrasing content, so that they can be wrapped inside an inline
+// element (and vice versa) while keeping the HTML valid.
+var inlineElements = map[atom.Atom]bool{
+	atom.A: true, atom.Abbr: true, atom.B: true, atom.Bdi: true, atom.Bdo: true,
+	atom.Big: true, atom.Cite: true, atom.Code: true, atom.Data: true,
+	atom.Del: true, atom.Dfn: true, atom.Em: true, atom.Font: true, atom.I: true,
+	atom.Ins: true, atom.Kbd: true, atom.Label: true, atom.Mark: true,
+	atom.Nobr: true, atom.Q: true, atom.S: true, atom.Samp: true,
+	atom.Small: true, atom.Span: true, atom.Strike: true, atom.Strong: true,
+	atom.Sub: true, atom.Sup: true, atom.Time: true, atom.Tt: true,
+	atom.U: true, atom.Var: true,
+}
+
+// isInlineElement returns true if n is an HTML inline element.
+func isInlineElement(n *html.Node) bool {
+	return n.Type == html.ElementNode && n.Namespace == "" && inlineElements[n.DataAtom]
+}
+
+// blockElements is the set of HTML elements that are rendered as blocks, or
+// that are otherwise not part of the inline formatting of the text, so that
+// whitespace next to them is insignificant.
+var blockElements = map[atom.Atom]bool{
+	atom.Address: true, atom.Article: true, atom.Aside: true,
+	atom.Blockquote: true, atom.Body: true, atom.Caption: true,
+	atom.Colgroup: true, atom.Col: true, atom.Dd: true, atom.Details: true,
+	atom.Dialog: true, atom.Div: true, atom.Dl: true, atom.Dt: true,
+	atom.Fieldset: true, atom.Figcaption: true, atom.Figure: true,
+	atom.Footer: true, atom.Form: true, atom.H1: true, atom.H2: true,
+	atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true, atom.Head: true,
+	atom.Header: true, atom.Hgroup: true, atom.Hr: true, atom.Html: true,
+	atom.Li: true, atom.Link: true, atom.Main: true, atom.Menu: true,
+	atom.Meta: true, atom.Nav: true, atom.Ol: true, atom.Optgroup: true,
+	atom.Option: true, atom.P: true, atom.Pre: true, atom.Section: true,
+	atom.Summary: true, atom.Table: true, atom.Tbody: true, atom.Td: true,
+	atom.Template: true, atom.Tfoot: true, atom.Th: true, atom.Thead: true,
+	atom.Title: true, atom.Tr: true, atom.Ul: true,
+}
+
+// isBlockElement returns true if n is an HTML block element.
+func isBlockElement(n *html.Node) bool {
+	return n.Type == html.ElementNode && n.Namespace == "" && blockElements[n.DataAtom]
+}
+
+// isWhitespaceSensitive returns true if n is an element whose text is
+// rendered with its whitespace preserved, or is not rendered as text.
+func isWhitespaceSensitive(n *html.Node) bool {
+	if n.Type != html.ElementNode || n.Namespace != "" {
+		return false
+	}
+	switch n.DataAtom {
+	case atom.Pre, atom.Textarea, atom.Listing, atom.Plaintext, atom.Xmp,
+		atom.Script, atom.Style:
+		return true
+	}
+	return false
+}
+
+// isTextOnlyElement returns true if n is an element whose contents are raw
+// text that cannot hold other elements.
+func isTextOnlyElement(n *html.Node) bool {
+	if n.Type != html.ElementNode || n.Namespace != "" {
+		return false
+	}
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Textarea, atom.Title, atom.Xmp,
+		atom.Iframe, atom.Noembed, atom.Noframes, atom.Noscript, atom.Plaintext:
+		return true
+	}
+	return false
+}
+
+// getFirstElement returns the first element node of the slice, or nil.
+func getFirstElement(nodes []*html.Node) *html.Node {
+	for _, n := range nodes {
+		if n.Type == html.ElementNode {
+			return n
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package goquery
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestReplaceText(t *testing.T) {
+	cases := []struct {
+		src  string
+		re   string
+		repl func(string) string
+		want string
+	}{
+		0: {
+			src:  `<p>Welcome to Acme Corp, the Acme Corp way.</p>`,
+			re:   `Acme Corp`,
+			repl: func(string) string { return "ACME" },
+			want: `<p>Welcome to ACME, the ACME way.</p>`,
+		},
+		1: {
+			src:  `<p>Welcome to <b>Acme</b> Corp!</p>`,
+			re:   `Acme Corp`,
+			repl: func(string) string { return "ACME" },
+			want: `<p>Welcome to <b>ACME</b>!</p>`,
+		},
+		2: {
+			src:  `<p>Acme<i> </i>Corp<b>Acme</b></p>`,
+			re:   `Acme Corp`,
+			repl: func(string) string { return "ACME" },
+			want: `<p>ACME<i></i><b>Acme</b></p>`,
+		},
+		3: {
+			src:  `<p>mail <a href="mailto:jo@example.com">jo@example.com</a> or al@exam<b>ple.org</b></p>`,
+			re:   `\w+@[\w.]+`,
+			repl: func(m string) string { return strings.Repeat("*", len(m)) },
+			want: `<p>mail <a href="mailto:jo@example.com">**************</a> or **************<b></b></p>`,
+		},
+		4: {
+			src:  `<p>a<script>var a = 1;</script><style>a{}</style>a</p>`,
+			re:   `a`,
+			repl: strings.ToUpper,
+			want: `<p>A<script>var a = 1;</script><style>a{}</style>A</p>`,
+		},
+		5: {
+			src:  `<p>no match</p>`,
+			re:   `x+`,
+			repl: strings.ToUpper,
+			want: `<p>no match</p>`,
+		},
+	}
+
+	for i, c := range cases {
+		doc := loadString(t, c.src)
+		doc.Find("p").ReplaceText(regexp.MustCompile(c.re), c.repl)
+		got, err := OuterHtml(doc.Find("p"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("[%d] want %q, got %q", i, c.want, got)
+		}
+	}
+}
+
+func TestReplaceTextWithOptions(t *testing.T) {
+	doc := loadString(t, `<div title="Acme Corp"><p>Acme <b>Corp</b></p><script>"Acme Corp"</script></div>`)
+	doc.Find("div, p").ReplaceTextWithOptions(regexp.MustCompile(`Acme Corp`), func(string) string {
+		return "ACME Acme Corp"
+	}, TextOptions{IncludeRawText: true, IncludeAttributes: true})
+
+	got, err := OuterHtml(doc.Find("div"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<div title="ACME Acme Corp"><p>ACME Acme Corp<b></b></p><script>"ACME Acme Corp"</script></div>`
+	if got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestReplaceTextMutations(t *testing.T) {
+	doc := loadString(t, `<p>Acme<b> Corp</b></p>`)
+	records := collectMutations(doc)
+	doc.Find("p").ReplaceText(regexp.MustCompile(`Acme Corp`), func(string) string { return "ACME" })
+
+	if len(*records) != 2 {
+		t.Fatalf("want 2 records, got %d", len(*records))
+	}
+	if r := (*records)[0]; r.Type != MutationCharacterData || r.OldValue != "Acme" || r.NewValue != "ACME" {
+		t.Errorf("unexpected record %s %q -> %q", r.Type, r.OldValue, r.NewValue)
+	}
+	if r := (*records)[1]; r.Type != MutationChildList || len(r.RemovedNodes) != 1 || !r.Target.Is("b") {
+		t.Errorf("unexpected record %s on %s", r.Type, NodeName(r.Target))
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	cases := []struct {
+		src  string
+		re   string
+		wrap string
+		want string
+		cnt  int
+	}{
+		0: {
+			src:  `<p>Welcome to Acme Corp, the Acme Corp way.</p>`,
+			re:   `Acme Corp`,
+			wrap: `<mark></mark>`,
+			want: `<p>Welcome to <mark>Acme Corp</mark>, the <mark>Acme Corp</mark> way.</p>`,
+			cnt:  2,
+		},
+		1: {
+			src:  `<p>Welcome to <b>Acme</b> Corp!</p>`,
+			re:   `Acme Corp`,
+			wrap: `<mark></mark>`,
+			want: `<p>Welcome to <mark><b>Acme</b> Corp</mark>!</p>`,
+			cnt:  1,
+		},
+		2: {
+			src:  `<p>Welcome to <b>The Acme</b> Corp!</p>`,
+			re:   `Acme Corp`,
+			wrap: `<mark></mark>`,
+			want: `<p>Welcome to <b>The <mark>Acme</mark></b><mark> Corp</mark>!</p>`,
+			cnt:  2,
+		},
+		3: {
+			src:  `<div><p>Acme</p><p>Corp</p></div>`,
+			re:   `AcmeCorp`,
+			wrap: `<span class="org" data-x="1"></span>`,
+			want: `<div><p><span class="org" data-x="1">Acme</span></p><p><span class="org" data-x="1">Corp</span></p></div>`,
+			cnt:  2,
+		},
+		4: {
+			src:  `<p>x<script>x</script><textarea>x</textarea><b>y</b></p>`,
+			re:   `x+`,
+			wrap: `<em><i></i></em>`,
+			want: `<p><em><i>x</i></em><script>x</script><textarea>x</textarea><b>y</b></p>`,
+			cnt:  1,
+		},
+		5: {
+			src:  `<p>no match</p>`,
+			re:   `z*`,
+			wrap: `<mark></mark>`,
+			want: `<p>no match</p>`,
+			cnt:  0,
+		},
+		6: {
+			src:  `<p>text</p>`,
+			re:   `text`,
+			wrap: `no element`,
+			want: `<p>text</p>`,
+			cnt:  0,
+		},
+	}
+
+	for i, c := range cases {
+		doc := loadString(t, c.src)
+		sel := doc.Find("body > *").WrapText(regexp.MustCompile(c.re), c.wrap)
+		got, err := OuterHtml(doc.Find("body > *"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("[%d] want %q, got %q", i, c.want, got)
+		}
+		if sel.Length() != c.cnt {
+			t.Errorf("[%d] want %d wrappers, got %d", i, c.cnt, sel.Length())
+		}
+	}
+}
+
+func TestWrapTextText(t *testing.T) {
+	doc := loadString(t, `<p>One <i>two</i> three <b>t<u>wo</u></b> four</p>`)
+	p := doc.Find("p")
+	text := p.Text()
+	sel := p.WrapText(regexp.MustCompile(`two`), `<mark>`)
+
+	assertLength(t, sel.Nodes, 2)
+	if got := p.Text(); got != text {
+		t.Errorf("want text %q to be unchanged, got %q", text, got)
+	}
+	if got := sel.Text(); got != "twotwo" {
+		t.Errorf("want wrapped text %q, got %q", "twotwo", got)
+	}
+	assertLength(t, doc.Find("mark > i, mark > b").Nodes, 2)
+}
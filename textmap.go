@@ -0,0 +1,206 @@
+package goquery
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// TextMap maps character offsets in the text of a Selection, as returned by
+// Selection.Text, to the text nodes that hold those characters, and back.
+// Offsets are either byte or rune offsets, depending on the constructor used
+// to create the TextMap, and the same unit is used for the offsets local to a
+// text node.
+//
+// A TextMap is a snapshot of the text nodes at the time it is created (or at
+// the last call to one of its wrapping methods). It must be created again if
+// the text or the structure of the document changes by other means.
+type TextMap struct {
+	sel   *Selection
+	runes bool
+	ix    *textIndex
+
+	// byte offset of each rune of the text, created on demand for rune
+	// offsets of non-ASCII text.
+	runeOffsets []int
+}
+
+// TextSpan is the part of a text node that holds a range of text.
+type TextSpan struct {
+	// Node is the text node.
+	Node *html.Node
+	// Start and End are the offsets of the range in the node's Data, in
+	// the unit of the TextMap.
+	Start, End int
+}
+
+// NewTextMap creates a TextMap for the text of the selection, using byte
+// offsets.
+func NewTextMap(s *Selection) *TextMap {
+	return newTextMap(s, false)
+}
+
+// NewTextMapRunes creates a TextMap for the text of the selection, using rune
+// offsets.
+func NewTextMapRunes(s *Selection) *TextMap {
+	return newTextMap(s, true)
+}
+
+func newTextMap(s *Selection, runes bool) *TextMap {
+	m := &TextMap{sel: s, runes: runes}
+	m.reset()
+	return m
+}
+
+func (m *TextMap) reset() {
+	m.ix = newTextIndex(m.sel.Nodes, nil)
+	m.runeOffsets = nil
+	if m.runes && utf8.RuneCountInString(m.ix.text) != len(m.ix.text) {
+		m.runeOffsets = make([]int, 0, len(m.ix.text))
+		for i := range m.ix.text {
+			m.runeOffsets = append(m.runeOffsets, i)
+		}
+	}
+}
+
+// Text returns the text of the selection, as it was when the TextMap was
+// created.
+func (m *TextMap) Text() string {
+	return m.ix.text
+}
+
+// Len returns the length of the text, in the unit of the TextMap.
+func (m *TextMap) Len() int {
+	return m.fromByte(len(m.ix.text))
+}
+
+// Locate returns the parts of the text nodes that hold the text between
+// start and end, in document order. Offsets are clamped to the bounds of
+// the text. For an empty range, it returns the position of start in the text
+// node that holds it, if any.
+func (m *TextMap) Locate(start, end int) []TextSpan {
+	bstart, bend := m.byteRange(start, end)
+	first := m.ix.locate(bstart)
+	if first < 0 {
+		return nil
+	}
+	if bstart == bend {
+		n := m.ix.nodes[first]
+		local := m.localFromByte(n, min(bstart-m.ix.offsets[first], len(n.Data)))
+		return []TextSpan{{Node: n, Start: local, End: local}}
+	}
+
+	var spans []TextSpan
+	for i := first; i < len(m.ix.nodes) && m.ix.offsets[i] < bend; i++ {
+		n, off := m.ix.nodes[i], m.ix.offsets[i]
+		ls, le := max(bstart, off)-off, min(bend, off+len(n.Data))-off
+		if ls < le {
+			spans = append(spans, TextSpan{
+				Node:  n,
+				Start: m.localFromByte(n, ls),
+				End:   m.localFromByte(n, le),
+			})
+		}
+	}
+	return spans
+}
+
+// Offset returns the offset in the text of the character at offset local in
+// the text node n, or -1 if n is not part of the TextMap.
+func (m *TextMap) Offset(n *html.Node, local int) int {
+	for i, nn := range m.ix.nodes {
+		if nn == n {
+			return m.fromByte(m.ix.offsets[i] + m.localToByte(n, local))
+		}
+	}
+	return -1
+}
+
+// Select returns a new Selection object containing the text nodes that hold
+// the text between start and end.
+func (m *TextMap) Select(start, end int) *Selection {
+	spans := m.Locate(start, end)
+	nodes := make([]*html.Node, 0, len(spans))
+	for _, sp := range spans {
+		nodes = appendWithoutDuplicates(nodes, []*html.Node{sp.Node}, nil)
+	}
+	return pushStack(m.sel, nodes)
+}
+
+// Wrap wraps the text between start and end inside a clone of the first
+// element of the given HTML, following the same rules as Selection.WrapText.
+// It returns a new Selection object containing the created wrappers.
+//
+// The text nodes are split as needed, and the TextMap is updated to reflect
+// the new nodes: offsets in the text are unchanged, so the same TextMap can
+// be used to wrap multiple ranges.
+func (m *TextMap) Wrap(start, end int, wrapperHtml string) *Selection {
+	wrapper := getFirstElement(parseHtml(wrapperHtml))
+	bstart, bend := m.byteRange(start, end)
+	if wrapper == nil || bstart >= bend {
+		return pushStack(m.sel, nil)
+	}
+	wrappers := m.ix.wrap(m.sel.document, m.sel.Nodes, [][2]int{{bstart, bend}}, wrapper)
+	m.reset()
+	return pushStack(m.sel, wrappers)
+}
+
+// Highlight wraps the text between start and end inside mark elements. It is
+// a shortcut for Wrap(start, end, "<mark></mark>").
+func (m *TextMap) Highlight(start, end int) *Selection {
+	return m.Wrap(start, end, "<mark></mark>")
+}
+
+// byteRange converts the range to byte offsets, clamped to the bounds of the
+// text.
+func (m *TextMap) byteRange(start, end int) (int, int) {
+	bstart, bend := m.toByte(max(start, 0)), m.toByte(max(end, 0))
+	if bend < bstart {
+		bend = bstart
+	}
+	return bstart, bend
+}
+
+// toByte converts an offset in the unit of the TextMap to a byte offset.
+func (m *TextMap) toByte(off int) int {
+	if m.runeOffsets == nil {
+		return min(off, len(m.ix.text))
+	}
+	if off >= len(m.runeOffsets) {
+		return len(m.ix.text)
+	}
+	return m.runeOffsets[off]
+}
+
+// fromByte converts a byte offset to the unit of the TextMap.
+func (m *TextMap) fromByte(off int) int {
+	if m.runeOffsets == nil {
+		return off
+	}
+	return sort.SearchInts(m.runeOffsets, off)
+}
+
+// localFromByte converts a byte offset in the text node n to the unit of the
+// TextMap.
+func (m *TextMap) localFromByte(n *html.Node, off int) int {
+	if !m.runes {
+		return off
+	}
+	return utf8.RuneCountInString(n.Data[:off])
+}
+
+// localToByte converts an offset in the text node n in the unit of the
+// TextMap to a byte offset.
+func (m *TextMap) localToByte(n *html.Node, off int) int {
+	if !m.runes {
+		return min(max(off, 0), len(n.Data))
+	}
+	for i := range n.Data {
+		if off <= 0 {
+			return i
+		}
+		off--
+	}
+	return len(n.Data)
+}
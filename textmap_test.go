@@ -0,0 +1,92 @@
+package goquery
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTextMapLocate(t *testing.T) {
+	doc := loadString(t, `<p>Barack <b>Obama</b> visited <i>Paris</i>.</p>`)
+	p := doc.Find("p")
+	m := NewTextMap(p)
+
+	if m.Text() != p.Text() {
+		t.Fatalf("want text %q, got %q", p.Text(), m.Text())
+	}
+	start := strings.Index(m.Text(), "Barack Obama")
+	spans := m.Locate(start, start+len("Barack Obama"))
+	if len(spans) != 2 {
+		t.Fatalf("want 2 spans, got %d", len(spans))
+	}
+	if got := spans[0].Node.Data[spans[0].Start:spans[0].End]; got != "Barack " {
+		t.Errorf("want first span %q, got %q", "Barack ", got)
+	}
+	if got := spans[1].Node.Data[spans[1].Start:spans[1].End]; got != "Obama" {
+		t.Errorf("want second span %q, got %q", "Obama", got)
+	}
+
+	paris := doc.Find("i").Get(0).FirstChild
+	if off := m.Offset(paris, 2); off != strings.Index(m.Text(), "ris") {
+		t.Errorf("want offset %d, got %d", strings.Index(m.Text(), "ris"), off)
+	}
+	if off := m.Offset(p.Get(0), 0); off != -1 {
+		t.Errorf("want offset -1 for a non-text node, got %d", off)
+	}
+
+	if spans := m.Locate(3, 3); len(spans) != 1 || spans[0].Start != 3 || spans[0].End != 3 {
+		t.Errorf("unexpected spans for an empty range: %+v", spans)
+	}
+	if spans := m.Locate(100, 200); len(spans) != 1 || spans[0].Node.Data != "." {
+		t.Errorf("want out of bounds offsets to be clamped, got %+v", spans)
+	}
+}
+
+func TestTextMapRunes(t *testing.T) {
+	doc := loadString(t, `<p>Zoë <b>Čapek</b> lives in Zürich</p>`)
+	m := NewTextMapRunes(doc.Find("p"))
+	runes := []rune(m.Text())
+	if m.Len() != len(runes) {
+		t.Fatalf("want length %d, got %d", len(runes), m.Len())
+	}
+
+	start := strings.Index(string(runes), "Čapek")
+	start = len([]rune(string(runes)[:start]))
+	spans := m.Locate(start, start+5)
+	if len(spans) != 1 || spans[0].Start != 0 || spans[0].End != 5 {
+		t.Fatalf("unexpected spans %+v", spans)
+	}
+	if off := m.Offset(spans[0].Node, 2); off != start+2 {
+		t.Errorf("want offset %d, got %d", start+2, off)
+	}
+
+	sel := m.Highlight(len(runes)-6, len(runes))
+	if sel.Length() != 1 || sel.Text() != "Zürich" {
+		t.Errorf("want Zürich to be highlighted, got %q", sel.Text())
+	}
+}
+
+func TestTextMapWrap(t *testing.T) {
+	doc := loadString(t, `<p>Barack <b>Obama</b> visited <i>Paris</i>.</p>`)
+	p := doc.Find("p")
+	m := NewTextMap(p)
+	text := m.Text()
+
+	obama := strings.Index(text, "Barack Obama")
+	paris := strings.Index(text, "Paris")
+	sel := m.Wrap(obama, obama+len("Barack Obama"), `<span class="person">`)
+	sel = sel.AddSelection(m.Wrap(paris, paris+len("Paris"), `<span class="place">`))
+
+	want := `<p><span class="person">Barack <b>Obama</b></span> visited <span class="place"><i>Paris</i></span>.</p>`
+	if got, _ := OuterHtml(p); got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	assertLength(t, sel.Nodes, 2)
+	if m.Text() != text || p.Text() != text {
+		t.Errorf("want text to be unchanged")
+	}
+
+	sel = m.Select(obama+3, paris+1)
+	if sel.Length() != 4 || sel.Text() != "Barack Obama visited Paris" {
+		t.Errorf("unexpected selected text nodes %q", sel.Text())
+	}
+}
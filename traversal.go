@@ -19,7 +19,8 @@ const (
 
 // Find gets the descendants of each element in the current set of matched
 // elements, filtered by a selector. It returns a new Selection object
-// containing these matched elements.
+// containing these matched elements. The content of the templates is inert
+// and not searched, see TemplateContent.
 //
 // Note that as for all methods accepting a selector string, the selector is
 // compiled and applied by the cascadia package and inherits its behavior and
@@ -27,14 +28,14 @@ const (
 // the goquery documentation here:
 // https://github.com/PuerkitoBio/goquery?tab=readme-ov-file#api
 func (s *Selection) Find(selector string) *Selection {
-	return pushStack(s, findWithMatcher(s.Nodes, compileMatcher(selector)))
+	return pushStack(s, findWithMatcher(s.Nodes, compileMatcher(selector), s.composed()))
 }
 
 // FindMatcher gets the descendants of each element in the current set of matched
 // elements, filtered by the matcher. It returns a new Selection object
 // containing these matched elements.
 func (s *Selection) FindMatcher(m Matcher) *Selection {
-	return pushStack(s, findWithMatcher(s.Nodes, m))
+	return pushStack(s, findWithMatcher(s.Nodes, m, s.composed()))
 }
 
 // FindSelection gets the descendants of each element in the current
@@ -175,6 +176,29 @@ func (s *Selection) ClosestSelection(sel *Selection) *Selection {
 	return s.ClosestNodes(sel.Nodes...)
 }
 
+// CommonAncestor gets the deepest node that contains all the nodes in the
+// current Selection, which is the node itself if there is only one. It
+// returns a new Selection object with that node, or an empty one if the
+// Selection is empty or its nodes are not in the same tree.
+func (s *Selection) CommonAncestor() *Selection {
+	if len(s.Nodes) == 0 {
+		return pushStack(s, nil)
+	}
+	common := ancestorsFromRoot(s.Nodes[0])
+	for _, n := range s.Nodes[1:] {
+		path := ancestorsFromRoot(n)
+		k := 0
+		for k < len(common) && k < len(path) && common[k] == path[k] {
+			k++
+		}
+		if k == 0 {
+			return pushStack(s, nil)
+		}
+		common = common[:k]
+	}
+	return pushStack(s, common[len(common)-1:])
+}
+
 // Parents gets the ancestors of each element in the current Selection. It
 // returns a new Selection object with the matched elements.
 func (s *Selection) Parents() *Selection {
@@ -541,16 +565,10 @@ func filterAndPush(srcSel *Selection, nodes []*html.Node, m Matcher) *Selection
 }
 
 // Internal implementation of Find that return raw nodes.
-func findWithMatcher(nodes []*html.Node, m Matcher) []*html.Node {
-	// Map nodes to find the matches within the children of each node
-	return mapNodes(nodes, func(i int, n *html.Node) (result []*html.Node) {
-		// Go down one level, becausejQuery's Find selects only within descendants
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			if c.Type == html.ElementNode {
-				result = append(result, m.MatchAll(c)...)
-			}
-		}
-		return
+func findWithMatcher(nodes []*html.Node, m Matcher, composed bool) []*html.Node {
+	// Map nodes to find the matches within the descendants of each node
+	return mapNodes(nodes, func(i int, n *html.Node) []*html.Node {
+		return matchDescendants(n, m, composed)
 	})
 }
 
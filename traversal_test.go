@@ -718,6 +718,26 @@ func TestClosestMany(t *testing.T) {
 	assertSelectionIs(t, sel2, "#pc1", "#pc2")
 }
 
+func TestCommonAncestor(t *testing.T) {
+	doc := Doc2()
+	sel := doc.Find("#n3, #n5").CommonAncestor()
+	assertLength(t, sel.Nodes, 1)
+	assertSelectionIs(t, sel, "#main")
+
+	sel = doc.Find("#n3, #nf5").CommonAncestor()
+	assertLength(t, sel.Nodes, 1)
+	assertSelectionIs(t, sel, "body")
+
+	sel = doc.Find("#n3").CommonAncestor()
+	assertSelectionIs(t, sel, "#n3")
+}
+
+func TestCommonAncestorNone(t *testing.T) {
+	sel := Doc2().Find("#n3")
+	assertLength(t, sel.AddSelection(Doc().Find("body")).CommonAncestor().Nodes, 0)
+	assertLength(t, sel.Find("p").CommonAncestor().Nodes, 0)
+}
+
 func TestClosestRollback(t *testing.T) {
 	sel := Doc().Find(".container-fluid")
 	sel2 := sel.Closest(".pvk-content").End()
@@ -1,13 +1,17 @@
 package goquery
 
 import (
+	"context"
 	"errors"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
 
 	"github.com/andybalholm/cascadia"
 	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
 )
 
 // Document represents an HTML document to be manipulated. Unlike jQuery, which
@@ -19,6 +23,45 @@ type Document struct {
 	*Selection
 	Url      *url.URL
 	rootNode *html.Node
+
+	// DocumentOrder makes all the methods that return a new Selection of
+	// the document return its nodes in document order, without duplicates,
+	// as SortDocumentOrder does. By default, the nodes are in the order they
+	// are found, e.g. AddSelection appends the nodes of its argument to
+	// those of the Selection.
+	DocumentOrder bool
+
+	// Composed makes Find and the other methods that search the descendants
+	// of the nodes search the composed tree of the declarative shadow roots:
+	// the content of the shadow root of an element instead of its children,
+	// with the children assigned to each slot in place of the slot's content.
+	// The selectors are still matched against the nodes in the document
+	// tree. By default, the shadow roots are inert templates.
+	Composed bool
+
+	observers []*MutationObserver
+	muted     int
+
+	// source is set for the documents created by
+	// NewDocumentFromReaderWithSource, or with the Positions option.
+	source *documentSource
+
+	// xml is set for the documents created by NewXMLDocumentFromReader,
+	// which are rendered as XML.
+	xml bool
+
+	// options are the options the document was parsed with, and truncated
+	// is the limit that its source exceeded, with the Truncate option.
+	options   DocumentOptions
+	truncated *LimitError
+
+	// parent and frame are the document and the element that the document
+	// is embedded in, for the documents returned by ContentDocument, and
+	// embedded caches those documents by element, guarded by embeddedMu.
+	parent     *Document
+	frame      *html.Node
+	embeddedMu sync.Mutex
+	embedded   map[*html.Node]*embeddedDocument
 }
 
 // NewDocumentFromNode is a Document constructor that takes a root html Node
@@ -56,6 +99,190 @@ func NewDocumentFromReader(r io.Reader) (*Document, error) {
 	return newDocument(root, nil), nil
 }
 
+// NewDocumentFromReaderWithSource returns a Document from an io.Reader, like
+// NewDocumentFromReader, but it also keeps the source of the document and
+// records where each node comes from in it. The document can then be
+// rendered with the PreserveSource option of RenderWithOptions, which writes
+// the unchanged parts of the document exactly as they are in the source and
+// only serializes the nodes that were changed.
+func NewDocumentFromReaderWithSource(r io.Reader) (*Document, error) {
+	return NewDocumentFromReaderWithOptions(r, DocumentOptions{Positions: true})
+}
+
+// DocumentOptions configures the parsing of a document by
+// NewDocumentFromReaderWithOptions. The zero value parses the document the
+// same way as NewDocumentFromReader.
+type DocumentOptions struct {
+	// Positions records the position in the source of each node of the
+	// document, as returned by Selection.Position. It keeps the source of
+	// the document, so the PreserveSource option of RenderWithOptions can
+	// also be used.
+	Positions bool
+
+	// DocumentOrder sets the DocumentOrder field of the document.
+	DocumentOrder bool
+
+	// Composed sets the Composed field of the document.
+	Composed bool
+
+	// DisableScripting parses the document as a browser does with scripting
+	// disabled: the content of the noscript elements is parsed as markup,
+	// instead of being kept as text.
+	DisableScripting bool
+
+	// Context parses the input as an HTML fragment in the context of an
+	// element of that tag name, e.g. "tr" or "textarea", as the content of
+	// the element would be parsed, instead of as a full document. The
+	// parsed nodes are the children of the root node of the document. The
+	// "svg" and "math" contexts are the SVG and MathML elements, whose
+	// content is parsed in their namespace.
+	Context string
+
+	// MaxBytes is the maximum number of bytes read from the input, if
+	// positive. If the input is longer, a *LimitError is returned.
+	MaxBytes int64
+
+	// The following limits, if positive, are checked on the tokens of the
+	// source before it is parsed, which requires the whole input to be read
+	// first: the input is then limited to DefaultMaxBytes if MaxBytes is not
+	// set. If the source exceeds one of them, a *LimitError is returned.
+	//
+	// MaxNodes is the maximum number of tags, texts, comments and doctypes
+	// of the source. It is also checked on the nodes of the parsed
+	// document, not counting the html, head and body elements, as the
+	// parser may create more nodes than there are tags, e.g. when it reopens
+	// the formatting elements closed by the end of a paragraph; the nodes
+	// over the limit, in document order, are then removed with Truncate.
+	//
+	// MaxDepth is the maximum nesting of the elements, not counting the
+	// html, head and body elements, as opened and closed by their tags in
+	// the source, where an element whose end tag may be omitted, such as p
+	// or li, is also closed by a start tag of the same name. It is also checked on the parsed document, where the parser may
+	// nest the elements deeper, e.g. when it reopens the formatting elements
+	// closed by the end of a paragraph; the elements that are nested too
+	// deep are then removed with Truncate. MaxAttributes is the maximum
+	// number of attributes of a tag, and MaxTextLength the maximum length in
+	// bytes of a text or a comment in the source.
+	MaxNodes      int64
+	MaxDepth      int64
+	MaxAttributes int64
+	MaxTextLength int64
+
+	// Truncate keeps the document parsed from the part of the source before
+	// the limit that it exceeds, instead of returning an error: the bytes
+	// up to MaxBytes, or the tokens before the one that exceeds one of the
+	// other limits. The exceeded limit is returned by Document.Truncated.
+	Truncate bool
+}
+
+// NewDocumentFromReaderWithOptions returns a Document from an io.Reader,
+// like NewDocumentFromReader, configured by opts.
+func NewDocumentFromReaderWithOptions(r io.Reader, opts DocumentOptions) (*Document, error) {
+	return newDocumentFromReader(context.Background(), r, opts)
+}
+
+// newDocumentFromReader returns a Document from r, configured by opts, and
+// stops reading r when ctx is done.
+func newDocumentFromReader(ctx context.Context, r io.Reader, opts DocumentOptions) (*Document, error) {
+	r = withContext(ctx, r)
+	var limited *limitReader
+	maxBytes := opts.MaxBytes
+	if maxBytes <= 0 && opts.hasTokenLimits() {
+		maxBytes = DefaultMaxBytes
+	}
+	if maxBytes > 0 {
+		limited = &limitReader{r: r, n: maxBytes, err: &LimitError{LimitBytes, maxBytes, maxBytes}}
+		if opts.Truncate {
+			limited.err = io.EOF
+		}
+		r = limited
+	}
+	var src string
+	var truncated *LimitError
+	if opts.Positions || opts.hasTokenLimits() {
+		var sb strings.Builder
+		if _, e := io.Copy(&sb, r); e != nil {
+			return nil, e
+		}
+		src = sb.String()
+		if e := checkLimits(src, opts); e != nil {
+			if !opts.Truncate {
+				return nil, e
+			}
+			src, truncated = src[:e.Offset], e
+		}
+		r = withContext(ctx, strings.NewReader(src))
+	}
+	root, e := parseDocument(r, opts)
+	if e != nil {
+		return nil, e
+	}
+	if opts.MaxDepth > 0 {
+		if n := checkDepth(root, opts.MaxDepth); n != nil {
+			e := &LimitError{LimitDepth, opts.MaxDepth, nodeOffset(src, root, n, opts)}
+			if !opts.Truncate {
+				return nil, e
+			}
+			pruneDepth(root, 0, opts.MaxDepth)
+			if truncated == nil {
+				truncated = e
+			}
+		}
+	}
+	if opts.MaxNodes > 0 {
+		if n := checkNodes(root, opts.MaxNodes); n != nil {
+			e := &LimitError{LimitNodes, opts.MaxNodes, nodeOffset(src, root, n, opts)}
+			if !opts.Truncate {
+				return nil, e
+			}
+			pruneFrom(n)
+			if truncated == nil {
+				truncated = e
+			}
+		}
+	}
+	if truncated == nil && limited != nil && limited.exceeded {
+		truncated = &LimitError{LimitBytes, maxBytes, maxBytes}
+	}
+
+	d := newDocument(root, nil)
+	d.truncated = truncated
+	d.options = opts
+	d.DocumentOrder = opts.DocumentOrder
+	d.Composed = opts.Composed
+	if opts.Positions {
+		if e := ctx.Err(); e != nil {
+			return nil, e
+		}
+		d.source = newDocumentSource(src, root, opts.Context, !opts.DisableScripting)
+	}
+	return d, nil
+}
+
+// parseDocument parses the document or, with the Context option, the fragment
+// read from r, and returns its root node.
+func parseDocument(r io.Reader, opts DocumentOptions) (*html.Node, error) {
+	scripting := html.ParseOptionEnableScripting(!opts.DisableScripting)
+	if opts.Context == "" {
+		return html.ParseWithOptions(r, scripting)
+	}
+
+	tag := strings.ToLower(opts.Context)
+	context := &html.Node{Type: html.ElementNode, Data: tag, DataAtom: atom.Lookup([]byte(tag))}
+	if tag == "svg" || tag == "math" {
+		context.Namespace = tag
+	}
+	nodes, e := html.ParseFragmentWithOptions(r, context, scripting)
+	if e != nil {
+		return nil, e
+	}
+	root := &html.Node{Type: html.DocumentNode}
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+	return root, nil
+}
+
 // NewDocumentFromResponse is another Document constructor that takes an http response as argument.
 // It loads the specified response's document, parses it, and stores the root Document
 // node, ready to be manipulated. The response's body is closed on return.
@@ -82,13 +309,18 @@ func NewDocumentFromResponse(res *http.Response) (*Document, error) {
 
 // CloneDocument creates a deep-clone of a document.
 func CloneDocument(doc *Document) *Document {
-	return newDocument(cloneNode(doc.rootNode), doc.Url)
+	d := newDocument(cloneNode(doc.rootNode), doc.Url)
+	d.options, d.truncated = doc.options, doc.truncated
+	d.xml = doc.xml
+	d.DocumentOrder = doc.DocumentOrder
+	d.Composed = doc.Composed
+	return d
 }
 
 // Private constructor, make sure all fields are correctly filled.
 func newDocument(root *html.Node, url *url.URL) *Document {
 	// Create and fill the document
-	d := &Document{nil, url, root}
+	d := &Document{Url: url, rootNode: root}
 	d.Selection = newSingleSelection(root, d)
 	return d
 }
@@ -164,6 +396,9 @@ func SingleMatcher(m Matcher) Matcher {
 // the corresponding Matcher. If s is an invalid selector string,
 // it returns a Matcher that fails all matches.
 func compileMatcher(s string) Matcher {
+	if m := compileNameSelector(s); m != nil {
+		return m
+	}
 	cs, err := cascadia.Compile(s)
 	if err != nil {
 		return invalidMatcher{}
@@ -2,6 +2,7 @@ package goquery
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -253,3 +254,204 @@ func TestSingle(t *testing.T) {
 		t.Fatalf("want %q, got %q", "142", text)
 	}
 }
+
+func TestNewDocumentFromReaderWithOptionsContext(t *testing.T) {
+	doc, err := NewDocumentFromReaderWithOptions(strings.NewReader(`<td>a</td><td>b</td>`), DocumentOptions{Context: "TR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLength(t, doc.Find("html, body, table").Nodes, 0)
+	assertLength(t, doc.Children().Filter("td").Nodes, 2)
+
+	// the fragment is parsed as the content of a textarea
+	doc, err = NewDocumentFromReaderWithOptions(strings.NewReader(`<b>a</b>`), DocumentOptions{Context: "textarea", Positions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertLength(t, doc.Find("b").Nodes, 0)
+	text := doc.Contents()
+	assertLength(t, text.Nodes, 1)
+	if text.Nodes[0].Type != html.TextNode || text.Nodes[0].Data != "<b>a</b>" {
+		t.Errorf("want the text node %q, got %v", "<b>a</b>", text.Nodes[0])
+	}
+	if pos := text.Position(); pos.Offset != 0 || pos.EndOffset != 8 {
+		t.Errorf("want the text at 0-8, got %+v", pos)
+	}
+}
+
+func TestNewDocumentFromReaderWithOptionsMaxBytes(t *testing.T) {
+	src := `<p>hello</p>`
+	for _, positions := range []bool{false, true} {
+		_, err := NewDocumentFromReaderWithOptions(strings.NewReader(src), DocumentOptions{MaxBytes: int64(len(src)) - 1, Positions: positions})
+		var lerr *LimitError
+		if !errors.As(err, &lerr) {
+			t.Fatalf("want a LimitError, got %v", err)
+		}
+		if lerr.Limit != LimitBytes || lerr.Max != int64(len(src))-1 {
+			t.Errorf("unexpected error %+v", lerr)
+		}
+		if got, want := err.Error(), "goquery: document exceeds the MaxBytes limit of 11 at offset 11"; got != want {
+			t.Errorf("want %q, got %q", want, got)
+		}
+
+		doc, err := NewDocumentFromReaderWithOptions(strings.NewReader(src), DocumentOptions{MaxBytes: int64(len(src)), Positions: positions})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := doc.Find("p").Text(); got != "hello" {
+			t.Errorf("want %q, got %q", "hello", got)
+		}
+	}
+}
+
+func TestNewDocumentFromReaderWithOptionsLimits(t *testing.T) {
+	src := `<ul><li a="1" b="2">one<li>two<li><!--three--></ul><div><div><div></div></div></div>`
+	cases := []struct {
+		opts   DocumentOptions
+		limit  Limit
+		offset int64
+	}{
+		{DocumentOptions{MaxNodes: 7}, LimitNodes, 51},
+		{DocumentOptions{MaxNodes: 14}, 0, 0},
+		// the li are closed by the next li
+		{DocumentOptions{MaxDepth: 2}, LimitDepth, 61},
+		{DocumentOptions{MaxDepth: 3}, 0, 0},
+		{DocumentOptions{MaxAttributes: 1}, LimitAttributes, 4},
+		{DocumentOptions{MaxTextLength: 5}, LimitTextLength, 34},
+		{DocumentOptions{MaxTextLength: 12}, 0, 0},
+	}
+	for i, c := range cases {
+		doc, err := NewDocumentFromReaderWithOptions(strings.NewReader(src), c.opts)
+		if c.limit == 0 {
+			if err != nil {
+				t.Errorf("%d: want no error, got %v", i, err)
+			}
+			continue
+		}
+		var lerr *LimitError
+		if !errors.As(err, &lerr) {
+			t.Errorf("%d: want a LimitError, got %v", i, err)
+			continue
+		}
+		if lerr.Limit != c.limit || lerr.Offset != c.offset {
+			t.Errorf("%d: want %s at %d, got %s at %d", i, c.limit, c.offset, lerr.Limit, lerr.Offset)
+		}
+
+		c.opts.Truncate = true
+		doc, err = NewDocumentFromReaderWithOptions(strings.NewReader(src), c.opts)
+		if err != nil {
+			t.Fatalf("%d: want no error, got %v", i, err)
+		}
+		if !errors.As(doc.Truncated(), &lerr) || lerr.Limit != c.limit {
+			t.Errorf("%d: want the document truncated by %s, got %v", i, c.limit, doc.Truncated())
+		}
+		want, _ := NewDocumentFromReader(strings.NewReader(src[:c.offset]))
+		got, _ := doc.Html()
+		if h, _ := want.Html(); got != h {
+			t.Errorf("%d: want %q, got %q", i, h, got)
+		}
+	}
+}
+
+func TestNewDocumentFromReaderWithOptionsTruncateBytes(t *testing.T) {
+	doc, err := NewDocumentFromReaderWithOptions(strings.NewReader(`<p>hello</p><p>world</p>`), DocumentOptions{MaxBytes: 17, Truncate: true, Positions: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := doc.Find("p").Text(); got != "hellowo" {
+		t.Errorf("want %q, got %q", "hellowo", got)
+	}
+	var lerr *LimitError
+	if !errors.As(doc.Truncated(), &lerr) || lerr.Limit != LimitBytes || lerr.Offset != 17 {
+		t.Errorf("want the document truncated by MaxBytes, got %v", doc.Truncated())
+	}
+
+	doc, err = NewDocumentFromReaderWithOptions(strings.NewReader(`<p>hello</p>`), DocumentOptions{MaxBytes: 12, Truncate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Truncated() != nil {
+		t.Errorf("want the document not truncated, got %v", doc.Truncated())
+	}
+}
+
+func TestNewDocumentFromReaderWithOptionsDepthInTree(t *testing.T) {
+	// the end tags of body and html are ignored by the parser
+	src := "<body>" + strings.Repeat("<div>", 50) + "</body>" + strings.Repeat("<div>", 50)
+	_, err := NewDocumentFromReaderWithOptions(strings.NewReader(src), DocumentOptions{MaxDepth: 60})
+	var lerr *LimitError
+	if !errors.As(err, &lerr) || lerr.Limit != LimitDepth {
+		t.Errorf("want a MaxDepth error, got %v", err)
+	}
+
+	// the formatting elements closed by the end of the p are reopened
+	var sb strings.Builder
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&sb, `<p><b id="%d"></p>`, i)
+	}
+	sb.WriteString("x")
+	src = sb.String()
+	_, err = NewDocumentFromReaderWithOptions(strings.NewReader(src), DocumentOptions{MaxDepth: 10})
+	if !errors.As(err, &lerr) || lerr.Limit != LimitDepth || lerr.Offset != int64(len(src)-1) {
+		t.Errorf("want a MaxDepth error at %d, got %v", len(src)-1, err)
+	}
+	doc, err := NewDocumentFromReaderWithOptions(strings.NewReader(src), DocumentOptions{MaxDepth: 10, Truncate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.As(doc.Truncated(), &lerr) || lerr.Limit != LimitDepth {
+		t.Errorf("want the document truncated by MaxDepth, got %v", doc.Truncated())
+	}
+	if n := checkDepth(doc.rootNode, 10); n != nil {
+		t.Errorf("want the elements deeper than 10 removed, found %v", n)
+	}
+}
+
+func TestNewDocumentFromReaderWithOptionsNodesInTree(t *testing.T) {
+	// the b are reopened in each p, which creates 90,300 elements
+	var sb strings.Builder
+	sb.WriteString("<p>")
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&sb, "<b id=%d>", i)
+	}
+	sb.WriteString(strings.Repeat("<p>x", 300))
+	src := sb.String()
+
+	_, err := NewDocumentFromReaderWithOptions(strings.NewReader(src), DocumentOptions{MaxNodes: 2000})
+	var lerr *LimitError
+	if !errors.As(err, &lerr) || lerr.Limit != LimitNodes {
+		t.Errorf("want a MaxNodes error, got %v", err)
+	}
+	doc, err := NewDocumentFromReaderWithOptions(strings.NewReader(src), DocumentOptions{MaxNodes: 2000, Truncate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.As(doc.Truncated(), &lerr) || lerr.Limit != LimitNodes {
+		t.Errorf("want the document truncated by MaxNodes, got %v", doc.Truncated())
+	}
+	if n := checkNodes(doc.rootNode, 2000); n != nil {
+		t.Errorf("want the nodes over 2000 removed, found %v", n)
+	}
+	if got := doc.Find("b").Length(); got == 0 || got > 2000 {
+		t.Errorf("want at most 2000 b, got %d", got)
+	}
+}
+
+// endlessReader returns an endless sequence of the same byte.
+type endlessReader byte
+
+func (r endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(r)
+	}
+	return len(p), nil
+}
+
+func TestNewDocumentFromReaderWithOptionsDefaultMaxBytes(t *testing.T) {
+	// the input is read before its tokens are checked, up to DefaultMaxBytes
+	_, err := NewDocumentFromReaderWithOptions(endlessReader(' '), DocumentOptions{MaxNodes: 10})
+	var lerr *LimitError
+	if !errors.As(err, &lerr) || lerr.Limit != LimitBytes || lerr.Max != DefaultMaxBytes {
+		t.Errorf("want a MaxBytes error of %d, got %v", DefaultMaxBytes, err)
+	}
+}
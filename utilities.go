@@ -59,12 +59,16 @@ func nodeName(node *html.Node) string {
 
 // Render renders the HTML of the first item in the selection and writes it to
 // the writer. It behaves the same as OuterHtml but writes to w instead of
-// returning the string.
+// returning the string. The nodes of the documents created by
+// NewXMLDocumentFromReader are rendered as XML.
 func Render(w io.Writer, s *Selection) error {
 	if s.Length() == 0 {
 		return nil
 	}
 	n := s.Get(0)
+	if s.isXML() {
+		return renderXML(w, n)
+	}
 	return html.Render(w, n)
 }
 
@@ -170,8 +174,12 @@ func grep(sel *Selection, predicate func(i int, s *Selection) bool) (result []*h
 }
 
 // Creates a new Selection object based on the specified nodes, and keeps the
-// source Selection object on the stack (linked list).
+// source Selection object on the stack (linked list). The nodes are sorted if
+// the document has the DocumentOrder option.
 func pushStack(fromSel *Selection, nodes []*html.Node) *Selection {
+	if fromSel.document != nil && fromSel.document.DocumentOrder {
+		nodes = sortDocumentOrder(nodes)
+	}
 	result := &Selection{nodes, fromSel.document, fromSel}
 	return result
 }
@@ -0,0 +1,359 @@
+package goquery
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// The namespaces that have a short name in the Namespace field of the nodes,
+// as set by the HTML parser for the foreign elements and attributes.
+const (
+	xhtmlNamespace  = "http://www.w3.org/1999/xhtml"
+	svgNamespace    = "http://www.w3.org/2000/svg"
+	mathNamespace   = "http://www.w3.org/1998/Math/MathML"
+	xlinkNamespace  = "http://www.w3.org/1999/xlink"
+	xmlNamespaceURL = "http://www.w3.org/XML/1998/namespace"
+	xmlnsNamespace  = "http://www.w3.org/2000/xmlns/"
+)
+
+// namespaceNames maps the namespace URLs to their short names.
+var namespaceNames = map[string]string{
+	svgNamespace:    "svg",
+	mathNamespace:   "math",
+	xlinkNamespace:  "xlink",
+	xmlNamespaceURL: "xml",
+	xmlnsNamespace:  "xmlns",
+}
+
+// namespaceURL returns the URL of the namespace of a node, which is either
+// a short name or a URL.
+func namespaceURL(ns string) string {
+	for url, name := range namespaceNames {
+		if name == ns {
+			return url
+		}
+	}
+	return ns
+}
+
+// NewXMLDocumentFromReader returns a Document from an io.Reader that holds an
+// XML document, such as an RSS or Atom feed, a sitemap, an SVG image or an
+// XHTML document. The input must be encoded in UTF-8, and may use the HTML
+// entities. It returns an error if the document is not well-formed.
+//
+// The document is built as written, with the same *html.Node tree as the
+// HTML documents: the case of the names is kept, the elements are not moved,
+// and the DataAtom of the elements is the atom of their local name. The
+// Namespace of the elements and attributes is the URL of their
+// namespace, except for the namespaces that the HTML parser uses: the XHTML
+// elements have an empty Namespace, as the HTML elements do, and the SVG and
+// MathML elements, the xlink, xml and xmlns attributes have the same short
+// Namespace as in HTML, e.g. "svg". The namespace declarations are kept as
+// attributes, the processing instructions and directives, such as the XML
+// declaration, as raw nodes.
+//
+// The document is rendered as XML by Render, OuterHtml, Html and
+// RenderWithOptions, whose options are then ignored.
+//
+// The HTML given to the manipulation methods, such as AppendHtml or SetHtml,
+// is parsed as XML, with the namespaces declared in scope of the element it
+// is inserted in. A fragment that is not well-formed is not inserted, and the
+// *HtmlWithOptions methods return an *HtmlParseError.
+func NewXMLDocumentFromReader(r io.Reader) (*Document, error) {
+	root := &html.Node{Type: html.DocumentNode}
+	if err := parseXML(newXMLDecoder(r), root); err != nil {
+		return nil, err
+	}
+
+	d := newDocument(root, nil)
+	d.xml = true
+	return d, nil
+}
+
+func newXMLDecoder(r io.Reader) *xml.Decoder {
+	dec := xml.NewDecoder(r)
+	dec.Entity = xml.HTMLEntity
+	return dec
+}
+
+// parseXML appends the nodes decoded by dec to parent.
+func parseXML(dec *xml.Decoder, parent *html.Node) error {
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &html.Node{Type: html.ElementNode, Data: t.Name.Local, DataAtom: atom.Lookup([]byte(t.Name.Local))}
+			n.Namespace = shortNamespace(t.Name.Space)
+			for _, a := range t.Attr {
+				n.Attr = append(n.Attr, html.Attribute{Namespace: shortNamespace(a.Name.Space), Key: a.Name.Local, Val: a.Value})
+			}
+			parent.AppendChild(n)
+			parent = n
+		case xml.EndElement:
+			parent = parent.Parent
+		case xml.CharData:
+			if last := parent.LastChild; last != nil && last.Type == html.TextNode {
+				last.Data += string(t)
+			} else {
+				parent.AppendChild(&html.Node{Type: html.TextNode, Data: string(t)})
+			}
+		case xml.Comment:
+			parent.AppendChild(&html.Node{Type: html.CommentNode, Data: string(t)})
+		case xml.ProcInst:
+			data := "<?" + t.Target
+			if len(t.Inst) > 0 {
+				data += " " + string(t.Inst)
+			}
+			parent.AppendChild(&html.Node{Type: html.RawNode, Data: data + "?>"})
+		case xml.Directive:
+			parent.AppendChild(&html.Node{Type: html.RawNode, Data: "<!" + string(t) + ">"})
+		}
+	}
+}
+
+// xmlFragmentRoot is the name of the element that holds the nodes of an XML
+// fragment while it is parsed.
+const xmlFragmentRoot = "goquery-fragment"
+
+// parseXMLFragment parses the XML fragment h in the context of the element
+// (or document) context: the prefixes and the default namespace declared in
+// scope at context are those of the fragment. It returns the offset in h of
+// the error if the fragment is not well-formed.
+func parseXMLFragment(h string, context *html.Node) ([]*html.Node, int, error) {
+	var sb strings.Builder
+	w := bufio.NewWriter(&sb)
+	w.WriteString("<" + xmlFragmentRoot)
+	seen := make(map[string]bool)
+	for p := context; p != nil && p.Type == html.ElementNode; p = p.Parent {
+		for _, a := range p.Attr {
+			key := a.Key
+			switch {
+			case a.Namespace == "xmlns":
+				key = "xmlns:" + key
+			case a.Namespace == "" && a.Key == "xmlns":
+			default:
+				continue
+			}
+			if !seen[key] {
+				seen[key] = true
+				writeXMLAttr(w, key, a.Val)
+			}
+		}
+	}
+	w.WriteByte('>')
+	w.Flush()
+	prefix := sb.Len()
+
+	root := &html.Node{Type: html.DocumentNode}
+	dec := newXMLDecoder(strings.NewReader(sb.String() + h + "</" + xmlFragmentRoot + ">"))
+	if err := parseXML(dec, root); err != nil {
+		return nil, max(int(dec.InputOffset())-prefix, 0), err
+	}
+	if root.FirstChild == nil || root.FirstChild != root.LastChild {
+		// e.g. the fragment closed the element that holds it
+		return nil, len(h), errors.New("unexpected end tag")
+	}
+	return detachChildren(root.FirstChild), 0, nil
+}
+
+// shortNamespace returns the Namespace of the nodes in the namespace ns,
+// which is either a short name or a URL: the short name of the namespaces
+// that have one, an empty Namespace for XHTML, or the URL.
+func shortNamespace(ns string) string {
+	if ns == xhtmlNamespace {
+		return ""
+	}
+	if name, ok := namespaceNames[ns]; ok {
+		return name
+	}
+	return ns
+}
+
+// isXML returns true if the nodes of the Selection are rendered as XML.
+func (s *Selection) isXML() bool {
+	return s.document != nil && s.document.xml
+}
+
+// renderXML renders n and its descendants as XML.
+func renderXML(w io.Writer, n *html.Node) error {
+	bw := bufio.NewWriter(w)
+	writeXML(bw, n)
+	return bw.Flush()
+}
+
+// writeXML writes n as XML. Write errors are kept by the bufio.Writer.
+func writeXML(w *bufio.Writer, n *html.Node) {
+	switch n.Type {
+	case html.DocumentNode:
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			writeXML(w, c)
+		}
+	case html.TextNode:
+		xmlEscape(w, n.Data, false)
+	case html.CommentNode:
+		w.WriteString("<!--")
+		w.WriteString(n.Data)
+		w.WriteString("-->")
+	case html.DoctypeNode:
+		w.WriteString("<!DOCTYPE ")
+		w.WriteString(n.Data)
+		w.WriteByte('>')
+	case html.RawNode:
+		w.WriteString(n.Data)
+	case html.ElementNode:
+		writeXMLElement(w, n)
+	}
+}
+
+func writeXMLElement(w *bufio.Writer, n *html.Node) {
+	// the namespaces that are not declared in scope are declared on the
+	// element
+	var decls []html.Attribute
+	name := n.Data
+	if n.Namespace != "" {
+		url := namespaceURL(n.Namespace)
+		if prefix, ok := xmlPrefix(n, url, true); !ok {
+			decls = append(decls, html.Attribute{Key: "xmlns", Val: url})
+		} else if prefix != "" {
+			name = prefix + ":" + name
+		}
+	}
+
+	w.WriteByte('<')
+	w.WriteString(name)
+	for _, a := range n.Attr {
+		key := a.Key
+		switch a.Namespace {
+		case "":
+		case "xmlns", "xml":
+			key = a.Namespace + ":" + key
+		default:
+			url := namespaceURL(a.Namespace)
+			prefix, ok := xmlPrefix(n, url, false)
+			if !ok {
+				prefix = a.Namespace
+				if url == a.Namespace {
+					prefix = fmt.Sprintf("ns%d", len(decls))
+				}
+				decls = append(decls, html.Attribute{Namespace: "xmlns", Key: prefix, Val: url})
+			}
+			key = prefix + ":" + key
+		}
+		writeXMLAttr(w, key, a.Val)
+	}
+	for _, a := range decls {
+		key := a.Key
+		if a.Namespace != "" {
+			key = a.Namespace + ":" + key
+		}
+		writeXMLAttr(w, key, a.Val)
+	}
+
+	if n.FirstChild == nil {
+		w.WriteString("/>")
+		return
+	}
+	w.WriteByte('>')
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		writeXML(w, c)
+	}
+	w.WriteString("</")
+	w.WriteString(name)
+	w.WriteByte('>')
+}
+
+func writeXMLAttr(w *bufio.Writer, key, val string) {
+	w.WriteByte(' ')
+	w.WriteString(key)
+	w.WriteString(`="`)
+	xmlEscape(w, val, true)
+	w.WriteByte('"')
+}
+
+// xmlPrefix returns the prefix of the namespace of that URL, from the
+// namespace declarations in scope at n, or false if it is not declared. The
+// default namespace, with an empty prefix, is only used for the elements.
+func xmlPrefix(n *html.Node, url string, element bool) (string, bool) {
+	seen := make(map[string]bool)
+	for p := n; p != nil && p.Type == html.ElementNode; p = p.Parent {
+		for _, a := range p.Attr {
+			var prefix string
+			switch {
+			case a.Namespace == "xmlns":
+				prefix = a.Key
+			case a.Namespace == "" && a.Key == "xmlns":
+				if !element {
+					continue
+				}
+			default:
+				continue
+			}
+			if seen[prefix] {
+				continue
+			}
+			seen[prefix] = true
+			if a.Val == url {
+				return prefix, true
+			}
+		}
+	}
+	return "", false
+}
+
+// lookupNamespace returns the URL of the namespace of the prefix, from the
+// namespace declarations in scope at n, or false if it is not declared.
+func lookupNamespace(n *html.Node, prefix string) (string, bool) {
+	for p := n; p != nil && p.Type == html.ElementNode; p = p.Parent {
+		for _, a := range p.Attr {
+			if a.Namespace == "xmlns" && a.Key == prefix {
+				return a.Val, true
+			}
+		}
+	}
+	return "", false
+}
+
+// xmlEscape writes s with the markup characters escaped, and the whitespace
+// characters that are normalized by the XML parsers: the carriage returns,
+// and the tabs and line feeds of the attribute values, if attr is true.
+func xmlEscape(w *bufio.Writer, s string, attr bool) {
+	special := "&<>\r"
+	if attr {
+		special = "&<>\"\t\n\r"
+	}
+	for {
+		i := strings.IndexAny(s, special)
+		if i < 0 {
+			w.WriteString(s)
+			return
+		}
+		w.WriteString(s[:i])
+		switch c := s[i]; c {
+		case '&':
+			w.WriteString("&amp;")
+		case '<':
+			w.WriteString("&lt;")
+		case '>':
+			w.WriteString("&gt;")
+		case '"':
+			w.WriteString("&#34;")
+		default:
+			fmt.Fprintf(w, "&#%d;", c)
+		}
+		s = s[i+1:]
+	}
+}
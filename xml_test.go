@@ -0,0 +1,170 @@
+package goquery
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const rssFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:atom="http://www.w3.org/2005/Atom" xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+<title>Feed &amp; news</title>
+<atom:link href="https://example.com/feed" rel="self"/>
+<item><title>One</title><pubDate>Mon, 19 Oct 2026</pubDate><guid isPermaLink="false">1</guid><dc:creator>A</dc:creator></item>
+<item><title><![CDATA[Two <b>bold</b>]]></title><pubDate>Tue, 20 Oct 2026</pubDate></item>
+</channel>
+</rss>`
+
+func loadXMLString(t *testing.T, src string) *Document {
+	doc, err := NewXMLDocumentFromReader(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestNewXMLDocumentFromReader(t *testing.T) {
+	doc := loadXMLString(t, rssFeed)
+
+	assertLength(t, doc.Find("item").Nodes, 2)
+	if got := doc.Find("channel > title").Text(); got != "Feed & news" {
+		t.Errorf("want %q, got %q", "Feed & news", got)
+	}
+	if got := doc.Find("item title").Eq(1).Text(); got != "Two <b>bold</b>" {
+		t.Errorf("want the CDATA text, got %q", got)
+	}
+	if got := doc.Find("pubDate").First().Text(); got != "Mon, 19 Oct 2026" {
+		t.Errorf("want %q, got %q", "Mon, 19 Oct 2026", got)
+	}
+	if got := NodeName(doc.Find("item > pubDate")); got != "pubDate" {
+		t.Errorf("want the case kept, got %q", got)
+	}
+	if v, ok := doc.Find("guid").Attr("isPermaLink"); !ok || v != "false" {
+		t.Errorf("want isPermaLink=false, got %q %v", v, ok)
+	}
+	assertLength(t, doc.Find("[isPermaLink=false]").Nodes, 1)
+
+	link := doc.Find("link")
+	assertLength(t, link.Nodes, 1)
+	if ns := link.Nodes[0].Namespace; ns != "http://www.w3.org/2005/Atom" {
+		t.Errorf("want the Atom namespace, got %q", ns)
+	}
+	if ns := doc.Find("creator").Nodes[0].Namespace; ns != "http://purl.org/dc/elements/1.1/" {
+		t.Errorf("want the Dublin Core namespace, got %q", ns)
+	}
+}
+
+func TestNewXMLDocumentFromReaderError(t *testing.T) {
+	for _, src := range []string{`<a><b></a>`, `<a>`, `<a x=1/>`} {
+		if _, err := NewXMLDocumentFromReader(strings.NewReader(src)); err == nil {
+			t.Errorf("%s: want an error", src)
+		}
+	}
+}
+
+func TestXMLRender(t *testing.T) {
+	doc := loadXMLString(t, rssFeed)
+	got, err := OuterHtml(doc.Find("atom\\:link, link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<atom:link href="https://example.com/feed" rel="self"/>`; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	got, err = doc.Find("item").Eq(1).Html()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<title>Two &lt;b&gt;bold&lt;/b&gt;</title><pubDate>Tue, 20 Oct 2026</pubDate>`; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+
+	// the rendered document parses back to the same document
+	h, err := OuterHtml(doc.Selection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc2 := loadXMLString(t, h)
+	h2, _ := OuterHtml(doc2.Selection)
+	if h != h2 {
+		t.Errorf("want the same rendering, got\n%s\n%s", h, h2)
+	}
+	if !strings.HasPrefix(h, `<?xml version="1.0" encoding="UTF-8"?>`) {
+		t.Errorf("want the XML declaration, got %q", h)
+	}
+}
+
+func TestXMLRenderNamespaces(t *testing.T) {
+	src := `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 10 10">` +
+		`<linearGradient id="g"/><use xlink:href="#g"/></svg>`
+	doc := loadXMLString(t, src)
+	svg := doc.Find("svg")
+	if svg.Nodes[0].Namespace != "svg" {
+		t.Errorf("want the svg namespace, got %q", svg.Nodes[0].Namespace)
+	}
+	assertLength(t, doc.Find("svg > linearGradient").Nodes, 1)
+	assertLength(t, doc.Find("[viewBox]").Nodes, 1)
+
+	got, _ := OuterHtml(svg)
+	if got != src {
+		t.Errorf("want %q, got %q", src, got)
+	}
+
+	// new nodes are rendered with their namespace declared
+	doc.Find("use").Nodes[0].Attr[0].Namespace = "xlink"
+	svg.Nodes[0].Attr = svg.Nodes[0].Attr[2:]
+	got, _ = OuterHtml(doc.Find("use"))
+	if want := `<use xlink:href="#g" xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"/>`; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestXMLRenderWithOptions(t *testing.T) {
+	doc := loadXMLString(t, `<a><b x="1&#10;2"/></a>`)
+	got, err := OuterHtmlWithOptions(doc.Find("a"), RenderOptions{Minify: true, Indent: "  "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<a><b x="1&#10;2"/></a>`; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got, _ := OuterHtml(CloneDocument(doc).Find("b")); got != `<b x="1&#10;2"/>` {
+		t.Errorf("want the clone rendered as XML, got %q", got)
+	}
+}
+
+func TestXMLManipulateHtml(t *testing.T) {
+	doc := loadXMLString(t, rssFeed)
+	title := doc.Find("channel > title")
+	title.SetHtml("<b>x</b>")
+	if got, _ := title.Html(); got != "<b>x</b>" {
+		t.Errorf("want %q, got %q", "<b>x</b>", got)
+	}
+
+	// the prefixes declared in scope are those of the fragment
+	doc.Find("item").First().AppendHtml(`<dc:subject>S</dc:subject><atom:link href="/one"/>`)
+	subject := doc.Find("item subject")
+	if subject.Length() != 1 || subject.Nodes[0].Namespace != "http://purl.org/dc/elements/1.1/" {
+		t.Errorf("want a Dublin Core subject, got %v", subject.Nodes)
+	}
+	if got, _ := OuterHtml(doc.Find("item > link")); got != `<atom:link href="/one"/>` {
+		t.Errorf("want the atom:link, got %q", got)
+	}
+
+	svg := loadXMLString(t, `<svg xmlns="http://www.w3.org/2000/svg"><g/></svg>`)
+	svg.Find("svg").AppendHtml(`<linearGradient id="g"/>`)
+	if g := svg.Find("#g"); g.Length() != 1 || g.Nodes[0].Namespace != "svg" || g.Nodes[0].Data != "linearGradient" {
+		t.Errorf("want an SVG linearGradient, got %v", g.Nodes)
+	}
+
+	var perr *HtmlParseError
+	if _, err := doc.Find("item").AppendHtmlWithOptions(`<a><b></a>`, HtmlOptions{}); !errors.As(err, &perr) {
+		t.Errorf("want an HtmlParseError, got %v", err)
+	}
+	if _, err := doc.Find("item").AppendHtmlWithOptions(`<a/></item>`, HtmlOptions{}); err == nil {
+		t.Error("want an error for the end tag of the context")
+	}
+	assertLength(t, doc.Find("item a").Nodes, 0)
+}